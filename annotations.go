@@ -0,0 +1,97 @@
+package eurip
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// WithAnnotations gives the Matcher a copy of a, a map from prefixes to
+// tags such as "requires-dpa" or "high-risk", for Annotations to return.
+// Tags are kept apart from the dataset, so they survive Refresh.
+func WithAnnotations(a *PrefixMap[[]string]) Option {
+	return func(m *Matcher) {
+		if a != nil && a.Len() > 0 {
+			m.annotations.Store(a.Clone())
+		}
+	}
+}
+
+// Annotations returns the tags of every annotated prefix holding addr,
+// those of shorter prefixes first, without repeats. It returns nil if there
+// are none. IPv4-mapped IPv6 addresses are treated as IPv4.
+func (m *Matcher) Annotations(addr netip.Addr) []string {
+	a := m.annotations.Load()
+	if a == nil {
+		return nil
+	}
+	var tags []string
+	for _, t := range a.LookupAll(addr) {
+		for _, tag := range t {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// SetAnnotation replaces the tags of p, so Annotations returns them for
+// addresses in p. Like SetOverride, each change copies the table of
+// annotations, so for loading many at once use LoadAnnotations.
+func (m *Matcher) SetAnnotation(p netip.Prefix, tags ...string) {
+	tags = slices.Clone(tags)
+	updatePrefixMap(&m.annotationsMu, &m.annotations, func(a *PrefixMap[[]string]) { a.Set(p, tags) })
+}
+
+// DeleteAnnotation removes the tags of exactly p, reporting whether it had
+// any.
+func (m *Matcher) DeleteAnnotation(p netip.Prefix) bool {
+	var deleted bool
+	updatePrefixMap(&m.annotationsMu, &m.annotations, func(a *PrefixMap[[]string]) { deleted = a.Delete(p) })
+	return deleted
+}
+
+// LoadAnnotations replaces m's annotations with those read from r, one
+// prefix per line followed by its tags, separated by spaces:
+//
+//	185.0.0.0/16 requires-dpa
+//	185.0.2.0/24 high-risk manual-review
+//
+// A bare address annotates just itself. Blank lines and # comments are
+// ignored, and a prefix listed twice gets the tags of both lines. If r
+// holds a malformed prefix, m keeps its old annotations and LoadAnnotations
+// returns an error wrapping ErrInvalidAddr.
+func (m *Matcher) LoadAnnotations(r io.Reader) error {
+	a := &PrefixMap[[]string]{}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		fields := strings.Fields(strings.SplitN(s.Text(), "#", 2)[0])
+		if len(fields) == 0 {
+			continue
+		}
+		p, err := netip.ParsePrefix(fields[0])
+		if err != nil {
+			addr, aerr := netip.ParseAddr(fields[0])
+			if aerr != nil {
+				return fmt.Errorf("%w: annotations line %d: %w", ErrInvalidAddr, line, err)
+			}
+			p = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		tags, _ := a.Get(p)
+		a.Set(p, append(tags, fields[1:]...))
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	m.annotationsMu.Lock()
+	defer m.annotationsMu.Unlock()
+	if a.Len() == 0 {
+		a = nil
+	}
+	m.annotations.Store(a)
+	return nil
+}
@@ -0,0 +1,83 @@
+package eurip
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAnnotations(t *testing.T) {
+	m := NewMatcher()
+	addr := netip.MustParseAddr("2.0.0.1")
+	if tags := m.Annotations(addr); tags != nil {
+		t.Errorf("Annotations without any = %v", tags)
+	}
+	m.SetAnnotation(netip.MustParsePrefix("2.0.0.0/8"), "requires-dpa", "eu-hosted")
+	m.SetAnnotation(netip.MustParsePrefix("2.0.0.0/24"), "high-risk", "requires-dpa")
+	if got, want := m.Annotations(netip.MustParseAddr("::ffff:2.0.0.1")), []string{"requires-dpa", "eu-hosted", "high-risk"}; !slices.Equal(got, want) {
+		t.Errorf("Annotations(%s) = %v, want %v", addr, got, want)
+	}
+	if got := m.Annotations(netip.MustParseAddr("2.1.0.1")); !slices.Equal(got, []string{"requires-dpa", "eu-hosted"}) {
+		t.Errorf("Annotations(2.1.0.1) = %v", got)
+	}
+	if e := m.Explain(addr); len(e.Annotations) != 3 {
+		t.Errorf("Explain(%s).Annotations = %v, want 3 tags", addr, e.Annotations)
+	}
+	if !m.DeleteAnnotation(netip.MustParsePrefix("2.0.0.0/8")) || m.DeleteAnnotation(netip.MustParsePrefix("2.0.0.0/8")) {
+		t.Error("DeleteAnnotation(2.0.0.0/8) should succeed once")
+	}
+	if got := m.Annotations(addr); !slices.Equal(got, []string{"high-risk", "requires-dpa"}) {
+		t.Errorf("after DeleteAnnotation, Annotations(%s) = %v", addr, got)
+	}
+
+	// Annotations are kept apart from the dataset.
+	b := refreshedDataset(t)
+	if err := m.Refresh(context.Background(), func(context.Context) ([]byte, error) { return b, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Annotations(addr); len(got) != 2 {
+		t.Errorf("after Refresh, Annotations(%s) = %v", addr, got)
+	}
+
+	var pm PrefixMap[[]string]
+	pm.Set(netip.MustParsePrefix("2620:db8::/32"), []string{"lab"})
+	m = NewMatcher(WithAnnotations(&pm))
+	pm.Delete(netip.MustParsePrefix("2620:db8::/32"))
+	if got := m.Annotations(netip.MustParseAddr("2620:db8::1")); !slices.Equal(got, []string{"lab"}) {
+		t.Errorf("WithAnnotations: Annotations(2620:db8::1) = %v", got)
+	}
+}
+
+func TestLoadAnnotations(t *testing.T) {
+	m := NewMatcher()
+	err := m.LoadAnnotations(strings.NewReader(`# compliance hints
+44.0.0.0/8 requires-dpa
+44.1.2.3   high-risk   # one host
+
+2620:db8::/32 lab
+44.0.0.0/8 manual-review
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ip, want := range map[string][]string{
+		"44.1.2.3":    {"requires-dpa", "manual-review", "high-risk"},
+		"44.1.2.4":    {"requires-dpa", "manual-review"},
+		"2620:db8::1": {"lab"},
+		"45.0.0.1":    nil,
+	} {
+		if got := m.Annotations(netip.MustParseAddr(ip)); !slices.Equal(got, want) {
+			t.Errorf("Annotations(%s) = %v, want %v", ip, got, want)
+		}
+	}
+
+	if err := m.LoadAnnotations(strings.NewReader("44.0.0.0/8 ok\n44.0.0.0/33 broken\n")); !errors.Is(err, ErrInvalidAddr) || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("LoadAnnotations with a bad prefix = %v, want ErrInvalidAddr on line 2", err)
+	}
+	if got := m.Annotations(netip.MustParseAddr("2620:db8::1")); len(got) != 1 {
+		t.Error("a failed LoadAnnotations replaced the annotations")
+	}
+}
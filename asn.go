@@ -0,0 +1,17 @@
+package eurip
+
+import "net/netip"
+
+// ASN returns the autonomous system number and organization announcing addr,
+// or 0 and "" if it is unknown.
+//
+// The ASN tables are large, so they are only embedded when built with
+// -tags eurip_asn, after generating asn_data.go with process.py --asn and
+// codegen.py. Without the tag, ASN always returns 0 and "".
+func ASN(addr netip.Addr) (uint32, string) {
+	v, ok := lookupValue(addr, asnV4Data, asnV6Data)
+	if !ok || int(v) >= len(asnNumbers) {
+		return 0, ""
+	}
+	return asnNumbers[v], asnNames[v]
+}
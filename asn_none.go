@@ -0,0 +1,11 @@
+//go:build !eurip_asn
+
+package eurip
+
+// Empty ASN tables, used unless built with the eurip_asn tag.
+var (
+	asnV4Data  = []uint32{0}
+	asnV6Data  = []uint32{0}
+	asnNumbers []uint32
+	asnNames   []string
+)
@@ -0,0 +1,29 @@
+package eurip
+
+import (
+	"context"
+	"net/netip"
+)
+
+// CheckBatch checks each of addrs in order, calling fn with its index and
+// Check's answer as soon as it is computed, so that tools showing results
+// to a user can display them as they arrive rather than after the last
+// one. If ctx is done before every address is checked, CheckBatch stops
+// without calling fn again and returns ctx.Err(); otherwise it returns nil.
+// fn runs in the calling goroutine.
+func CheckBatch(ctx context.Context, addrs []netip.Addr, fn func(i int, r Result, err error)) error {
+	return defaultMatcher.CheckBatch(ctx, addrs, fn)
+}
+
+// CheckBatch is like the package-level CheckBatch, but uses m's view of the
+// dataset.
+func (m *Matcher) CheckBatch(ctx context.Context, addrs []netip.Addr, fn func(i int, r Result, err error)) error {
+	for i, addr := range addrs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		r, err := m.Check(addr)
+		fn(i, r, err)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package eurip
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestCheckBatch(t *testing.T) {
+	addrs := []netip.Addr{
+		netip.MustParseAddr("2.0.0.1"),
+		netip.MustParseAddr("1.0.0.1"),
+		netip.MustParseAddr("10.0.0.1"),
+		netip.MustParseAddr("2001:420:4000:1::"),
+	}
+	m := NewMatcher(WithUnclassifiedPolicy(UnclassifiedError))
+	var got []int
+	err := m.CheckBatch(context.Background(), addrs, func(i int, r Result, err error) {
+		got = append(got, i)
+		want, wantErr := m.Check(addrs[i])
+		if r != want || (err == nil) != (wantErr == nil) {
+			t.Errorf("CheckBatch result %d = %+v, %v, want %+v, %v", i, r, err, want, wantErr)
+		}
+	})
+	if err != nil || len(got) != len(addrs) {
+		t.Errorf("CheckBatch = %v after results %v, want nil after all %d", err, got, len(addrs))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	got = nil
+	err = m.CheckBatch(ctx, addrs, func(i int, r Result, err error) {
+		got = append(got, i)
+		if i == 1 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) || len(got) != 2 {
+		t.Errorf("CheckBatch canceled after result 1 = %v after results %v, want Canceled after 2", err, got)
+	}
+}
@@ -0,0 +1,113 @@
+package eurip
+
+import (
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+// Benchmarks for evaluating table layout and lookup changes. Reference
+// results are in testdata/bench.txt; compare a change against them with
+// make benchcmp, which needs golang.org/x/perf/cmd/benchstat.
+
+// benchAddrs is the number of addresses each benchmark cycles through, so
+// that lookups see varied paths rather than one cached one.
+const benchAddrs = 4096
+
+// benchAddrs4 returns addresses drawn uniformly from the IPv4 space.
+func benchAddrs4() []netip.Addr {
+	r := rand.New(rand.NewPCG(1, 2))
+	addrs := make([]netip.Addr, benchAddrs)
+	for i := range addrs {
+		a := r.Uint32()
+		addrs[i] = netip.AddrFrom4([4]byte{byte(a >> 24), byte(a >> 16), byte(a >> 8), byte(a)})
+	}
+	return addrs
+}
+
+// benchAddrs6 returns addresses drawn uniformly from 2000::/3, the global
+// unicast space.
+func benchAddrs6() []netip.Addr {
+	r := rand.New(rand.NewPCG(1, 2))
+	addrs := make([]netip.Addr, benchAddrs)
+	for i := range addrs {
+		var a [16]byte
+		for j := range a {
+			a[j] = byte(r.Uint32())
+		}
+		a[0] = 0x20 | a[0]&0x1f
+		addrs[i] = netip.AddrFrom16(a)
+	}
+	return addrs
+}
+
+// benchAddrsLocal returns addresses from a handful of EU /24s, like the
+// clients of a regional site.
+func benchAddrsLocal() []netip.Addr {
+	r := rand.New(rand.NewPCG(1, 2))
+	nets := make([]netip.Addr, 8)
+	for i := range nets {
+		nets[i] = defaultMatcher.RandomEUAddr(r)
+	}
+	addrs := make([]netip.Addr, benchAddrs)
+	for i := range addrs {
+		a := nets[r.IntN(len(nets))].As4()
+		a[3] = byte(r.Uint32())
+		addrs[i] = netip.AddrFrom4(a)
+	}
+	return addrs
+}
+
+func benchmarkLookup(b *testing.B, addrs []netip.Addr) {
+	m := NewMatcher()
+	for i := 0; b.Loop(); i++ {
+		m.Lookup(addrs[i%len(addrs)])
+	}
+}
+
+func BenchmarkLookupRandomV4(b *testing.B) { benchmarkLookup(b, benchAddrs4()) }
+func BenchmarkLookupRandomV6(b *testing.B) { benchmarkLookup(b, benchAddrs6()) }
+func BenchmarkLookupLocal(b *testing.B)    { benchmarkLookup(b, benchAddrsLocal()) }
+
+func BenchmarkIsFromEU(b *testing.B) {
+	addrs := benchAddrs4()
+	ips := make([]net.IP, len(addrs))
+	for i, a := range addrs {
+		ips[i] = a.AsSlice()
+	}
+	for i := 0; b.Loop(); i++ {
+		IsFromEU(ips[i%len(ips)])
+	}
+}
+
+// BenchmarkBatch looks up benchAddrs mixed addresses per iteration, and
+// reports the cost per address.
+func BenchmarkBatch(b *testing.B) {
+	addrs := append(benchAddrs4()[:benchAddrs/2], benchAddrs6()[:benchAddrs/2]...)
+	m := NewMatcher()
+	for b.Loop() {
+		for _, a := range addrs {
+			m.Lookup(a)
+		}
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(addrs)), "ns/addr")
+}
+
+func BenchmarkLookupParallel(b *testing.B) {
+	addrs := benchAddrs4()
+	m := NewMatcher()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			m.Lookup(addrs[i%len(addrs)])
+		}
+	})
+}
+
+func BenchmarkCheckStrict(b *testing.B) {
+	addrs := benchAddrs4()
+	m := NewMatcher(WithStrict(true))
+	for i := 0; b.Loop(); i++ {
+		m.Check(addrs[i%len(addrs)])
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/rmmh/eurip/httpmw"
+)
+
+// extAuthz implements Envoy's HTTP external authorization protocol, for a
+// cluster configured as the ext_authz filter's http_service with path_prefix
+// /ext_authz. Envoy sends each request's headers here; a 200 response lets
+// the request through, and any other status is returned to the client.
+// Allowed requests get x-client-eu and x-client-country response headers,
+// which Envoy adds to the upstream request if they are listed in
+// allowed_upstream_headers.
+func (s *server) extAuthz(w http.ResponseWriter, r *http.Request) {
+	m := s.matcher.Load()
+	addr, ok := envoyClientAddr(r)
+	if !ok {
+		s.lookups[statusInvalid].Add(1)
+		http.Error(w, "no client address", http.StatusForbidden)
+		return
+	}
+	res := s.lookup(m, addr)
+	if !s.policy.Load().allows(res.EU, res.Uncertain, res.Country) {
+		http.Error(w, "not available in your region", http.StatusForbidden)
+		return
+	}
+	w.Header().Set(httpmw.ClientEUHeader, strconv.FormatBool(res.EU))
+	w.Header().Set(httpmw.ClientCountryHeader, res.Country)
+	w.WriteHeader(http.StatusOK)
+}
+
+// envoyClientAddr returns the client address Envoy forwarded: the trusted
+// x-envoy-external-address if set, or else the last X-Forwarded-For hop,
+// which Envoy appends when it is the edge proxy.
+func envoyClientAddr(r *http.Request) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(r.Header.Get("X-Envoy-External-Address")); err == nil {
+		return addr.Unmap(), true
+	}
+	hops := r.Header.Values("X-Forwarded-For")
+	if len(hops) == 0 {
+		return netip.Addr{}, false
+	}
+	last := hops[len(hops)-1]
+	last = strings.TrimSpace(last[strings.LastIndex(last, ",")+1:])
+	addr, err := netip.ParseAddr(last)
+	return addr.Unmap(), err == nil
+}
@@ -0,0 +1,276 @@
+// Command eurip classifies IP addresses as EU or not.
+//
+// Usage:
+//
+//	eurip [flags] ip...
+//
+// Each address is printed with its classification and country, one per line.
+// With --json, each is instead printed as a JSON object:
+//
+//	{"ip":"2.0.0.1","eu":true,"uncertain":false,"country":"FR","prefix":"2.0.0.0/12","dataset":"2018-05-01"}
+//
+// Addresses that don't parse are reported on stderr, or as an object with an
+// "error" field in --json mode, and make eurip exit with status 2.
+//
+// With --quiet, nothing is printed, and eurip exits with status 0 if every
+// address is EU, 1 if one isn't, or 2 if one doesn't parse, for use in shell
+// conditionals:
+//
+//	if eurip --quiet "$ip"; then ...
+//
+// With --stdin, addresses are read one per line from standard input instead,
+// and each line is printed followed by its classification and country, tab
+// separated, or "invalid". With --column=N, input is instead CSV, classified
+// by its Nth column, and each record is printed with is_eu and country
+// columns appended; --header passes a header record through. Lines are
+// classified in parallel but printed in input order.
+//
+// --uk and --microstates need tables the embedded dataset lacks; eurip warns
+// on stderr when the dataset in use can't honor them, and classifies as if
+// they weren't given.
+//
+// eurip serve runs an HTTP server: GET /check?ip=<addr> returns the --json
+// object for addr, or for the client if ip is omitted; POST /check/stream
+// classifies newline-delimited JSON arrays of addresses over one
+// full-duplex request, answering each batch as it arrives; /ext_authz/
+// serves Envoy's HTTP external authorization protocol, tagging requests with
+// x-client-eu and x-client-country headers or, with --ext-authz-deny,
+// rejecting EU or non-EU clients; /healthz reports
+// whether the dataset is valid and, with --max-age, fresh; and /metrics
+// exposes counters in the Prometheus text format. --dataset=FILE serves a
+// dataset written by make eurip.dataset in place of the embedded one.
+// SIGHUP reloads the dataset, rereading FILE, and keeps the old one if that
+// fails; SIGINT or SIGTERM shut the server down gracefully.
+//
+// In place of --ext-authz-deny or eurip proxy's --deny, --policy=FILE reads
+// allow and deny lists of countries and sets from a JSON file:
+//
+//	{"default": "deny", "allow": ["eu", "CH"], "deny": ["uncertain"]}
+//
+// Entries are ISO country codes or the sets "eu", "non-eu", and
+// "uncertain". Country codes take precedence over sets, and deny over
+// allow; addresses matching nothing get the default, which is "deny" if
+// there is only an allow list and "allow" otherwise. eurip serve rereads
+// the policy on SIGHUP.
+//
+// eurip proxy runs an HTTP forward proxy, relaying CONNECT tunnels and plain
+// http:// requests and logging each destination's classification. Allowed
+// responses carry X-Destination-EU and X-Destination-Country headers;
+// --deny=eu or --deny=non-eu refuses those destinations with 403, and
+// --via-eu or --via-non-eu sends them through another proxy. Names are
+// resolved once and the proxy connects to the address it classified, trying
+// each until one is allowed.
+//
+// eurip selftest checks the embedded dataset, or with --dataset=FILE a
+// serialized one, such as a freshly generated or custom one, before it
+// serves traffic: it must validate and classify a small set of well-known
+// EU and non-EU addresses as expected. Failures are printed, and eurip
+// exits with status 1 if there are any or 2 if the dataset can't be read.
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmmh/eurip"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// Exit statuses, which --quiet reports for the inputs as a whole.
+const (
+	statusEU = iota
+	statusNonEU
+	statusInvalid
+)
+
+// result is the --json output for one input.
+type result struct {
+	IP        string `json:"ip"`
+	EU        bool   `json:"eu"`
+	Uncertain bool   `json:"uncertain"`
+	Country   string `json:"country"`
+	Prefix    string `json:"prefix"`
+	Dataset   string `json:"dataset"`
+	Error     string `json:"error,omitempty"`
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "serve" {
+		return serve(args[1:], stderr)
+	}
+	if len(args) > 0 && args[0] == "proxy" {
+		return proxyMain(args[1:], stderr)
+	}
+	if len(args) > 0 && args[0] == "selftest" {
+		return selftest(args[1:], stdout, stderr)
+	}
+	fs := flag.NewFlagSet("eurip", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	jsonOut := fs.Bool("json", false, "print one JSON object per address")
+	quiet := fs.Bool("quiet", false, "print nothing; exit 0 if every address is EU, 1 if not, 2 on a parse error")
+	stream := fs.Bool("stdin", false, "read addresses from standard input, one per line")
+	column := fs.Int("column", 0, "with --stdin, read CSV and classify this `column`, counting from 1")
+	header := fs.Bool("header", false, "with --column, pass the first record through as a header")
+	workers := fs.Int("workers", runtime.GOMAXPROCS(0), "with --stdin, classify with this many goroutines")
+	uk := fs.Bool("uk", false, "treat the UK as part of the EU")
+	microstates := fs.Bool("microstates", false, "treat Andorra, Monaco, San Marino, and Vatican City as part of the EU")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: eurip [flags] ip...\n       eurip [flags] --stdin\n       eurip serve [flags]\n       eurip proxy [flags]\n       eurip selftest [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return statusInvalid
+	}
+	if *stream == (fs.NArg() > 0) || *column < 0 || *workers < 1 {
+		fs.Usage()
+		return statusInvalid
+	}
+
+	m := eurip.NewMatcher(eurip.WithUKTreatedAsEU(*uk), eurip.WithMicrostatesTreatedAsEU(*microstates))
+	warnTables(m, stderr)
+	c := &classifier{
+		m:       m,
+		dataset: datasetDate(m),
+		json:    *jsonOut,
+		quiet:   *quiet,
+		column:  *column,
+	}
+	status := statusEU
+	if *stream {
+		var err error
+		if status, err = c.stream(stdin, stdout, *workers, *header); err != nil {
+			fmt.Fprintf(stderr, "eurip: %v\n", err)
+			return statusInvalid
+		}
+	}
+	var buf bytes.Buffer
+	for _, s := range fs.Args() {
+		buf.Reset()
+		st, err := c.classify(&buf, []string{s})
+		if err != nil && !c.json && !c.quiet {
+			fmt.Fprintf(stderr, "eurip: %v\n", err)
+		} else {
+			stdout.Write(buf.Bytes())
+		}
+		status = max(status, st)
+	}
+	if status == statusNonEU && !c.quiet {
+		return 0
+	}
+	return status
+}
+
+// A classifier formats the output for each input.
+type classifier struct {
+	m           *eurip.Matcher
+	dataset     string
+	json, quiet bool
+	column      int // 1-based CSV column, or 0 for whole lines
+}
+
+// classify appends the output for one input record to buf, and returns its
+// status. Invalid inputs also return the parse error, but still produce
+// output unless --quiet is set: plain text output marks them "invalid".
+func (c *classifier) classify(buf *bytes.Buffer, record []string) (int, error) {
+	s := record[0]
+	if c.column > 0 {
+		s = ""
+		if c.column <= len(record) {
+			s = strings.TrimSpace(record[c.column-1])
+		}
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		if c.quiet {
+			return statusInvalid, err
+		}
+		switch {
+		case c.json:
+			json.NewEncoder(buf).Encode(result{IP: s, Dataset: c.dataset, Error: err.Error()})
+		case c.column > 0:
+			writeCSV(buf, append(record, "", ""))
+		default:
+			buf.WriteString(s + "\tinvalid\t\n")
+		}
+		return statusInvalid, err
+	}
+
+	res := c.m.Lookup(addr)
+	status := statusEU
+	if !res.EU {
+		status = statusNonEU
+	}
+	if c.quiet {
+		return status, nil
+	}
+	country := c.m.Country(addr)
+	switch {
+	case c.json:
+		json.NewEncoder(buf).Encode(newResult(c.m, addr, res, c.dataset))
+	case c.column > 0:
+		writeCSV(buf, append(record, strconv.FormatBool(res.EU), country))
+	default:
+		buf.WriteString(record[0] + "\t" + label(res) + "\t" + country + "\n")
+	}
+	return status, nil
+}
+
+func newResult(m *eurip.Matcher, addr netip.Addr, res eurip.Result, dataset string) result {
+	return result{
+		IP:        addr.String(),
+		EU:        res.EU,
+		Uncertain: res.Uncertain,
+		Country:   m.Country(addr),
+		Prefix:    m.MatchedPrefix(addr).String(),
+		Dataset:   dataset,
+	}
+}
+
+// warnTables prints a warning to stderr for each option m's dataset lacks
+// the table for, as reported by CheckTables.
+func warnTables(m *eurip.Matcher, stderr io.Writer) {
+	if err := m.CheckTables(); err != nil {
+		for _, err := range unjoin(err) {
+			fmt.Fprintf(stderr, "eurip: warning: %v\n", err)
+		}
+	}
+}
+
+// datasetDate formats the version of m's dataset as a date.
+func datasetDate(m *eurip.Matcher) string {
+	if t, err := time.Parse("20060102", m.Version()); err == nil {
+		return t.Format(time.DateOnly)
+	}
+	return m.Version()
+}
+
+func writeCSV(buf *bytes.Buffer, record []string) {
+	w := csv.NewWriter(buf)
+	w.Write(record)
+	w.Flush()
+}
+
+func label(r eurip.Result) string {
+	switch {
+	case r.EU && r.Uncertain:
+		return "EU?"
+	case r.EU:
+		return "EU"
+	case r.Uncertain:
+		return "non-EU?"
+	}
+	return "non-EU"
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"2.0.0.1", "1.0.0.1", "::ffff:2.0.0.1"}, nil, &stdout, &stderr); status != 0 {
+		t.Errorf("run() = %d, stderr %q", status, stderr.String())
+	}
+	want := "2.0.0.1\tEU\t\n1.0.0.1\tnon-EU\t\n::ffff:2.0.0.1\tEU\t\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("run() printed %q, want %q", got, want)
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	if status := run([]string{"1.0.0.1", "bogus"}, nil, &stdout, &stderr); status != 2 {
+		t.Errorf("run(bogus) = %d, want 2", status)
+	}
+	if !strings.Contains(stderr.String(), "bogus") {
+		t.Errorf("run(bogus) stderr = %q", stderr.String())
+	}
+}
+
+func TestRunJSON(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"--json", "2.0.0.1", "bogus"}, nil, &stdout, &stderr); status != 2 {
+		t.Errorf("run(--json) = %d, want 2", status)
+	}
+	dec := json.NewDecoder(&stdout)
+	var got []result
+	for dec.More() {
+		var r result
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+	if len(got) != 2 {
+		t.Fatalf("run(--json) printed %d objects, want 2", len(got))
+	}
+	if r := got[0]; r.IP != "2.0.0.1" || !r.EU || r.Prefix != "2.0.0.0/12" || r.Dataset != "2018-05-01" || r.Error != "" {
+		t.Errorf("run(--json 2.0.0.1) = %+v", r)
+	}
+	if r := got[1]; r.IP != "bogus" || r.Error == "" {
+		t.Errorf("run(--json bogus) = %+v", r)
+	}
+}
+
+func TestRunQuiet(t *testing.T) {
+	v6 := 0
+	if !hasIPv6 {
+		v6 = 1
+	}
+	for _, tc := range []struct {
+		args []string
+		want int
+	}{
+		{[]string{"2.0.0.1"}, 0},
+		{[]string{"2.0.0.1", "2001:420:4000:1::"}, v6},
+		{[]string{"1.0.0.1"}, 1},
+		{[]string{"2.0.0.1", "1.0.0.1"}, 1},
+		{[]string{"bogus"}, 2},
+		{[]string{"1.0.0.1", "bogus", "2.0.0.1"}, 2},
+	} {
+		var stdout, stderr bytes.Buffer
+		if got := run(append([]string{"--quiet"}, tc.args...), nil, &stdout, &stderr); got != tc.want {
+			t.Errorf("run(--quiet %v) = %d, want %d", tc.args, got, tc.want)
+		}
+		if stdout.Len() > 0 || stderr.Len() > 0 {
+			t.Errorf("run(--quiet %v) printed %q, %q", tc.args, stdout.String(), stderr.String())
+		}
+	}
+}
+
+func TestRunStdin(t *testing.T) {
+	var in strings.Builder
+	var want strings.Builder
+	for i := range 3000 {
+		switch i % 3 {
+		case 0:
+			in.WriteString("2.0.0.1\n")
+			want.WriteString("2.0.0.1\tEU\t\n")
+		case 1:
+			in.WriteString(" 1.0.0." + strconv.Itoa(i%256) + "\r\n\n")
+			want.WriteString("1.0.0." + strconv.Itoa(i%256) + "\tnon-EU\t\n")
+		case 2:
+			in.WriteString("bogus\n")
+			want.WriteString("bogus\tinvalid\t\n")
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"--stdin", "--workers=4"}, strings.NewReader(in.String()), &stdout, &stderr); status != 2 {
+		t.Errorf("run(--stdin) = %d, want 2", status)
+	}
+	if stdout.String() != want.String() {
+		t.Errorf("run(--stdin) output differs from want; got %d bytes, want %d", stdout.Len(), want.Len())
+	}
+
+	stdout.Reset()
+	if status := run([]string{"--stdin", "--quiet"}, strings.NewReader("2.0.0.1\n1.0.0.1\n"), &stdout, &stderr); status != 1 || stdout.Len() > 0 {
+		t.Errorf("run(--stdin --quiet) = %d, printed %q", status, stdout.String())
+	}
+	if status := run([]string{"--stdin", "1.0.0.1"}, strings.NewReader(""), &stdout, &stderr); status != 2 {
+		t.Errorf("run(--stdin 1.0.0.1) = %d, want usage error", status)
+	}
+}
+
+func TestRunStdinCSV(t *testing.T) {
+	in := "time,client,path\n1,2.0.0.1,/\n2,\"1.0.0.1\",\"/a,b\"\n3\n"
+	want := "time,client,path,is_eu,country\n1,2.0.0.1,/,true,\n2,1.0.0.1,\"/a,b\",false,\n3,,\n"
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"--stdin", "--column=2", "--header"}, strings.NewReader(in), &stdout, &stderr); status != 2 {
+		t.Errorf("run(--stdin --column=2) = %d, want 2", status)
+	}
+	if got := stdout.String(); got != want {
+		t.Errorf("run(--stdin --column=2) printed %q, want %q", got, want)
+	}
+
+	stdout.Reset()
+	if status := run([]string{"--stdin", "--column=2", "--header", "--json"}, strings.NewReader(in), &stdout, &stderr); status != 2 {
+		t.Errorf("run(--stdin --column=2 --json) = %d, want 2", status)
+	}
+	if n := strings.Count(stdout.String(), "\n"); n != 3 {
+		t.Errorf("run(--stdin --column=2 --json) printed %d objects, want 3:\n%s", n, stdout.String())
+	}
+}
+
+func TestRunWarnsOfMissingTables(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"--uk", "2.0.0.1"}, nil, &stdout, &stderr); status != 0 {
+		t.Errorf("run(--uk) = %d, stderr %q", status, stderr.String())
+	}
+	if got := stderr.String(); !strings.HasPrefix(got, "eurip: warning: ") || !strings.Contains(got, "WithUKTreatedAsEU") {
+		t.Errorf("run(--uk) over the embedded dataset printed %q, want a warning that it has no UK table", got)
+	}
+	stderr.Reset()
+	if run([]string{"2.0.0.1"}, nil, &stdout, &stderr); stderr.Len() != 0 {
+		t.Errorf("run() printed %q, want no warnings", stderr.String())
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// A policy decides which addresses the server modes let through: clients
+// in eurip serve's ext_authz endpoint, destinations in eurip proxy. It is
+// read from a JSON file such as
+//
+//	{"default": "deny", "allow": ["eu", "CH"], "deny": ["uncertain", "HU"]}
+//
+// Each entry is an ISO 3166-1 country code or a set: "eu" and "non-eu"
+// follow the --uk and --microstates flags, and "uncertain" matches anycast
+// and satellite ranges. Country codes take precedence over sets, and deny
+// over allow, so {"deny": ["eu"], "allow": ["DE"]} denies the EU except
+// Germany. Addresses matching nothing get the default, which is "deny" if
+// there is only an allow list and "allow" otherwise.
+//
+// The embedded dataset only locates countries in and around the EU, so
+// codes for countries elsewhere never match; use "non-eu" for those.
+type policy struct {
+	Default string   `json:"default"`
+	Allow   []string `json:"allow"`
+	Deny    []string `json:"deny"`
+}
+
+// policySets are the set names a policy entry can use.
+var policySets = []string{"eu", "non-eu", "uncertain"}
+
+// denyPolicy returns the policy for the --deny shorthand: nil, allowing
+// everything, for "", or one denying the "eu" or "non-eu" set.
+func denyPolicy(deny string) *policy {
+	if deny == "" {
+		return nil
+	}
+	return &policy{Default: "allow", Deny: []string{deny}}
+}
+
+// readPolicy reads and checks the policy in the file at path.
+func readPolicy(path string) (*policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	p := new(policy)
+	if err := dec.Decode(p); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := p.normalize(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return p, nil
+}
+
+// normalize checks p's entries, lower-casing sets and upper-casing country
+// codes, and fills in its default.
+func (p *policy) normalize() error {
+	switch p.Default {
+	case "":
+		p.Default = "allow"
+		if len(p.Allow) > 0 && len(p.Deny) == 0 {
+			p.Default = "deny"
+		}
+	case "allow", "deny":
+	default:
+		return fmt.Errorf("default %q is neither allow nor deny", p.Default)
+	}
+	for _, list := range [][]string{p.Allow, p.Deny} {
+		for i, e := range list {
+			if set := strings.ToLower(e); slices.Contains(policySets, set) {
+				list[i] = set
+				continue
+			}
+			if len(e) != 2 || strings.Trim(strings.ToUpper(e), "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+				return fmt.Errorf("%q is neither a country code nor one of %s", e, strings.Join(policySets, ", "))
+			}
+			list[i] = strings.ToUpper(e)
+		}
+	}
+	return nil
+}
+
+// allows reports whether p lets through an address with the given
+// classification and country. A nil policy allows everything.
+func (p *policy) allows(eu, uncertain bool, country string) bool {
+	if p == nil {
+		return true
+	}
+	if country != "" {
+		if slices.Contains(p.Deny, country) {
+			return false
+		}
+		if slices.Contains(p.Allow, country) {
+			return true
+		}
+	}
+	in := func(list []string) bool {
+		return eu && slices.Contains(list, "eu") || !eu && slices.Contains(list, "non-eu") ||
+			uncertain && slices.Contains(list, "uncertain")
+	}
+	if in(p.Deny) {
+		return false
+	}
+	if in(p.Allow) {
+		return true
+	}
+	return p.Default == "allow"
+}
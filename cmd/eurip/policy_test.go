@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rmmh/eurip"
+)
+
+func writePolicy(t *testing.T, path, policy string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(policy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	type addr struct {
+		eu, uncertain bool
+		country       string
+	}
+	fr, gb, us, anycast := addr{true, false, "FR"}, addr{false, false, "GB"}, addr{false, false, ""}, addr{true, true, "FR"}
+	for _, tc := range []struct {
+		policy  string
+		allowed []addr
+		denied  []addr
+	}{
+		{`{}`, []addr{fr, gb, us, anycast}, nil},
+		{`{"allow": ["eu"]}`, []addr{fr, anycast}, []addr{gb, us}},
+		{`{"deny": ["EU", "uncertain"]}`, []addr{gb, us}, []addr{fr, anycast}},
+		{`{"default": "deny", "allow": ["eu", "gb"], "deny": ["uncertain"]}`, []addr{fr, gb}, []addr{us, anycast}},
+		{`{"deny": ["eu"], "allow": ["FR"]}`, []addr{fr, anycast, gb, us}, nil},
+		{`{"default": "allow", "allow": ["FR"], "deny": ["non-eu"]}`, []addr{fr, anycast}, []addr{gb, us}},
+		{`{"default": "deny", "deny": ["GB"]}`, nil, []addr{fr, gb, us, anycast}},
+	} {
+		path := filepath.Join(t.TempDir(), "policy.json")
+		writePolicy(t, path, tc.policy)
+		p, err := readPolicy(path)
+		if err != nil {
+			t.Errorf("readPolicy(%s) = %v", tc.policy, err)
+			continue
+		}
+		for _, a := range tc.allowed {
+			if !p.allows(a.eu, a.uncertain, a.country) {
+				t.Errorf("%s denies %+v", tc.policy, a)
+			}
+		}
+		for _, a := range tc.denied {
+			if p.allows(a.eu, a.uncertain, a.country) {
+				t.Errorf("%s allows %+v", tc.policy, a)
+			}
+		}
+	}
+}
+
+func TestReadPolicyErrors(t *testing.T) {
+	for _, policy := range []string{
+		`{"default": "block"}`,
+		`{"allow": ["europe"]}`,
+		`{"deny": ["F1"]}`,
+		`{"allowed": ["eu"]}`,
+		`{"allow": "eu"}`,
+	} {
+		path := filepath.Join(t.TempDir(), "policy.json")
+		writePolicy(t, path, policy)
+		if _, err := readPolicy(path); err == nil {
+			t.Errorf("readPolicy(%s) succeeded", policy)
+		}
+	}
+	if _, err := readPolicy(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("readPolicy of a missing file succeeded")
+	}
+}
+
+func TestServerPolicyReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	writePolicy(t, path, `{"allow": ["eu"]}`)
+	s := newServer(func() (*eurip.Matcher, error) { return eurip.NewMatcher(), nil }, 0)
+	s.policyFile = path
+	if err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	authz := func(ip string) int {
+		r := httptest.NewRequest("GET", "/ext_authz/", nil)
+		r.Header.Set("X-Envoy-External-Address", ip)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w.Code
+	}
+	if eu, nonEU := authz("2.0.0.1"), authz("1.0.0.1"); eu != 200 || nonEU != 403 {
+		t.Errorf("allowing eu: got %d for an EU client and %d for a non-EU one, want 200 and 403", eu, nonEU)
+	}
+
+	writePolicy(t, path, `{"deny": ["eu"]}`)
+	if err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if eu, nonEU := authz("2.0.0.1"), authz("1.0.0.1"); eu != 403 || nonEU != 200 {
+		t.Errorf("denying eu after reload: got %d for an EU client and %d for a non-EU one, want 403 and 200", eu, nonEU)
+	}
+
+	writePolicy(t, path, `{"deny": ["nowhere"]}`)
+	if err := s.reload(); err == nil {
+		t.Error("reload with a bad policy succeeded")
+	}
+	if eu := authz("2.0.0.1"); eu != 403 {
+		t.Errorf("a failed reload replaced the policy: got %d for an EU client, want 403", eu)
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/netip"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/rmmh/eurip"
+)
+
+// proxyMain runs the forward proxy until interrupted.
+func proxyMain(args []string, stderr io.Writer) int {
+	fs := flag.NewFlagSet("eurip proxy", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", "localhost:3128", "listen on this `host:port`")
+	deny := fs.String("deny", "", "refuse connections to `eu` or `non-eu` destinations")
+	policyFile := fs.String("policy", "", "allow and refuse connections by the JSON policy in `file`")
+	viaEU := fs.String("via-eu", "", "forward connections to EU destinations through the proxy at this `host:port`")
+	viaNonEU := fs.String("via-non-eu", "", "forward connections to non-EU destinations through the proxy at this `host:port`")
+	quiet := fs.Bool("quiet", false, "don't log connections")
+	uk := fs.Bool("uk", false, "treat the UK as part of the EU")
+	microstates := fs.Bool("microstates", false, "treat Andorra, Monaco, San Marino, and Vatican City as part of the EU")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: eurip proxy [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return statusInvalid
+	}
+	if *deny != "" && *deny != "eu" && *deny != "non-eu" || *deny != "" && *policyFile != "" {
+		fs.Usage()
+		return statusInvalid
+	}
+	pol := denyPolicy(*deny)
+	if *policyFile != "" {
+		var err error
+		if pol, err = readPolicy(*policyFile); err != nil {
+			fmt.Fprintf(stderr, "eurip: %v\n", err)
+			return statusInvalid
+		}
+	}
+
+	m := eurip.NewMatcher(eurip.WithUKTreatedAsEU(*uk), eurip.WithMicrostatesTreatedAsEU(*microstates))
+	warnTables(m, stderr)
+	p := newForwardProxy(m, pol, [2]string{*viaNonEU, *viaEU})
+	if !*quiet {
+		p.log = stderr
+	}
+	srv := &http.Server{Addr: *addr, Handler: p}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		srv.Shutdown(ctx)
+		cancel()
+	}()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(stderr, "eurip: %v\n", err)
+		return statusInvalid
+	}
+	return 0
+}
+
+// A forwardProxy relays CONNECT tunnels and plain HTTP requests, classifying
+// each destination. Allowed responses are tagged with X-Destination-EU and
+// X-Destination-Country headers.
+//
+// Destinations are resolved once, and the proxy connects to the address it
+// classified, so a name can't be rebound to another address after the check.
+type forwardProxy struct {
+	matcher *eurip.Matcher
+	// policy decides which destinations are allowed; nil allows all.
+	policy *policy
+	// via holds the upstream proxies for non-EU and EU destinations, ""
+	// to connect directly.
+	via        [2]string
+	transports [2]*http.Transport // for plain HTTP requests, by via
+	log        io.Writer          // connection log, if not nil
+
+	lookup func(ctx context.Context, host string) ([]netip.Addr, error)
+	dial   func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+func newForwardProxy(m *eurip.Matcher, pol *policy, via [2]string) *forwardProxy {
+	var d net.Dialer
+	p := &forwardProxy{
+		matcher: m,
+		policy:  pol,
+		via:     via,
+		lookup: func(ctx context.Context, host string) ([]netip.Addr, error) {
+			return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		},
+		dial: d.DialContext,
+	}
+	for i := range p.transports {
+		t := &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return p.dial(ctx, network, addr)
+			},
+			IdleConnTimeout: 90 * time.Second,
+		}
+		if via[i] != "" {
+			t.Proxy = http.ProxyURL(&url.URL{Scheme: "http", Host: via[i]})
+		}
+		p.transports[i] = t
+	}
+	return p
+}
+
+// A destination is the classified address a request will be sent to.
+type destination struct {
+	addr netip.AddrPort
+	res  eurip.Result
+	// route indexes forwardProxy.via and transports.
+	route int
+}
+
+// errDenied is returned when no address of a destination is allowed.
+var errDenied = errors.New("destination not allowed")
+
+// resolve chooses the first allowed address of hostport, which is a
+// "host:port" pair as in a CONNECT request or URL. If every address is
+// denied, it returns the first with errDenied.
+func (p *forwardProxy) resolve(ctx context.Context, hostport string) (destination, error) {
+	host, portStr, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return destination{}, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return destination{}, fmt.Errorf("invalid port %q", portStr)
+	}
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = append(addrs, addr)
+	} else if addrs, err = p.lookup(ctx, host); err != nil {
+		return destination{}, err
+	}
+	if len(addrs) == 0 {
+		return destination{}, fmt.Errorf("no addresses for %s", host)
+	}
+	var first destination
+	for i, addr := range addrs {
+		addr = addr.Unmap()
+		d := destination{addr: netip.AddrPortFrom(addr, uint16(port)), res: p.matcher.Lookup(addr)}
+		if d.res.EU {
+			d.route = 1
+		}
+		if p.policy.allows(d.res.EU, d.res.Uncertain, p.matcher.Country(addr)) {
+			return d, nil
+		}
+		if i == 0 {
+			first = d
+		}
+	}
+	return first, errDenied
+}
+
+func (p *forwardProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hostport := r.Host
+	if r.Method != http.MethodConnect {
+		if !r.URL.IsAbs() || r.URL.Scheme != "http" {
+			http.Error(w, "only CONNECT and absolute http:// requests are proxied", http.StatusBadRequest)
+			return
+		}
+		hostport = r.URL.Host
+		if r.URL.Port() == "" {
+			hostport = net.JoinHostPort(r.URL.Hostname(), "80")
+		}
+	}
+	d, err := p.resolve(r.Context(), hostport)
+	p.logf(r, hostport, d, err)
+	switch {
+	case errors.Is(err, errDenied):
+		http.Error(w, fmt.Sprintf("%s: %s destinations are not allowed", hostport, label(d.res)), http.StatusForbidden)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("X-Destination-EU", strconv.FormatBool(d.res.EU))
+	w.Header().Set("X-Destination-Country", p.matcher.Country(d.addr.Addr()))
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r, d)
+		return
+	}
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			// Send the request to the address that was classified, rather
+			// than letting the transport resolve the name again.
+			pr.Out.URL.Host = d.addr.String()
+			pr.Out.Host = r.URL.Host
+		},
+		Transport: p.transports[d.route],
+	}
+	rp.ServeHTTP(w, r)
+}
+
+// tunnel connects to d and relays bytes between it and the client.
+func (p *forwardProxy) tunnel(w http.ResponseWriter, r *http.Request, d destination) {
+	upstream, br, err := p.connect(r.Context(), d)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+	client, buf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return
+	}
+	defer client.Close()
+	// Write the response by hand: the server would frame an unknown length
+	// as a chunked body, which a CONNECT response mustn't have.
+	fmt.Fprintf(buf, "HTTP/1.1 200 Connection established\r\n")
+	w.Header().Write(buf)
+	if _, err := buf.WriteString("\r\n"); err != nil || buf.Flush() != nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		io.Copy(upstream, buf)
+		if c, ok := upstream.(interface{ CloseWrite() error }); ok {
+			c.CloseWrite()
+		}
+		close(done)
+	}()
+	io.Copy(client, br)
+	client.Close()
+	<-done
+}
+
+// connect opens a connection to d, through its upstream proxy if it has one.
+// Reads should use the returned reader, which may hold data the upstream
+// proxy sent after its response.
+func (p *forwardProxy) connect(ctx context.Context, d destination) (net.Conn, io.Reader, error) {
+	via := p.via[d.route]
+	if via == "" {
+		conn, err := p.dial(ctx, "tcp", d.addr.String())
+		return conn, conn, err
+	}
+	conn, err := p.dial(ctx, "tcp", via)
+	if err != nil {
+		return nil, nil, err
+	}
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: d.addr.String()},
+		Host:   d.addr.String(),
+		Header: http.Header{},
+	}
+	br := bufio.NewReader(conn)
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, nil, fmt.Errorf("proxy %s: %s", via, resp.Status)
+	}
+	return conn, br, nil
+}
+
+func (p *forwardProxy) logf(r *http.Request, hostport string, d destination, err error) {
+	if p.log == nil {
+		return
+	}
+	switch {
+	case errors.Is(err, errDenied):
+		fmt.Fprintf(p.log, "%s %s %s %s %s denied\n", r.Method, hostport, d.addr, label(d.res), p.matcher.Country(d.addr.Addr()))
+	case err != nil:
+		fmt.Fprintf(p.log, "%s %s failed: %v\n", r.Method, hostport, err)
+	default:
+		via := ""
+		if p.via[d.route] != "" {
+			via = " via " + p.via[d.route]
+		}
+		fmt.Fprintf(p.log, "%s %s %s %s %s%s\n", r.Method, hostport, d.addr, label(d.res), p.matcher.Country(d.addr.Addr()), via)
+	}
+}
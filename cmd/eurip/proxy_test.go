@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rmmh/eurip"
+)
+
+// testProxy returns a proxy resolving eu.example to an EU address and
+// us.example to a non-EU one, which dials backend whatever the address.
+func testProxy(deny string, via [2]string, backend string) (*forwardProxy, *[]string) {
+	p := newForwardProxy(eurip.NewMatcher(), denyPolicy(deny), via)
+	p.lookup = func(ctx context.Context, host string) ([]netip.Addr, error) {
+		switch host {
+		case "eu.example":
+			return []netip.Addr{netip.MustParseAddr("2.0.0.1")}, nil
+		case "us.example":
+			return []netip.Addr{netip.MustParseAddr("1.0.0.1")}, nil
+		case "both.example":
+			return []netip.Addr{netip.MustParseAddr("1.0.0.1"), netip.MustParseAddr("2.0.0.1")}, nil
+		}
+		return nil, fmt.Errorf("no such host %s", host)
+	}
+	var dialed []string
+	p.dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		var d net.Dialer
+		return d.DialContext(ctx, network, backend)
+	}
+	return p, &dialed
+}
+
+func TestProxyHTTP(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.Host, r.URL)
+	}))
+	defer backend.Close()
+	p, dialed := testProxy("non-eu", [2]string{}, backend.Listener.Addr().String())
+	ps := httptest.NewServer(p)
+	defer ps.Close()
+	proxyURL, _ := url.Parse(ps.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	for _, tc := range []struct {
+		url, body string
+		code      int
+		dialed    string
+	}{
+		{"http://eu.example/x", "eu.example /x", 200, "2.0.0.1:80"},
+		{"http://both.example:8080/", "both.example:8080 /", 200, "2.0.0.1:8080"},
+		{"http://us.example/", "", 403, ""},
+		{"http://1.0.0.1/", "", 403, ""},
+		{"http://nowhere.example/", "", 502, ""},
+	} {
+		*dialed = nil
+		resp, err := client.Get(tc.url)
+		if err != nil {
+			t.Errorf("GET %s: %v", tc.url, err)
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.code {
+			t.Errorf("GET %s = %d %q, want %d", tc.url, resp.StatusCode, body, tc.code)
+			continue
+		}
+		if tc.code != 200 {
+			continue
+		}
+		if string(body) != tc.body || resp.Header.Get("X-Destination-EU") != "true" {
+			t.Errorf("GET %s = %q, X-Destination-EU %q", tc.url, body, resp.Header.Get("X-Destination-EU"))
+		}
+		if len(*dialed) != 1 || (*dialed)[0] != tc.dialed {
+			t.Errorf("GET %s dialed %v, want %s", tc.url, *dialed, tc.dialed)
+		}
+	}
+}
+
+func TestProxyConnect(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "tunneled")
+	}))
+	defer backend.Close()
+
+	// An upstream proxy for non-EU destinations, which itself allows all.
+	upstream, upstreamDialed := testProxy("", [2]string{}, backend.Listener.Addr().String())
+	us := httptest.NewServer(upstream)
+	defer us.Close()
+	p, dialed := testProxy("eu", [2]string{us.Listener.Addr().String(), ""}, "")
+	p.dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		*dialed = append(*dialed, addr)
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	}
+	ps := httptest.NewServer(p)
+	defer ps.Close()
+
+	connect := func(target string) (*http.Response, *bufio.Reader, net.Conn) {
+		t.Helper()
+		conn, err := net.Dial("tcp", ps.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", target, target)
+		br := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, br, conn
+	}
+
+	resp, _, conn := connect("eu.example:443")
+	conn.Close()
+	if resp.StatusCode != 403 {
+		t.Errorf("CONNECT eu.example:443 = %d, want 403", resp.StatusCode)
+	}
+
+	resp, br, conn := connect("us.example:443")
+	defer conn.Close()
+	if resp.StatusCode != 200 || resp.Header.Get("X-Destination-EU") != "false" {
+		t.Fatalf("CONNECT us.example:443 = %d, X-Destination-EU %q", resp.StatusCode, resp.Header.Get("X-Destination-EU"))
+	}
+	fmt.Fprint(conn, "GET /x HTTP/1.1\r\nHost: us.example\r\nConnection: close\r\n\r\n")
+	tresp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(tresp.Body)
+	if string(body) != "tunneled" {
+		t.Errorf("tunneled response = %q", body)
+	}
+	if want := []string{us.Listener.Addr().String()}; strings.Join(*dialed, ",") != strings.Join(want, ",") {
+		t.Errorf("proxy dialed %v, want %v", *dialed, want)
+	}
+	if strings.Join(*upstreamDialed, ",") != "1.0.0.1:443" {
+		t.Errorf("upstream dialed %v, want 1.0.0.1:443", *upstreamDialed)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rmmh/eurip"
+)
+
+// selftest checks the embedded dataset, or the one in --dataset, with
+// Matcher.SelfTest. It exits 0 if the dataset passes, 1 if it fails, and 2
+// if it can't be loaded.
+func selftest(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("eurip selftest", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dataset := fs.String("dataset", "", "check the dataset in `file`, as written by MarshalBinary, instead of the embedded one")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: eurip selftest [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return statusInvalid
+	}
+
+	m := eurip.NewMatcher()
+	name := "embedded dataset"
+	if *dataset != "" {
+		b, err := os.ReadFile(*dataset)
+		if err != nil {
+			fmt.Fprintf(stderr, "eurip: %v\n", err)
+			return statusInvalid
+		}
+		if m, err = eurip.NewMatcherFromBytes(b); err != nil {
+			fmt.Fprintf(stderr, "eurip: %s: %v\n", *dataset, err)
+			return statusInvalid
+		}
+		name = *dataset
+	}
+	if err := m.SelfTest(); err != nil {
+		for _, err := range unjoin(err) {
+			fmt.Fprintf(stderr, "eurip: %s: %v\n", name, err)
+		}
+		return 1
+	}
+	fmt.Fprintf(stdout, "%s: ok\n", name)
+	return 0
+}
+
+// unjoin returns the errors joined with errors.Join in err, or just err.
+func unjoin(err error) []error {
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		return j.Unwrap()
+	}
+	return []error{err}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rmmh/eurip"
+)
+
+func TestSelftest(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"selftest"}, nil, &stdout, &stderr); status != 0 || stdout.String() != "embedded dataset: ok\n" {
+		t.Errorf("run(selftest) = %d, stdout %q, stderr %q", status, stdout.String(), stderr.String())
+	}
+
+	b, err := eurip.NewMatcher().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	good, bad := filepath.Join(dir, "good.bin"), filepath.Join(dir, "bad.bin")
+	if err := os.WriteFile(good, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bad, b[:len(b)/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	if status := run([]string{"selftest", "--dataset", good}, nil, &stdout, &stderr); status != 0 || !strings.HasSuffix(stdout.String(), "good.bin: ok\n") {
+		t.Errorf("run(selftest --dataset good.bin) = %d, stdout %q, stderr %q", status, stdout.String(), stderr.String())
+	}
+	for _, path := range []string{bad, filepath.Join(dir, "missing.bin")} {
+		stderr.Reset()
+		if status := run([]string{"selftest", "--dataset", path}, nil, &stdout, &stderr); status != statusInvalid || stderr.Len() == 0 {
+			t.Errorf("run(selftest --dataset %s) = %d, stderr %q, want %d with an error", filepath.Base(path), status, stderr.String(), statusInvalid)
+		}
+	}
+}
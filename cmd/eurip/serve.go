@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/rmmh/eurip"
+	"github.com/rmmh/eurip/httpmw"
+)
+
+// serve runs the HTTP server until interrupted.
+func serve(args []string, stderr io.Writer) int {
+	fs := flag.NewFlagSet("eurip serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", "localhost:8080", "listen on this `host:port`")
+	dataset := fs.String("dataset", "", "serve the dataset in `file`, as written by MarshalBinary, rereading it on SIGHUP, instead of the embedded one")
+	maxAge := fs.Duration("max-age", 0, "report unhealthy if the dataset is older than this; 0 disables the check")
+	deny := fs.String("ext-authz-deny", "", "deny `eu` or `non-eu` clients in the Envoy ext_authz endpoint, rather than tagging them")
+	policyFile := fs.String("policy", "", "allow and deny clients in the Envoy ext_authz endpoint by the JSON policy in `file`, reread on SIGHUP")
+	uk := fs.Bool("uk", false, "treat the UK as part of the EU")
+	microstates := fs.Bool("microstates", false, "treat Andorra, Monaco, San Marino, and Vatican City as part of the EU")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: eurip serve [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return statusInvalid
+	}
+	if *deny != "" && *deny != "eu" && *deny != "non-eu" || *deny != "" && *policyFile != "" {
+		fs.Usage()
+		return statusInvalid
+	}
+
+	s := newServer(datasetLoader(*dataset, eurip.WithUKTreatedAsEU(*uk), eurip.WithMicrostatesTreatedAsEU(*microstates)), *maxAge)
+	s.policy.Store(denyPolicy(*deny))
+	s.policyFile = *policyFile
+	if err := s.reload(); err != nil {
+		fmt.Fprintf(stderr, "eurip: %v\n", err)
+		return statusInvalid
+	}
+	warnTables(s.matcher.Load(), stderr)
+	// No read or write timeouts: /check/stream requests last as long as
+	// their clients keep sending batches.
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGHUP {
+				if err := s.reload(); err != nil {
+					fmt.Fprintf(stderr, "eurip: reload failed, still serving the old dataset: %v\n", err)
+				} else {
+					warnTables(s.matcher.Load(), stderr)
+				}
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			srv.Shutdown(ctx)
+			cancel()
+			return
+		}
+	}()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(stderr, "eurip: %v\n", err)
+		return statusInvalid
+	}
+	return 0
+}
+
+// datasetLoader returns a function loading a Matcher with opts over the
+// dataset in path, read afresh on each call, or over the embedded dataset if
+// path is empty.
+func datasetLoader(path string, opts ...eurip.Option) func() (*eurip.Matcher, error) {
+	return func() (*eurip.Matcher, error) {
+		if path == "" {
+			return eurip.NewMatcher(opts...), nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return eurip.NewMatcherFromBytes(b, opts...)
+	}
+}
+
+// A server answers lookups over HTTP. Reloading swaps in a new Matcher
+// atomically, so requests in flight finish with the old one.
+type server struct {
+	matcher atomic.Pointer[eurip.Matcher]
+	load    func() (*eurip.Matcher, error)
+	maxAge  time.Duration
+	mux     *http.ServeMux
+	// policy decides which clients extAuthz allows; nil allows all. If
+	// policyFile is set, reloading rereads it from there.
+	policy     atomic.Pointer[policy]
+	policyFile string
+
+	lookups    [3]atomic.Uint64 // by status
+	reloads    [2]atomic.Uint64 // failed, succeeded
+	lastReload atomic.Int64     // Unix time of the last successful reload
+}
+
+func newServer(load func() (*eurip.Matcher, error), maxAge time.Duration) *server {
+	s := &server{load: load, maxAge: maxAge, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/check", s.check)
+	s.mux.HandleFunc("/check/stream", s.checkStream)
+	s.mux.HandleFunc("/ext_authz/", s.extAuthz)
+	s.mux.HandleFunc("/healthz", s.healthz)
+	s.mux.HandleFunc("/metrics", s.metrics)
+	return s
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// reload replaces the Matcher and policy, keeping the old ones if loading
+// either fails or the Matcher's dataset doesn't validate. Only validated
+// Matchers are stored, so healthz needn't validate them again.
+func (s *server) reload() error {
+	var p *policy
+	m, err := s.load()
+	if err == nil {
+		err = m.Validate()
+	}
+	if err == nil && s.policyFile != "" {
+		p, err = readPolicy(s.policyFile)
+	}
+	if err != nil {
+		s.reloads[0].Add(1)
+		return err
+	}
+	s.matcher.Store(m)
+	if s.policyFile != "" {
+		s.policy.Store(p)
+	}
+	s.reloads[1].Add(1)
+	s.lastReload.Store(time.Now().Unix())
+	return nil
+}
+
+// check classifies the address in the ip parameter, or else the client's.
+func (s *server) check(w http.ResponseWriter, r *http.Request) {
+	m := s.matcher.Load()
+	var addr netip.Addr
+	var err error
+	if ip := r.FormValue("ip"); ip != "" {
+		addr, err = netip.ParseAddr(ip)
+	} else {
+		opts := httpmw.Options{Matcher: m}
+		addr, err = opts.ClientAddr(r)
+	}
+	if err != nil {
+		s.lookups[statusInvalid].Add(1)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.lookup(m, addr))
+}
+
+func (s *server) lookup(m *eurip.Matcher, addr netip.Addr) result {
+	res := m.Lookup(addr)
+	if res.EU {
+		s.lookups[statusEU].Add(1)
+	} else {
+		s.lookups[statusNonEU].Add(1)
+	}
+	return newResult(m, addr, res, datasetDate(m))
+}
+
+// checkStream classifies batches of addresses over one long-lived request,
+// to amortize per-request overhead. Each line of the POSTed body is a batch:
+// a JSON array of address strings. Each is answered, as soon as it is read,
+// by a line holding a JSON array of the --json objects for its addresses.
+// A line that isn't an array of strings ends the stream with an
+// {"error": ...} line.
+func (s *server) checkStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "POST batches of addresses", http.StatusMethodNotAllowed)
+		return
+	}
+	rc := http.NewResponseController(w)
+	// Let clients send further batches while reading results. HTTP/1
+	// servers otherwise stop reading the body once writing starts.
+	rc.EnableFullDuplex()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	m := s.matcher.Load()
+	dec := json.NewDecoder(r.Body)
+	enc := json.NewEncoder(w)
+	for {
+		var batch []string
+		if err := dec.Decode(&batch); err == io.EOF {
+			return
+		} else if err != nil {
+			s.lookups[statusInvalid].Add(1)
+			enc.Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		results := make([]result, len(batch))
+		for i, ip := range batch {
+			addr, err := netip.ParseAddr(ip)
+			if err != nil {
+				s.lookups[statusInvalid].Add(1)
+				results[i] = result{IP: ip, Dataset: datasetDate(m), Error: err.Error()}
+				continue
+			}
+			results[i] = s.lookup(m, addr)
+		}
+		if enc.Encode(results) != nil || rc.Flush() != nil {
+			return
+		}
+	}
+}
+
+// healthz reports whether reload has stored a valid dataset and, if
+// --max-age is set, whether it is fresh enough.
+func (s *server) healthz(w http.ResponseWriter, r *http.Request) {
+	m := s.matcher.Load()
+	err := errors.New("no dataset loaded")
+	if m != nil {
+		err = nil
+		if s.maxAge > 0 {
+			err = m.CheckFreshness(s.maxAge)
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintf(w, "ok %s\n", datasetDate(m))
+}
+
+// metrics writes counters in the Prometheus text exposition format.
+func (s *server) metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# HELP eurip_lookups_total Lookups served, by result.")
+	fmt.Fprintln(w, "# TYPE eurip_lookups_total counter")
+	for status, name := range []string{"eu", "non_eu", "invalid"} {
+		fmt.Fprintf(w, "eurip_lookups_total{result=%q} %d\n", name, s.lookups[status].Load())
+	}
+	fmt.Fprintln(w, "# HELP eurip_reloads_total Dataset reloads, by outcome.")
+	fmt.Fprintln(w, "# TYPE eurip_reloads_total counter")
+	fmt.Fprintf(w, "eurip_reloads_total{result=\"failure\"} %d\n", s.reloads[0].Load())
+	fmt.Fprintf(w, "eurip_reloads_total{result=\"success\"} %d\n", s.reloads[1].Load())
+	fmt.Fprintln(w, "# HELP eurip_last_reload_timestamp_seconds When the dataset was last loaded.")
+	fmt.Fprintln(w, "# TYPE eurip_last_reload_timestamp_seconds gauge")
+	fmt.Fprintf(w, "eurip_last_reload_timestamp_seconds %d\n", s.lastReload.Load())
+	fmt.Fprintln(w, "# HELP eurip_dataset_info The loaded GeoLite2 dataset version.")
+	fmt.Fprintln(w, "# TYPE eurip_dataset_info gauge")
+	m := s.matcher.Load()
+	if m == nil {
+		return
+	}
+	fmt.Fprintf(w, "eurip_dataset_info{version=%q} 1\n", m.Version())
+	if t, err := time.Parse("20060102", m.Version()); err == nil {
+		fmt.Fprintln(w, "# HELP eurip_dataset_age_seconds Time since the dataset was published.")
+		fmt.Fprintln(w, "# TYPE eurip_dataset_age_seconds gauge")
+		fmt.Fprintf(w, "eurip_dataset_age_seconds %.0f\n", time.Since(t).Seconds())
+	}
+}
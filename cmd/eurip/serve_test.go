@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmmh/eurip"
+)
+
+func get(t *testing.T, h http.Handler, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest("GET", target, nil)
+	r.RemoteAddr = "2.0.0.1:1234"
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestServerCheck(t *testing.T) {
+	s := newServer(func() (*eurip.Matcher, error) { return eurip.NewMatcher(), nil }, 0)
+	if err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		target string
+		code   int
+		ip     string
+		eu     bool
+	}{
+		{"/check?ip=1.0.0.1", 200, "1.0.0.1", false},
+		{"/check", 200, "2.0.0.1", true},
+		{"/check?ip=bogus", 400, "", false},
+	} {
+		w := get(t, s, tc.target)
+		if w.Code != tc.code {
+			t.Errorf("GET %s = %d, want %d", tc.target, w.Code, tc.code)
+			continue
+		}
+		if tc.code != 200 {
+			continue
+		}
+		var res result
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || res.IP != tc.ip || res.EU != tc.eu {
+			t.Errorf("GET %s = %+v, %v", tc.target, res, err)
+		}
+	}
+
+	body := get(t, s, "/metrics").Body.String()
+	for _, want := range []string{
+		`eurip_lookups_total{result="eu"} 1`,
+		`eurip_lookups_total{result="non_eu"} 1`,
+		`eurip_lookups_total{result="invalid"} 1`,
+		`eurip_reloads_total{result="success"} 1`,
+		`eurip_dataset_info{version="` + eurip.Version + `"} 1`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("GET /metrics lacks %s:\n%s", want, body)
+		}
+	}
+}
+
+func TestServerHealthAndReload(t *testing.T) {
+	var loadErr error
+	loads := 0
+	s := newServer(func() (*eurip.Matcher, error) {
+		loads++
+		return eurip.NewMatcher(), loadErr
+	}, 0)
+	if w := get(t, s, "/healthz"); w.Code != 503 {
+		t.Errorf("GET /healthz before loading = %d, want 503", w.Code)
+	}
+	if err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if w := get(t, s, "/healthz"); w.Code != 200 {
+		t.Errorf("GET /healthz = %d, %q", w.Code, w.Body.String())
+	}
+	old := s.matcher.Load()
+	loadErr = errors.New("broken")
+	if err := s.reload(); err == nil || s.matcher.Load() != old {
+		t.Errorf("failed reload = %v, replaced the Matcher: %v", err, s.matcher.Load() != old)
+	}
+	if body := get(t, s, "/metrics").Body.String(); !strings.Contains(body, `eurip_reloads_total{result="failure"} 1`) {
+		t.Errorf("GET /metrics doesn't count the failed reload:\n%s", body)
+	}
+
+	s.maxAge = 24 * time.Hour
+	if w := get(t, s, "/healthz"); w.Code != 503 || !strings.Contains(w.Body.String(), "stale") {
+		t.Errorf("GET /healthz with stale dataset = %d, %q", w.Code, w.Body.String())
+	}
+}
+
+func TestServerDatasetReload(t *testing.T) {
+	b, err := eurip.NewMatcher().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "eurip.dataset")
+	s := newServer(datasetLoader(path), 0)
+	if err := s.reload(); err == nil {
+		t.Error("reload of a missing dataset succeeded")
+	}
+	for _, version := range []string{"20240101", "20240201"} {
+		copy(b[8:16], version)
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.reload(); err != nil {
+			t.Fatal(err)
+		}
+		var res result
+		if err := json.Unmarshal(get(t, s, "/check?ip=2.0.0.1").Body.Bytes(), &res); err != nil || !res.EU {
+			t.Errorf("GET /check after loading %s = %+v, %v", version, res, err)
+		}
+		if date := version[:4] + "-" + version[4:6] + "-" + version[6:]; res.Dataset != date {
+			t.Errorf("GET /check after loading %s has dataset %q, want %q", version, res.Dataset, date)
+		}
+		if body := get(t, s, "/metrics").Body.String(); !strings.Contains(body, `eurip_dataset_info{version="`+version+`"} 1`) {
+			t.Errorf("GET /metrics after loading %s lacks its version:\n%s", version, body)
+		}
+	}
+
+	if err := os.WriteFile(path, b[:len(b)/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.reload(); err == nil || s.matcher.Load().Version() != "20240201" {
+		t.Errorf("reload of a truncated dataset = %v, left version %s", err, s.matcher.Load().Version())
+	}
+}
+
+// hasIPv6 is whether the embedded dataset has IPv6 tables, which the
+// eurip_nov6 build tag leaves out.
+var hasIPv6 = slices.Contains(eurip.NewMatcher().Families(), eurip.IPv6)
+
+func TestServerCheckStream(t *testing.T) {
+	s := newServer(func() (*eurip.Matcher, error) { return eurip.NewMatcher(), nil }, 0)
+	if err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	// Send each batch only after the previous one is answered.
+	pr, pw := io.Pipe()
+	go io.WriteString(pw, `["2.0.0.1", "1.0.0.1"]`+"\n")
+	resp, err := http.Post(ts.URL+"/check/stream", "application/json", pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	var got []result
+	if err := dec.Decode(&got); err != nil || len(got) != 2 || !got[0].EU || got[1].EU {
+		t.Fatalf("first batch = %+v, %v", got, err)
+	}
+	go func() {
+		io.WriteString(pw, `["bogus", "2001:420:4000:1::"]`+"\n")
+		pw.Close()
+	}()
+	if err := dec.Decode(&got); err != nil || len(got) != 2 || got[0].Error == "" || got[1].EU != hasIPv6 {
+		t.Fatalf("second batch = %+v, %v", got, err)
+	}
+	if err := dec.Decode(&got); err != io.EOF {
+		t.Errorf("after last batch, Decode() = %v, want EOF", err)
+	}
+
+	resp, err = http.Post(ts.URL+"/check/stream", "application/json", strings.NewReader(`["2.0.0.1"] {"ip": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if lines := strings.Split(strings.TrimSpace(string(body)), "\n"); len(lines) != 2 || !strings.Contains(lines[1], `"error"`) {
+		t.Errorf("stream with malformed batch = %q", body)
+	}
+}
+
+func TestServerExtAuthz(t *testing.T) {
+	s := newServer(func() (*eurip.Matcher, error) { return eurip.NewMatcher(), nil }, 0)
+	if err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		deny    string
+		headers map[string]string
+		code    int
+		eu      string
+	}{
+		{"", map[string]string{"X-Envoy-External-Address": "2.0.0.1"}, 200, "true"},
+		{"", map[string]string{"X-Forwarded-For": "2.0.0.1, 1.0.0.1"}, 200, "false"},
+		{"", map[string]string{"X-Forwarded-For": "1.0.0.1,2.0.0.1"}, 200, "true"},
+		{"", nil, 403, ""},
+		{"eu", map[string]string{"X-Envoy-External-Address": "2.0.0.1"}, 403, ""},
+		{"eu", map[string]string{"X-Envoy-External-Address": "1.0.0.1"}, 200, "false"},
+		{"non-eu", map[string]string{"X-Envoy-External-Address": "1.0.0.1"}, 403, ""},
+		{"non-eu", map[string]string{"X-Envoy-External-Address": "::ffff:2.0.0.1"}, 200, "true"},
+	} {
+		s.policy.Store(denyPolicy(tc.deny))
+		r := httptest.NewRequest("GET", "/ext_authz/some/path?q=1", nil)
+		for k, v := range tc.headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != tc.code || w.Header().Get("X-Client-EU") != tc.eu {
+			t.Errorf("deny %q, headers %v: got %d, x-client-eu %q, want %d, %q",
+				tc.deny, tc.headers, w.Code, w.Header().Get("X-Client-EU"), tc.code, tc.eu)
+		}
+	}
+}
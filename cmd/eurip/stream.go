@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"sync"
+)
+
+// batchSize is the number of records a worker classifies at a time.
+const batchSize = 512
+
+// A batch is a run of input records, classified by one worker.
+type batch struct {
+	records [][]string
+	done    chan struct{}
+	out     bytes.Buffer
+	status  int
+}
+
+// stream classifies every record of r on workers goroutines, writing the
+// output to w in input order, and returns the combined status.
+func (c *classifier) stream(r io.Reader, w io.Writer, workers int, header bool) (int, error) {
+	todo := make(chan *batch)
+	// ordered holds batches in input order, bounding how far workers can
+	// run ahead of the writer.
+	ordered := make(chan *batch, 2*workers)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range todo {
+				for _, rec := range b.records {
+					st, _ := c.classify(&b.out, rec)
+					b.status = max(b.status, st)
+				}
+				close(b.done)
+			}
+		}()
+	}
+
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(ordered)
+		defer close(todo)
+		next := c.reader(r)
+		b := &batch{done: make(chan struct{})}
+		flush := func() {
+			ordered <- b
+			todo <- b
+			b = &batch{done: make(chan struct{})}
+		}
+		if header && c.column > 0 {
+			rec, err := next()
+			if err != nil && err != io.EOF {
+				readErr <- err
+				return
+			}
+			if err == nil {
+				// Pass the header through as a finished batch.
+				if !c.quiet && !c.json {
+					writeCSV(&b.out, append(rec, "is_eu", "country"))
+				}
+				close(b.done)
+				ordered <- b
+				b = &batch{done: make(chan struct{})}
+			}
+		}
+		for {
+			rec, err := next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+			if b.records = append(b.records, rec); len(b.records) == batchSize {
+				flush()
+			}
+		}
+		if len(b.records) > 0 {
+			flush()
+		}
+		readErr <- nil
+	}()
+
+	bw := bufio.NewWriter(w)
+	status := statusEU
+	var writeErr error
+	for b := range ordered {
+		<-b.done
+		if writeErr == nil {
+			_, writeErr = bw.Write(b.out.Bytes())
+		}
+		status = max(status, b.status)
+	}
+	wg.Wait()
+	if err := <-readErr; err != nil {
+		return statusInvalid, err
+	}
+	if writeErr != nil {
+		return statusInvalid, writeErr
+	}
+	return status, bw.Flush()
+}
+
+// reader returns a function reading the next input record from r: a CSV
+// record in --column mode, or else one line. Blank lines are skipped.
+func (c *classifier) reader(r io.Reader) func() ([]string, error) {
+	if c.column > 0 {
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		return cr.Read
+	}
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	return func() ([]string, error) {
+		for s.Scan() {
+			if line := bytes.TrimSpace(s.Bytes()); len(line) > 0 {
+				return []string{string(line)}, nil
+			}
+		}
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+}
@@ -4,55 +4,206 @@ codegen.py outputs embedded datafiles for various languages (...just Go, current
 """
 
 import argparse
+import json
+import os
 import struct
 
 def unpack(btr):
 	"""Returns the list of shorts for the given datafile."""
 	return struct.unpack('<%dH' % (len(btr) // 2), btr)
 
-def emit_go(v4, v6, version):
-	data_tmpl = '''package eurip
+def unpack32(vtr):
+	"""Returns the list of 32-bit words for the given value DAG datafile."""
+	return struct.unpack('<%dI' % (len(vtr) // 4), vtr)
 
-// Autogenerated data file. Modify codegen.py for changes.
-// This file includes GeoLite2 data created by MaxMind, available from
-// http://www.maxmind.com.
-
-// The GeoLite2 database version this was generated from (updates monthly).
-const Version = "{version}"
-
-var v4Data = []uint16{{
-{v4_lines}}}
-
-var v6Data = []uint16{{
-{v6_lines}}}
+def go_decls(decls):
+	"""Renders a list of (Go variable name, element type, values) as slices."""
+	decl_tmpl = '''
+var {name} = []{typ}{{
+{lines}}}
 '''
 
 	def to_lines(xs):
 		out = ''
-		line = ''
 		for n in range(0, len(xs), 16):
 			out += '\t' + ', '.join(str(x) for x in xs[n:n + 16]) + ',\n'
 		return out
 
-	v4_lines = to_lines(unpack(v4))
-	v6_lines = to_lines(unpack(v6))
+	output = ''
+	for name, typ, values in decls:
+		if not values:
+			output += '\nvar {name} = []{typ}{{}}\n'.format(name=name, typ=typ)
+			continue
+		if typ == 'string':
+			lines = ''.join('\t%s,\n' % json.dumps(v, ensure_ascii=False) for v in values)
+		else:
+			lines = to_lines(values)
+		output += decl_tmpl.format(name=name, typ=typ, lines=lines)
+	return output
+
+def source_comment(name):
+	"""
+	Returns a comment giving the digest process.py recorded in sources.txt for
+	the source database name, or nothing if it wasn't recorded.
+	"""
+	for line in read_lines('sources.txt'):
+		digest, source = line.split()
+		if source == name:
+			return '// Generated from %s, SHA-256 %s.\n' % (source, digest)
+	return ''
+
+def emit_go(decls, version):
+	"""Writes data.go, given a list of (Go variable name, element type, values)."""
+	header_tmpl = '''package eurip
+
+// Autogenerated data file. Modify codegen.py for changes.
+// This file includes GeoLite2 data created by MaxMind, available from
+// http://www.maxmind.com.
+{source}
+// The GeoLite2 database version this was generated from (updates monthly).
+const Version = "{version}"
+'''
 
-	output = data_tmpl.format(version=version, v4_lines=v4_lines, v6_lines=v6_lines)
+	source = source_comment('GeoLite2-Country-CSV.zip')
+	output = header_tmpl.format(version=version, source=source) + go_decls(decls)
 
 	with open('data.go', 'w') as f:
 		f.write(output)
 
+# Embedded bitset DAGs: Go variable name, datafile, and whether it must exist.
+# Optional tables that haven't been generated are emitted empty.
+TABLES = [
+	('v4Data', 'euro_v4.btr', True),
+	('v6Data', 'euro_v6.btr', True),
+	('gbV4Data', 'euro_gb_v4.btr', False),
+	('gbV6Data', 'euro_gb_v6.btr', False),
+	('microV4Data', 'euro_micro_v4.btr', False),
+	('microV6Data', 'euro_micro_v6.btr', False),
+	('uncertainV4Data', 'uncertain_v4.btr', False),
+	('uncertainV6Data', 'uncertain_v6.btr', False),
+	('knownV4Data', 'known_v4.btr', False),
+	('knownV6Data', 'known_v6.btr', False),
+	('datacenterV4Data', 'datacenter_v4.btr', False),
+	('datacenterV6Data', 'datacenter_v6.btr', False),
+]
+
+# Embedded value DAGs, which are all optional.
+VALUE_TABLES = [
+	('countryV4Data', 'country_v4.vtr'),
+	('countryV6Data', 'country_v6.vtr'),
+]
+
+# A bitset DAG with a root node and no children: an empty set.
+EMPTY_BTR = bytes(4)
+
+# A value DAG with a root node and no children: an empty map.
+EMPTY_VTR = bytes(4)
+
+def read_table(path, required, empty=EMPTY_BTR):
+	try:
+		return open(path, 'rb').read()
+	except IOError:
+		if required:
+			raise
+		return empty
+
+def read_lines(path):
+	"""Returns the lines of an optional text datafile."""
+	try:
+		return [l.rstrip('\n') for l in open(path)]
+	except IOError:
+		return []
+
+def emit_go_tagged(path, tag, source, decls):
+	"""
+	Writes an optional data file that is only built with the given tag,
+	generated from the source database.
+	"""
+	tmpl = '''//go:build {tag}
+
+package eurip
+
+// Autogenerated data file. Modify codegen.py for changes.
+// This file includes GeoLite2 data created by MaxMind, available from
+// http://www.maxmind.com.
+{source}'''
+
+	with open(path, 'w') as f:
+		f.write(tmpl.format(tag=tag, source=source_comment(source)) + go_decls(decls))
+
+def emit_go_asn():
+	names = [l.split('\t', 1) for l in read_lines('asn_names.tsv')]
+	emit_go_tagged('asn_data.go', 'eurip_asn', 'GeoLite2-ASN-CSV.zip', [
+		('asnV4Data', 'uint32', unpack32(open('asn_v4.vtr', 'rb').read())),
+		('asnV6Data', 'uint32', unpack32(open('asn_v6.vtr', 'rb').read())),
+		('asnNumbers', 'uint32', [int(n) for n, _ in names]),
+		('asnNames', 'string', [name for _, name in names]),
+	])
+
+def emit_go_subdivisions():
+	emit_go_tagged('subdivision_data.go', 'eurip_subdivisions', 'GeoLite2-City-CSV.zip', [
+		('subdivisionV4Data', 'uint32', unpack32(open('subdivision_v4.vtr', 'rb').read())),
+		('subdivisionV6Data', 'uint32', unpack32(open('subdivision_v6.vtr', 'rb').read())),
+		('subdivisionCodes', 'string', read_lines('subdivision_codes.txt')),
+	])
+
+# Section ids of the serialized dataset format, by Go variable name. See
+# dataset.go for the layout.
+SECTIONS = {
+	'v4Data': 1, 'v6Data': 2,
+	'gbV4Data': 3, 'gbV6Data': 4,
+	'microV4Data': 5, 'microV6Data': 6,
+	'uncertainV4Data': 7, 'uncertainV6Data': 8,
+	'knownV4Data': 9, 'knownV6Data': 10,
+	'countryV4Data': 11, 'countryV6Data': 12,
+	'countryCodes': 13,
+	'datacenterV4Data': 14, 'datacenterV6Data': 15,
+}
+
+def emit_bin(decls, version, path='eurip.dataset'):
+	"""Writes the serialized dataset, for eurip.NewMatcherFromBytes."""
+	sections = []
+	for name, typ, values in decls:
+		if typ == 'string':
+			data = ''.join(v + '\n' for v in values).encode('utf8')
+		else:
+			data = struct.pack('<%d%s' % (len(values), 'H' if typ == 'uint16' else 'I'), *values)
+		sections.append((SECTIONS[name], data))
+	header = struct.pack('<8s16sI', b'EURIPDS\x01', version.encode('ascii'), len(sections))
+	off = len(header) + 12 * len(sections)
+	index = b''
+	body = b''
+	for sid, data in sections:
+		pad = -off % 4
+		off += pad
+		body += bytes(pad) + data
+		index += struct.pack('<III', sid, off, len(data))
+		off += len(data)
+	with open(path, 'wb') as f:
+		f.write(header + index + body)
+
 def main():
 	parser = argparse.ArgumentParser()
 	parser.add_argument('--go', action='store_true')
+	parser.add_argument('--bin', action='store_true',
+		help='also write eurip.dataset, for eurip.NewMatcherFromBytes')
 	options = parser.parse_args()
 
 	version = open('version.txt').read().strip()
-	v4 = open('euro_v4.btr', 'rb').read()
-	v6 = open('euro_v6.btr', 'rb').read()
+	decls = [(name, 'uint16', unpack(read_table(path, required)))
+		for name, path, required in TABLES]
+	decls += [(name, 'uint32', unpack32(read_table(path, False, EMPTY_VTR)))
+		for name, path in VALUE_TABLES]
+	decls.append(('countryCodes', 'string', read_lines('country_codes.txt')))
 
 	if options.go:
-		emit_go(v4, v6, version)
+		emit_go(decls, version)
+		if os.path.exists('asn_v4.vtr'):
+			emit_go_asn()
+		if os.path.exists('subdivision_v4.vtr'):
+			emit_go_subdivisions()
+	if options.bin:
+		emit_bin(decls, version)
 
 if __name__ == '__main__':
 	main()
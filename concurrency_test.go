@@ -0,0 +1,70 @@
+package eurip
+
+import (
+	"context"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConcurrentReload looks addresses up while other goroutines refresh the
+// dataset back and forth and change overrides and the Tor exit list. Run it
+// with -race.
+func TestConcurrentReload(t *testing.T) {
+	refreshed := refreshedDataset(t)
+	original, err := NewMatcher().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMatcher()
+	a, b := netip.MustParseAddr("44.0.0.1"), netip.MustParseAddr("2.0.0.1")
+	pinned := netip.MustParsePrefix("46.0.0.0/8")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for i := 0; ctx.Err() == nil; i++ {
+			data := original
+			if i%2 == 0 {
+				data = refreshed
+			}
+			if err := m.Refresh(ctx, func(context.Context) ([]byte, error) { return data, nil }); err != nil && ctx.Err() == nil {
+				t.Error(err)
+			}
+		}
+	})
+	wg.Go(func() {
+		for i := 0; ctx.Err() == nil; i++ {
+			m.SetOverride(pinned, Result{EU: i%2 == 0})
+			m.DeleteOverride(pinned)
+			m.LoadTorExits(strings.NewReader("46.0.0.1\n"))
+		}
+	})
+	for range 4 {
+		wg.Go(func() {
+			for ctx.Err() == nil {
+				// The refreshed dataset swaps which of a and b is EU, so a
+				// view holding both or neither mixes datasets.
+				v := m.load()
+				if v.isEU(a) == v.isEU(b) {
+					t.Error("view mixes datasets")
+					return
+				}
+				m.Lookup(a)
+				m.IsFromEU(net.IP(b.AsSlice()))
+				m.Explain(netip.MustParseAddr("46.0.0.1"))
+				m.IsTorExit(netip.MustParseAddr("46.0.0.1"))
+				m.RandomEUAddr(rand.NewPCG(1, 2))
+				m.AppendEUPrefixes(nil, netip.MustParsePrefix("44.0.0.0/8"))
+				for range m.Overrides() {
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
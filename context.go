@@ -0,0 +1,18 @@
+package eurip
+
+import "context"
+
+// contextKey is the type of the context key NewContext stores Results under.
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying r, so code handling a request can
+// read a decision made once, say by middleware, with FromContext.
+func NewContext(ctx context.Context, r Result) context.Context {
+	return context.WithValue(ctx, contextKey{}, r)
+}
+
+// FromContext returns the Result stored in ctx by NewContext, if any.
+func FromContext(ctx context.Context) (Result, bool) {
+	r, ok := ctx.Value(contextKey{}).(Result)
+	return r, ok
+}
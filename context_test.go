@@ -0,0 +1,16 @@
+package eurip
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	if r, ok := FromContext(context.Background()); ok {
+		t.Errorf("FromContext(Background) = %+v, true", r)
+	}
+	want := Result{EU: true, Uncertain: true}
+	if r, ok := FromContext(NewContext(context.Background(), want)); !ok || r != want {
+		t.Errorf("FromContext(NewContext(%+v)) = %+v, %v", want, r, ok)
+	}
+}
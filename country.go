@@ -0,0 +1,120 @@
+package eurip
+
+import (
+	"iter"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// The countries the UK and microstate tables are built from, as in
+// process.py. The EU table is built from EUMembers.
+var (
+	ukCountries         = map[string]bool{"GB": true}
+	microstateCountries = map[string]bool{"AD": true, "MC": true, "SM": true, "VA": true}
+)
+
+// countsAsEU reports whether m counts the country with ISO code c as EU.
+func (m *Matcher) countsAsEU(c string) bool {
+	return EUMembers.m[c] || m.uk && ukCountries[c] || m.microstates && microstateCountries[c]
+}
+
+// CountryResult returns the Result for a client known to be in the country
+// with the given ISO 3166-1 alpha-2 code, such as a user's self-declared
+// billing country, rather than located by address. It is EU if m counts the
+// country as EU, and has Provenance SourceRequest.
+func (m *Matcher) CountryResult(iso string) Result {
+	return Result{EU: m.countsAsEU(strings.ToUpper(iso)), Provenance: SourceRequest}
+}
+
+// countryTable maps addresses to countries, as value DAGs holding indexes
+// into codes.
+type countryTable struct {
+	v4, v6 []uint32
+	codes  []string
+}
+
+func (t countryTable) lookup(addr netip.Addr) string {
+	v, ok := lookupValue(addr, t.v4, t.v6)
+	if !ok || int(v) >= len(t.codes) {
+		return ""
+	}
+	return t.codes[v]
+}
+
+// Country returns the ISO 3166-1 alpha-2 code of the country addr is in, or
+// "" if it is unknown. Country data only covers the countries the EU tables
+// are built from: EU members, the UK, and the microstates. The embedded
+// dataset has no country data, so against that Country always returns "".
+func Country(addr netip.Addr) string {
+	return defaultMatcher.Country(addr)
+}
+
+// Country is like the package-level Country, but uses m's dataset.
+func (m *Matcher) Country(addr netip.Addr) string {
+	return m.load().countries.lookup(addr)
+}
+
+// PrefixesForCountry returns the prefixes located in the country with the
+// given ISO 3166-1 alpha-2 code, IPv4 first and then IPv6, in address order.
+// It yields nothing for countries without country data, which is every
+// country in the embedded dataset.
+func PrefixesForCountry(iso string) iter.Seq[netip.Prefix] {
+	return defaultMatcher.PrefixesForCountry(iso)
+}
+
+// PrefixesForCountry is like the package-level PrefixesForCountry, but uses
+// m's dataset.
+func (m *Matcher) PrefixesForCountry(iso string) iter.Seq[netip.Prefix] {
+	iso = strings.ToUpper(iso)
+	return func(yield func(netip.Prefix) bool) {
+		countries := m.load().countries
+		for i, code := range countries.codes {
+			if code != iso {
+				continue
+			}
+			want := uint32(i)
+			match := func(p netip.Prefix, v uint32) bool {
+				return v != want || yield(p)
+			}
+			if valuePrefixes(countries.v4, netip.PrefixFrom(netip.IPv4Unspecified(), 0), match) {
+				valuePrefixes(countries.v6, netip.PrefixFrom(netip.IPv6Unspecified(), 0), match)
+			}
+			return
+		}
+	}
+}
+
+// IsFromEUWithCountry is like IsFromEU, but also returns the country, as
+// Country does, from a single table walk when the dataset has country data.
+func IsFromEUWithCountry(ipAddress net.IP) (bool, string) {
+	return defaultMatcher.IsFromEUWithCountry(ipAddress)
+}
+
+// IsFromEUWithCountry is like the package-level IsFromEUWithCountry, but uses
+// m's view of the dataset.
+//
+// The country table is built from the same rows as the EU tables, so the
+// country code stored on a leaf decides EU too. Without country data, it
+// falls back to the EU tables and returns "".
+func (m *Matcher) IsFromEUWithCountry(ipAddress net.IP) (bool, string) {
+	addr, ok := netip.AddrFromSlice(ipAddress)
+	addr = addr.Unmap()
+	v := m.load()
+	if _, r, ok := m.override(addr); ok {
+		m.observe(addr, r)
+		return r.EU, v.countries.lookup(addr)
+	}
+	if !ok || isSpecialUse(addr) || len(v.countries.codes) == 0 {
+		return m.IsFromEU(ipAddress), ""
+	}
+	var eu bool
+	var country string
+	if i, ok := lookupValue(addr, v.countries.v4, v.countries.v6); ok && int(i) < len(v.countries.codes) {
+		eu, country = v.countryEU[i], v.countries.codes[i]
+	}
+	if m.feedback != nil || m.vars != nil {
+		m.observe(addr, Result{EU: eu, Uncertain: v.uncertain.contains(addr)})
+	}
+	return eu, country
+}
@@ -0,0 +1,175 @@
+package eurip
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func withCountries(t *testing.T, v4, v6 map[string]uint32, codes ...string) {
+	oldV4, oldV6, oldCodes := countryV4Data, countryV6Data, countryCodes
+	t.Cleanup(func() { countryV4Data, countryV6Data, countryCodes = oldV4, oldV6, oldCodes })
+	countryV4Data, countryV6Data, countryCodes = buildValueTable(v4), buildValueTable(v6), codes
+}
+
+func TestCountry(t *testing.T) {
+	withCountries(t,
+		map[string]uint32{"2.0.0.0/13": 0, "2.8.0.0/13": 1, "81.2.69.0/24": 2},
+		map[string]uint32{"2001:420:4000::/38": 1},
+		"DE", "FR", "GB")
+	m := NewMatcher()
+	for _, tc := range []struct{ ip, country string }{
+		{"2.0.0.1", "DE"},
+		{"2.9.0.1", "FR"},
+		{"::ffff:2.9.0.1", "FR"},
+		{"81.2.69.142", "GB"},
+		{"2001:420:4000:1::", "FR"},
+		{"1.0.0.1", ""},
+	} {
+		if skipV6(tc.ip) {
+			continue
+		}
+		if got := m.Country(netip.MustParseAddr(tc.ip)); got != tc.country {
+			t.Errorf("Country(%s) = %q, want %q", tc.ip, got, tc.country)
+		}
+	}
+
+	for _, tc := range []struct {
+		iso  string
+		want []string
+	}{
+		{"DE", []string{"2.0.0.0/13"}},
+		{"fr", []string{"2.8.0.0/13", "2001:420:4000::/38"}},
+		{"GB", []string{"81.2.69.0/24"}},
+		{"IT", nil},
+	} {
+		tc.want = slices.DeleteFunc(tc.want, skipV6)
+		var got []string
+		for p := range m.PrefixesForCountry(tc.iso) {
+			got = append(got, p.String())
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("PrefixesForCountry(%q) = %v, want %v", tc.iso, got, tc.want)
+		}
+	}
+	for range m.PrefixesForCountry("FR") {
+		break // stopping early must not panic
+	}
+}
+
+func TestAppendRangesCountries(t *testing.T) {
+	withCountries(t,
+		map[string]uint32{"2.0.0.0/13": 0, "2.8.0.0/13": 1, "81.2.69.0/24": 2},
+		nil,
+		"DE", "FR", "GB")
+	var got []Range
+	for _, r := range NewMatcher().AppendRanges(nil) {
+		if r.Start.Less(netip.MustParseAddr("2.0.0.0")) || netip.MustParseAddr("2.16.6.0").Less(r.Start) {
+			if r.Country != "" && r.Country != "GB" {
+				t.Errorf("unexpected country range %+v", r)
+			}
+			continue
+		}
+		got = append(got, r)
+	}
+	want := []Range{
+		{netip.MustParseAddr("2.0.0.0"), netip.MustParseAddr("2.7.255.255"), true, "DE"},
+		{netip.MustParseAddr("2.8.0.0"), netip.MustParseAddr("2.15.255.255"), true, "FR"},
+		{netip.MustParseAddr("2.16.0.0"), netip.MustParseAddr("2.16.5.255"), false, ""},
+		{netip.MustParseAddr("2.16.6.0"), netip.MustParseAddr("2.16.7.255"), true, ""},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("AppendRanges() = %v, want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := NewMatcher().WriteMMDB(&buf); err != nil {
+		t.Fatal(err)
+	}
+	r := newMMDBReader(t, buf.Bytes())
+	for _, tc := range []struct {
+		ip, country string
+		eu          bool
+	}{
+		{"2.0.0.1", "DE", true},
+		{"2.9.0.1", "FR", true},
+		{"81.2.69.142", "GB", false},
+		{"2.16.6.1", "", true},
+	} {
+		rec, _ := r.lookup(netip.MustParseAddr(tc.ip)).(map[string]any)
+		country, _ := rec["country"].(map[string]any)
+		if got, _ := country["iso_code"].(string); got != tc.country {
+			t.Errorf("MMDB iso_code for %s = %q, want %q", tc.ip, got, tc.country)
+		}
+		if got, _ := country["is_in_european_union"].(bool); got != tc.eu {
+			t.Errorf("MMDB is_in_european_union for %s = %v, want %v", tc.ip, got, tc.eu)
+		}
+	}
+}
+
+func TestAppendRangePrefixes(t *testing.T) {
+	for _, tc := range []struct {
+		start, end string
+		want       []string
+	}{
+		{"2.0.0.0", "2.15.255.255", []string{"2.0.0.0/12"}},
+		{"10.0.0.1", "10.0.0.6", []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"}},
+		{"0.0.0.0", "255.255.255.255", []string{"0.0.0.0/0"}},
+		{"2001:db8::", "2001:db8::1:ffff", []string{"2001:db8::/111"}},
+	} {
+		var got []string
+		for _, p := range appendRangePrefixes(nil, netip.MustParseAddr(tc.start), netip.MustParseAddr(tc.end)) {
+			got = append(got, p.String())
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("appendRangePrefixes(%s, %s) = %v, want %v", tc.start, tc.end, got, tc.want)
+		}
+	}
+}
+
+func TestIsFromEUWithCountry(t *testing.T) {
+	if eu, country := IsFromEUWithCountry(net.ParseIP("2.0.0.1")); !eu || country != "" {
+		t.Errorf("IsFromEUWithCountry(2.0.0.1) without country data = %v, %q, want true, \"\"", eu, country)
+	}
+
+	withTables(t, map[*[]uint16][]uint16{
+		&v4Data:   buildTable("44.0.0.0/8", "45.0.0.0/8"),
+		&v6Data:   buildTable("2620:db8::/32"),
+		&gbV4Data: buildTable("46.0.0.0/8"),
+	})
+	withCountries(t,
+		map[string]uint32{"44.0.0.0/8": 0, "45.0.0.0/8": 1, "46.0.0.0/8": 2},
+		map[string]uint32{"2620:db8::/32": 1},
+		"DE", "FR", "GB")
+	for _, m := range []*Matcher{NewMatcher(), NewMatcher(WithUKTreatedAsEU(true))} {
+		for _, ip := range []string{"44.0.0.1", "45.0.0.1", "::ffff:45.0.0.1", "46.0.0.1", "47.0.0.1", "2620:db8::1", "2620:db9::1", "10.0.0.1"} {
+			eu, country := m.IsFromEUWithCountry(net.ParseIP(ip))
+			if want := m.IsFromEU(net.ParseIP(ip)); eu != want {
+				t.Errorf("IsFromEUWithCountry(%s) = %v, but IsFromEU = %v", ip, eu, want)
+			}
+			if want := m.Country(netip.MustParseAddr(ip)); country != want {
+				t.Errorf("IsFromEUWithCountry(%s) country = %q, want %q", ip, country, want)
+			}
+		}
+	}
+}
+
+func TestCountryResult(t *testing.T) {
+	for _, tc := range []struct {
+		m       *Matcher
+		country string
+		eu      bool
+	}{
+		{NewMatcher(), "DE", true},
+		{NewMatcher(), "fr", true},
+		{NewMatcher(), "US", false},
+		{NewMatcher(), "GB", false},
+		{NewMatcher(WithUKTreatedAsEU(true)), "gb", true},
+	} {
+		if r := tc.m.CountryResult(tc.country); r.EU != tc.eu || r.Provenance != SourceRequest {
+			t.Errorf("CountryResult(%q) = %+v, want EU %v from the request", tc.country, r, tc.eu)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package eurip
+
+import (
+	"strings"
+	"sync"
+)
+
+// countryNames maps language subtags to names by ISO 3166-1 alpha-2 code.
+// The embedded tables cover the countries Country can return and the
+// non-EU members of the EEA and Schengen area.
+var countryNames = map[string]map[string]string{
+	"en": {
+		"AD": "Andorra", "AT": "Austria", "BE": "Belgium", "BG": "Bulgaria",
+		"CH": "Switzerland", "CY": "Cyprus", "CZ": "Czechia", "DE": "Germany",
+		"DK": "Denmark", "EE": "Estonia", "ES": "Spain", "FI": "Finland",
+		"FR": "France", "GB": "United Kingdom", "GR": "Greece", "HR": "Croatia",
+		"HU": "Hungary", "IE": "Ireland", "IS": "Iceland", "IT": "Italy",
+		"LI": "Liechtenstein", "LT": "Lithuania", "LU": "Luxembourg", "LV": "Latvia",
+		"MC": "Monaco", "MT": "Malta", "NL": "Netherlands", "NO": "Norway",
+		"PL": "Poland", "PT": "Portugal", "RO": "Romania", "SE": "Sweden",
+		"SI": "Slovenia", "SK": "Slovakia", "SM": "San Marino", "VA": "Vatican City",
+	},
+	"de": {
+		"AD": "Andorra", "AT": "Österreich", "BE": "Belgien", "BG": "Bulgarien",
+		"CH": "Schweiz", "CY": "Zypern", "CZ": "Tschechien", "DE": "Deutschland",
+		"DK": "Dänemark", "EE": "Estland", "ES": "Spanien", "FI": "Finnland",
+		"FR": "Frankreich", "GB": "Vereinigtes Königreich", "GR": "Griechenland", "HR": "Kroatien",
+		"HU": "Ungarn", "IE": "Irland", "IS": "Island", "IT": "Italien",
+		"LI": "Liechtenstein", "LT": "Litauen", "LU": "Luxemburg", "LV": "Lettland",
+		"MC": "Monaco", "MT": "Malta", "NL": "Niederlande", "NO": "Norwegen",
+		"PL": "Polen", "PT": "Portugal", "RO": "Rumänien", "SE": "Schweden",
+		"SI": "Slowenien", "SK": "Slowakei", "SM": "San Marino", "VA": "Vatikanstadt",
+	},
+	"fr": {
+		"AD": "Andorre", "AT": "Autriche", "BE": "Belgique", "BG": "Bulgarie",
+		"CH": "Suisse", "CY": "Chypre", "CZ": "Tchéquie", "DE": "Allemagne",
+		"DK": "Danemark", "EE": "Estonie", "ES": "Espagne", "FI": "Finlande",
+		"FR": "France", "GB": "Royaume-Uni", "GR": "Grèce", "HR": "Croatie",
+		"HU": "Hongrie", "IE": "Irlande", "IS": "Islande", "IT": "Italie",
+		"LI": "Liechtenstein", "LT": "Lituanie", "LU": "Luxembourg", "LV": "Lettonie",
+		"MC": "Monaco", "MT": "Malte", "NL": "Pays-Bas", "NO": "Norvège",
+		"PL": "Pologne", "PT": "Portugal", "RO": "Roumanie", "SE": "Suède",
+		"SI": "Slovénie", "SK": "Slovaquie", "SM": "Saint-Marin", "VA": "Vatican",
+	},
+}
+
+// countryNamesMu guards countryNames against RegisterCountryNames.
+var countryNamesMu sync.RWMutex
+
+// CountryName returns the name of the country with the given ISO 3166-1
+// alpha-2 code in the language lang, a BCP 47 tag like "de" or "de-AT". It
+// falls back from a regional tag to its language, and then to English, and
+// returns "" for codes it has no name for. Names are embedded in English,
+// German, and French; RegisterCountryNames adds others.
+func CountryName(iso, lang string) string {
+	iso = strings.ToUpper(iso)
+	lang = strings.ToLower(strings.ReplaceAll(lang, "_", "-"))
+	countryNamesMu.RLock()
+	defer countryNamesMu.RUnlock()
+	for tag := lang; tag != ""; {
+		if name, ok := countryNames[tag][iso]; ok {
+			return name
+		}
+		i := strings.LastIndexByte(tag, '-')
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+	}
+	return countryNames["en"][iso]
+}
+
+// RegisterCountryNames adds names, by ISO 3166-1 alpha-2 code, to the table
+// CountryName uses for lang, replacing any names the codes had.
+func RegisterCountryNames(lang string, names map[string]string) {
+	lang = strings.ToLower(strings.ReplaceAll(lang, "_", "-"))
+	countryNamesMu.Lock()
+	defer countryNamesMu.Unlock()
+	t := countryNames[lang]
+	if t == nil {
+		t = map[string]string{}
+		countryNames[lang] = t
+	}
+	for iso, name := range names {
+		t[strings.ToUpper(iso)] = name
+	}
+}
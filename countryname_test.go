@@ -0,0 +1,52 @@
+package eurip
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCountryName(t *testing.T) {
+	for _, tc := range []struct{ iso, lang, want string }{
+		{"DE", "en", "Germany"},
+		{"de", "en-GB", "Germany"},
+		{"DE", "de", "Deutschland"},
+		{"DE", "de-AT", "Deutschland"},
+		{"GB", "fr_CA", "Royaume-Uni"},
+		{"FR", "ja", "France"},
+		{"FR", "", "France"},
+		{"XX", "en", ""},
+		{"", "en", ""},
+	} {
+		if got := CountryName(tc.iso, tc.lang); got != tc.want {
+			t.Errorf("CountryName(%q, %q) = %q, want %q", tc.iso, tc.lang, got, tc.want)
+		}
+	}
+
+	RegisterCountryNames("nl", map[string]string{"de": "Duitsland"})
+	t.Cleanup(func() {
+		countryNamesMu.Lock()
+		delete(countryNames, "nl")
+		countryNamesMu.Unlock()
+	})
+	if got := CountryName("DE", "nl-BE"); got != "Duitsland" {
+		t.Errorf("CountryName(DE, nl-BE) = %q after RegisterCountryNames, want Duitsland", got)
+	}
+	if got := CountryName("FR", "nl"); got != "France" {
+		t.Errorf("CountryName(FR, nl) = %q, want the English fallback", got)
+	}
+
+	// Every language should name the same countries.
+	codes := func(names map[string]string) []string {
+		var out []string
+		for iso := range names {
+			out = append(out, iso)
+		}
+		slices.Sort(out)
+		return out
+	}
+	for _, lang := range []string{"de", "fr"} {
+		if got, want := codes(countryNames[lang]), codes(countryNames["en"]); !slices.Equal(got, want) {
+			t.Errorf("%s names %v, want %v", lang, got, want)
+		}
+	}
+}
@@ -3608,3 +3608,53 @@ var v6Data = []uint16{
 	0, 12034, 32768, 0, 12037, 256, 0, 12040, 32768, 0, 12043, 1, 0, 12046, 32768, 0,
 	12049, 512, 0, 322,
 }
+
+var gbV4Data = []uint16{
+	0, 0,
+}
+
+var gbV6Data = []uint16{
+	0, 0,
+}
+
+var microV4Data = []uint16{
+	0, 0,
+}
+
+var microV6Data = []uint16{
+	0, 0,
+}
+
+var uncertainV4Data = []uint16{
+	0, 0,
+}
+
+var uncertainV6Data = []uint16{
+	0, 0,
+}
+
+var knownV4Data = []uint16{
+	0, 0,
+}
+
+var knownV6Data = []uint16{
+	0, 0,
+}
+
+var datacenterV4Data = []uint16{
+	0, 0,
+}
+
+var datacenterV6Data = []uint16{
+	0, 0,
+}
+
+var countryV4Data = []uint32{
+	0,
+}
+
+var countryV6Data = []uint32{
+	0,
+}
+
+var countryCodes = []string{}
@@ -0,0 +1,22 @@
+package eurip
+
+import "net/netip"
+
+// IsLikelyDatacenter reports whether addr is in a known datacenter or cloud
+// range. Traffic from those is mostly bots, proxies, and VPNs, so an EU
+// answer for it says little about where the person behind it is.
+//
+// The datacenter table is optional: it is generated with process.py
+// --datacenters from a list of ranges, such as those cloud providers
+// publish, and can be updated separately from GeoLite2. The embedded dataset
+// was built without it, so against that IsLikelyDatacenter always returns
+// false.
+func IsLikelyDatacenter(addr netip.Addr) bool {
+	return defaultMatcher.IsLikelyDatacenter(addr)
+}
+
+// IsLikelyDatacenter is like the package-level IsLikelyDatacenter, but uses
+// m's view of the dataset.
+func (m *Matcher) IsLikelyDatacenter(addr netip.Addr) bool {
+	return m.load().datacenter.contains(addr.Unmap())
+}
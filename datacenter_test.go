@@ -0,0 +1,46 @@
+package eurip
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestIsLikelyDatacenter(t *testing.T) {
+	if IsLikelyDatacenter(netip.MustParseAddr("2.0.0.1")) {
+		t.Error("IsLikelyDatacenter is true without a datacenter table")
+	}
+
+	withTables(t, map[*[]uint16][]uint16{
+		&datacenterV4Data: buildTable("44.0.0.0/16"),
+		&datacenterV6Data: buildTable("2620:db8::/32"),
+	})
+	m := NewMatcher()
+	for ip, want := range map[string]bool{
+		"44.0.0.1":         true,
+		"::ffff:44.0.1.1":  true,
+		"44.1.0.1":         false,
+		"2620:db8::1":      true,
+		"2620:db9::1":      false,
+		"invalid address?": false,
+	} {
+		if skipV6(ip) {
+			continue
+		}
+		addr, _ := netip.ParseAddr(ip)
+		if got := m.IsLikelyDatacenter(addr); got != want {
+			t.Errorf("IsLikelyDatacenter(%s) = %v, want %v", ip, got, want)
+		}
+	}
+
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := NewMatcherFromBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.IsLikelyDatacenter(netip.MustParseAddr("44.0.0.1")) {
+		t.Error("the datacenter table didn't survive MarshalBinary")
+	}
+}
@@ -0,0 +1,250 @@
+package eurip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+	"unsafe"
+)
+
+// A dataset holds every table a Matcher can be built from: the embedded
+// one, or one loaded with NewMatcherFromBytes.
+type dataset struct {
+	// version is the GeoLite2 version the tables were built from.
+	version                         string
+	eu, uk, micro, uncertain, known table
+	datacenter                      table
+	countries                       countryTable
+	// families says whether the dataset has IPv4 and IPv6 tables.
+	families [2]bool
+}
+
+// embeddedDataset returns the tables compiled into the package.
+func embeddedDataset() *dataset {
+	empty := []uint16{0, 0}
+	d := &dataset{
+		version:    Version,
+		eu:         table{v4Data, empty, "EU"},
+		uk:         table{gbV4Data, empty, "UK"},
+		micro:      table{microV4Data, empty, "microstates"},
+		uncertain:  table{uncertainV4Data, empty, "uncertain"},
+		known:      table{knownV4Data, empty, "known"},
+		datacenter: table{datacenterV4Data, empty, "datacenter"},
+		countries:  countryTable{countryV4Data, []uint32{0}, countryCodes},
+		families:   [2]bool{true, embeddedIPv6},
+	}
+	// Without the IPv6 tables referenced, the linker leaves them out.
+	if embeddedIPv6 {
+		d.eu.v6, d.uk.v6, d.micro.v6 = v6Data, gbV6Data, microV6Data
+		d.uncertain.v6, d.known.v6, d.datacenter.v6 = uncertainV6Data, knownV6Data, datacenterV6Data
+		d.countries.v6 = countryV6Data
+	}
+	return d
+}
+
+// The serialized dataset format, which codegen.py --bin also writes. All
+// integers are little-endian. A header holds:
+//
+//	magic    [8]byte  "EURIPDS\x01"
+//	version  [16]byte the GeoLite2 version, NUL padded
+//	count    uint32   the number of sections
+//	sections [count]struct{ id, offset, length uint32 }
+//
+// Each section's offset and length are in bytes from the start of the data,
+// and offsets are multiples of 4, so tables can be used in place. Bitset
+// DAG sections hold uint16s, value DAG sections uint32s, and the country
+// code section newline-separated codes. Sections may be missing, and are
+// then empty; unknown sections are ignored. A dataset without an EU section
+// for a family has no data for that family at all, and lookups in it fail
+// with ErrFamilyUnavailable.
+const datasetMagic = "EURIPDS\x01"
+
+const datasetHeaderLen = 8 + 16 + 4
+
+// Section ids.
+const (
+	secEUV4 = 1 + iota
+	secEUV6
+	secUKV4
+	secUKV6
+	secMicroV4
+	secMicroV6
+	secUncertainV4
+	secUncertainV6
+	secKnownV4
+	secKnownV6
+	secCountryV4
+	secCountryV6
+	secCountryCodes
+	secDatacenterV4
+	secDatacenterV6
+)
+
+// The table sections of each family.
+var (
+	v4Sections = []uint32{secEUV4, secUKV4, secMicroV4, secUncertainV4, secKnownV4, secDatacenterV4, secCountryV4}
+	v6Sections = []uint32{secEUV6, secUKV6, secMicroV6, secUncertainV6, secKnownV6, secDatacenterV6, secCountryV6}
+)
+
+// NewMatcherFromBytes returns a Matcher over the serialized dataset in b, as
+// written by MarshalBinary or codegen.py --bin. On little-endian machines
+// the tables are used in place, without copying, so b can be memory the
+// caller shares, like a mapped file; it must not change while the Matcher
+// is in use. The dataset is checked with Validate, so a malformed one
+// returns an error wrapping ErrDatasetCorrupt rather than misbehaving.
+func NewMatcherFromBytes(b []byte, opts ...Option) (*Matcher, error) {
+	d, err := parseDataset(b)
+	if err != nil {
+		return nil, err
+	}
+	m := newMatcher(d, opts)
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func parseDataset(b []byte) (*dataset, error) {
+	if len(b) < datasetHeaderLen || string(b[:8]) != datasetMagic {
+		return nil, corrupt("header", "not a serialized dataset")
+	}
+	le := binary.LittleEndian
+	d := &dataset{version: string(bytes.TrimRight(b[8:24], "\x00"))}
+	n := int(le.Uint32(b[24:]))
+	if n > (len(b)-datasetHeaderLen)/12 {
+		return nil, corrupt("header", "%d sections past end of data", n)
+	}
+	sections := map[uint32][]byte{}
+	for i := range n {
+		s := b[datasetHeaderLen+12*i:]
+		id, off, length := le.Uint32(s), uint64(le.Uint32(s[4:])), uint64(le.Uint32(s[8:]))
+		if off%4 != 0 || off+length > uint64(len(b)) {
+			return nil, corrupt("header", "section %d at %d+%d is misaligned or past end of data", id, off, length)
+		}
+		sections[id] = b[off : off+length]
+	}
+	u16 := func(id uint32) []uint16 {
+		if s, ok := sections[id]; ok {
+			return uint16s(s)
+		}
+		return []uint16{0, 0}
+	}
+	u32 := func(id uint32) []uint32 {
+		if s, ok := sections[id]; ok {
+			return uint32s(s)
+		}
+		return []uint32{0}
+	}
+	_, v4 := sections[secEUV4]
+	_, v6 := sections[secEUV6]
+	d.families = [2]bool{v4, v6}
+	d.eu = table{u16(secEUV4), u16(secEUV6), "EU"}
+	d.uk = table{u16(secUKV4), u16(secUKV6), "UK"}
+	d.micro = table{u16(secMicroV4), u16(secMicroV6), "microstates"}
+	d.uncertain = table{u16(secUncertainV4), u16(secUncertainV6), "uncertain"}
+	d.known = table{u16(secKnownV4), u16(secKnownV6), "known"}
+	d.datacenter = table{u16(secDatacenterV4), u16(secDatacenterV6), "datacenter"}
+	d.countries = countryTable{v4: u32(secCountryV4), v6: u32(secCountryV6)}
+	if s := strings.TrimSuffix(string(sections[secCountryCodes]), "\n"); s != "" {
+		d.countries.codes = strings.Split(s, "\n")
+	}
+	return d, nil
+}
+
+// littleEndian is whether tables can be read from little-endian bytes in
+// place.
+var littleEndian = binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+
+// uint16s returns b as little-endian uint16s, sharing its memory if it can.
+func uint16s(b []byte) []uint16 {
+	p := unsafe.SliceData(b)
+	if littleEndian && len(b) > 0 && uintptr(unsafe.Pointer(p))%unsafe.Alignof(uint16(0)) == 0 {
+		return unsafe.Slice((*uint16)(unsafe.Pointer(p)), len(b)/2)
+	}
+	out := make([]uint16, len(b)/2)
+	for i := range out {
+		out[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return out
+}
+
+// uint32s returns b as little-endian uint32s, sharing its memory if it can.
+func uint32s(b []byte) []uint32 {
+	p := unsafe.SliceData(b)
+	if littleEndian && len(b) > 0 && uintptr(unsafe.Pointer(p))%unsafe.Alignof(uint32(0)) == 0 {
+		return unsafe.Slice((*uint32)(unsafe.Pointer(p)), len(b)/4)
+	}
+	out := make([]uint32, len(b)/4)
+	for i := range out {
+		out[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	return out
+}
+
+// MarshalBinary serializes m's dataset, including the tables of options m
+// doesn't use, for NewMatcherFromBytes.
+func (m *Matcher) MarshalBinary() ([]byte, error) {
+	d := m.load().data
+	if len(d.version) > 16 {
+		return nil, fmt.Errorf("eurip: version %q too long to serialize", d.version)
+	}
+	type section struct {
+		id   uint32
+		data []byte
+	}
+	le := binary.LittleEndian
+	u16 := func(id uint32, t []uint16) section {
+		var b []byte
+		for _, x := range t {
+			b = le.AppendUint16(b, x)
+		}
+		return section{id, b}
+	}
+	u32 := func(id uint32, t []uint32) section {
+		var b []byte
+		for _, x := range t {
+			b = le.AppendUint32(b, x)
+		}
+		return section{id, b}
+	}
+	var codes []byte
+	for _, c := range d.countries.codes {
+		codes = append(codes, c+"\n"...)
+	}
+	sections := []section{
+		u16(secEUV4, d.eu.v4), u16(secEUV6, d.eu.v6),
+		u16(secUKV4, d.uk.v4), u16(secUKV6, d.uk.v6),
+		u16(secMicroV4, d.micro.v4), u16(secMicroV6, d.micro.v6),
+		u16(secUncertainV4, d.uncertain.v4), u16(secUncertainV6, d.uncertain.v6),
+		u16(secKnownV4, d.known.v4), u16(secKnownV6, d.known.v6),
+		u16(secDatacenterV4, d.datacenter.v4), u16(secDatacenterV6, d.datacenter.v6),
+		u32(secCountryV4, d.countries.v4), u32(secCountryV6, d.countries.v6),
+		{secCountryCodes, codes},
+	}
+	// Leave out the sections of a missing family, so it stays missing.
+	sections = slices.DeleteFunc(sections, func(s section) bool {
+		return !d.families[0] && slices.Contains(v4Sections, s.id) || !d.families[1] && slices.Contains(v6Sections, s.id)
+	})
+
+	b := make([]byte, datasetHeaderLen, datasetHeaderLen+12*len(sections))
+	copy(b, datasetMagic)
+	copy(b[8:24], d.version)
+	le.PutUint32(b[24:], uint32(len(sections)))
+	off := datasetHeaderLen + 12*len(sections)
+	for _, s := range sections {
+		off = (off + 3) &^ 3
+		b = le.AppendUint32(b, s.id)
+		b = le.AppendUint32(b, uint32(off))
+		b = le.AppendUint32(b, uint32(len(s.data)))
+		off += len(s.data)
+	}
+	for _, s := range sections {
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+		b = append(b, s.data...)
+	}
+	return b, nil
+}
@@ -0,0 +1,64 @@
+package eurip
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"testing"
+	"unsafe"
+)
+
+func TestNewMatcherFromBytes(t *testing.T) {
+	withTables(t, map[*[]uint16][]uint16{
+		&gbV4Data:        buildTable("81.2.69.0/24"),
+		&uncertainV6Data: buildTable("2620:db8::/32"),
+	})
+	withCountries(t, map[string]uint32{"2.0.0.0/12": 0}, map[string]uint32{"2620:db8::/32": 1}, "FR", "IE")
+	b, err := NewMatcher().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMatcherFromBytes(b, WithUKTreatedAsEU(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := NewMatcher(WithUKTreatedAsEU(true))
+	for _, ip := range []string{"2.0.0.1", "1.0.0.1", "81.2.69.1", "2001:420:4000:1::", "2620:db8::1", "2620:db9::1"} {
+		addr := netip.MustParseAddr(ip)
+		if got, want := m.Lookup(addr), want.Lookup(addr); got != want {
+			t.Errorf("Lookup(%s) = %+v, want %+v", ip, got, want)
+		}
+		if got, want := m.Country(addr), want.Country(addr); got != want {
+			t.Errorf("Country(%s) = %q, want %q", ip, got, want)
+		}
+	}
+	if m.load().data.version != Version {
+		t.Errorf("version = %q, want %q", m.load().data.version, Version)
+	}
+	// The tables should alias b, not copies of it.
+	if p := unsafe.Pointer(unsafe.SliceData(m.load().data.eu.v4)); littleEndian &&
+		(uintptr(p) < uintptr(unsafe.Pointer(&b[0])) || uintptr(p) >= uintptr(unsafe.Pointer(&b[0]))+uintptr(len(b))) {
+		t.Error("v4 table was copied")
+	}
+	if again, err := m.MarshalBinary(); err != nil || !bytes.Equal(again, b) {
+		t.Errorf("MarshalBinary of the loaded Matcher differs: %v", err)
+	}
+
+	// Misaligned input is copied, and still works.
+	shifted := append(make([]byte, 1, len(b)+1), b...)[1:]
+	if m, err := NewMatcherFromBytes(shifted); err != nil || !m.Lookup(netip.MustParseAddr("2.0.0.1")).EU {
+		t.Errorf("misaligned NewMatcherFromBytes: %v", err)
+	}
+
+	corruptions := map[string][]byte{
+		"empty":     nil,
+		"magic":     append([]byte("EURIPDS\x02"), b[8:]...),
+		"truncated": b[:len(b)-100],
+		"count":     append(append(append([]byte{}, b[:24]...), 0xff, 0xff, 0, 0), b[28:]...),
+	}
+	for name, c := range corruptions {
+		if _, err := NewMatcherFromBytes(c); !errors.Is(err, ErrDatasetCorrupt) {
+			t.Errorf("%s: NewMatcherFromBytes = %v, want ErrDatasetCorrupt", name, err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package eurip
+
+import "net/netip"
+
+// A DualStackResult combines the Results for the IPv4 and IPv6 addresses of
+// one client.
+type DualStackResult struct {
+	// Result is the combined decision: EU if either address is, and
+	// Uncertain if either is or they disagree.
+	Result
+	V4, V6 Result
+	// Mismatch is true if the addresses disagree about EU, which is common:
+	// providers often geolocate their IPv4 and IPv6 space differently.
+	Mismatch bool
+}
+
+// LookupDualStack classifies a client seen at both v4 and v6, say through
+// Happy Eyeballs or across sessions, using the default Matcher.
+func LookupDualStack(v4, v6 netip.Addr) DualStackResult {
+	return defaultMatcher.LookupDualStack(v4, v6)
+}
+
+// LookupDualStack is like the package-level LookupDualStack, but uses m's
+// view of the dataset. If either address is invalid, the other decides
+// alone.
+func (m *Matcher) LookupDualStack(v4, v6 netip.Addr) DualStackResult {
+	d := DualStackResult{V4: m.Lookup(v4), V6: m.Lookup(v6)}
+	switch {
+	case !v4.IsValid():
+		d.Result = d.V6
+	case !v6.IsValid():
+		d.Result = d.V4
+	default:
+		d.Mismatch = d.V4.EU != d.V6.EU
+		d.Result = Result{
+			EU:        d.V4.EU || d.V6.EU,
+			Uncertain: d.V4.Uncertain || d.V6.Uncertain || d.Mismatch,
+			Unknown:   d.V4.Unknown && d.V6.Unknown,
+		}
+	}
+	return d
+}
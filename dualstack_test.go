@@ -0,0 +1,37 @@
+package eurip
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestLookupDualStack(t *testing.T) {
+	withTables(t, map[*[]uint16][]uint16{
+		&v4Data:          buildTable("44.0.0.0/16"),
+		&v6Data:          buildTable("2620:db8::/32"),
+		&uncertainV6Data: buildTable("2620:db8:1::/48"),
+	})
+	for _, tc := range []struct {
+		v4, v6              string
+		eu, uncertain, diff bool
+	}{
+		{"44.0.0.1", "2620:db8::1", true, false, false},
+		{"45.0.0.1", "2620:db9::1", false, false, false},
+		{"44.0.0.1", "2620:db9::1", true, true, true},
+		{"45.0.0.1", "2620:db8::1", true, true, true},
+		{"44.0.0.1", "2620:db8:1::1", true, true, false},
+		{"44.0.0.1", "", true, false, false},
+		{"", "2620:db9::1", false, false, false},
+	} {
+		if skipV6(tc.v6) {
+			continue
+		}
+		v4, _ := netip.ParseAddr(tc.v4)
+		v6, _ := netip.ParseAddr(tc.v6)
+		d := NewMatcher().LookupDualStack(v4, v6)
+		if d.EU != tc.eu || d.Uncertain != tc.uncertain || d.Mismatch != tc.diff {
+			t.Errorf("LookupDualStack(%s, %s) = %+v, want EU %v, uncertain %v, mismatch %v",
+				tc.v4, tc.v6, d, tc.eu, tc.uncertain, tc.diff)
+		}
+	}
+}
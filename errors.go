@@ -0,0 +1,58 @@
+package eurip
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors returned by the package, possibly wrapped with more detail. Test for
+// them with errors.Is.
+var (
+	// ErrInvalidAddr means an input could not be parsed as an IP address.
+	ErrInvalidAddr = errors.New("eurip: invalid address")
+	// ErrUnclassified means an address is valid but not located anywhere,
+	// such as a private or loopback address.
+	ErrUnclassified = errors.New("eurip: unclassified address")
+	// ErrLocal means an address is local, as reported by IsLocal, and the
+	// Matcher's LocalPolicy is LocalError.
+	ErrLocal = errors.New("eurip: local address")
+	// ErrUnknown means an address is public, but the dataset has no data for
+	// it, so it may or may not be EU.
+	ErrUnknown = errors.New("eurip: unknown address")
+	// ErrFamilyUnavailable means an address is public, but the dataset has
+	// no tables for its address family. See Matcher.Families.
+	ErrFamilyUnavailable = errors.New("eurip: address family unavailable")
+	// ErrDatasetCorrupt means a dataset's tables are malformed, so lookups
+	// against it could give wrong answers or panic.
+	ErrDatasetCorrupt = errors.New("eurip: dataset corrupt")
+	// ErrDatasetStale means a dataset is older than the caller allows. The
+	// error is a *StaleError.
+	ErrDatasetStale = errors.New("eurip: dataset stale")
+	// ErrTableUnavailable means a Matcher option needs a table its dataset
+	// was generated without. See Matcher.CheckTables.
+	ErrTableUnavailable = errors.New("eurip: table unavailable")
+	// ErrSelfTestFailed means a dataset classifies one of the addresses
+	// Matcher.SelfTest checks differently than expected.
+	ErrSelfTestFailed = errors.New("eurip: self-test failed")
+)
+
+// A StaleError reports a dataset older than a freshness threshold. It matches
+// ErrDatasetStale.
+type StaleError struct {
+	Version     string // the dataset's GeoLite2 version, as YYYYMMDD
+	Age, MaxAge time.Duration
+}
+
+func (e *StaleError) Error() string {
+	return fmt.Sprintf("eurip: dataset %s is stale: %v old, limit %v", e.Version, e.Age.Round(time.Hour), e.MaxAge)
+}
+
+func (e *StaleError) Is(target error) bool {
+	return target == ErrDatasetStale
+}
+
+// corrupt returns an error wrapping ErrDatasetCorrupt.
+func corrupt(table string, format string, args ...any) error {
+	return fmt.Errorf("%w: %s: %s", ErrDatasetCorrupt, table, fmt.Sprintf(format, args...))
+}
@@ -1,26 +1,40 @@
 // Package eurip implements a fast test for whether an IP is from the EU.
 // This library includes GeoLite2 data created by MaxMind,
 // available from http://www.maxmind.com.
+//
+// The embedded data is the 20180501 build, made before the generator used
+// ISO codes: it classifies Greece and the UK as not EU. Load a dataset
+// generated from a current snapshot with NewMatcherFromBytes for correct
+// answers.
 package eurip
 
 import (
-	"log"
 	"math/bits"
 	"net"
+	"net/netip"
 )
 
 // IsFromEu returns true if the given IP is probably in the EU, based on
 // a country-level IP database.
 func IsFromEU(ipAddress net.IP) bool {
-	if ipAddress == nil {
-		return false
-	}
-	ip4 := ipAddress.To4()
-	if ip4 != nil {
-		log.Println("here")
-		return walk(ip4, v4Data)
-	}
-	return walk(ipAddress.To16(), v6Data)
+	return defaultMatcher.IsFromEU(ipAddress)
+}
+
+// Lookup classifies addr using the default Matcher.
+func Lookup(addr netip.Addr) Result {
+	return defaultMatcher.Lookup(addr)
+}
+
+// LookupString parses s and classifies it using the default Matcher.
+func LookupString(s string) (Result, error) {
+	return defaultMatcher.LookupString(s)
+}
+
+// MatchedPrefix returns the dataset prefix that decides whether addr is EU:
+// the EU prefix holding it, or else a block around it with no EU space. It returns the zero Prefix for
+// invalid addresses. IPv4-mapped IPv6 addresses are treated as IPv4.
+func MatchedPrefix(addr netip.Addr) netip.Prefix {
+	return defaultMatcher.MatchedPrefix(addr)
 }
 
 func walk(addr []byte, data []uint16) bool {
@@ -31,10 +45,8 @@ func walk(addr []byte, data []uint16) bool {
 	}
 	p := 0
 	for _, n := range nibbles {
-		log.Printf("n:%x p:%x hc:%x sc:%x\n", n, p, data[p], data[p+1])
 		if has_child := data[p]; has_child&(1<<n) != 0 {
 			child_number := bits.OnesCount16(has_child & ((1 << n) - 1))
-			log.Printf("child number: %d", child_number)
 			p = int(data[p+2+child_number])
 			continue
 		}
@@ -17,6 +17,9 @@ func TestKnownRanges(t *testing.T) {
 		{"::0", false},
 		{"2001:420:4000:1::", true},
 	} {
+		if skipV6(tc.ip) {
+			continue
+		}
 		result := IsFromEU(net.ParseIP(tc.ip))
 		if result != tc.is_euro {
 			t.Errorf("IsFromEU(%s) != %v", tc.ip, tc.is_euro)
@@ -0,0 +1,128 @@
+package eurip
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// A Source names the data a decision came from.
+type Source string
+
+const (
+	// SourceGeoLite2 means the decision came from the GeoLite2 tables.
+	SourceGeoLite2 Source = "GeoLite2"
+	// SourcePolicy means the address is invalid or special-purpose, so the
+	// Matcher's UnclassifiedPolicy or LocalPolicy decided.
+	SourcePolicy Source = "policy"
+	// SourceOverride means an override set with SetOverride decided.
+	SourceOverride Source = "override"
+	// SourceRequest means a request-time override, such as the client's
+	// declared country, decided instead of the address. It is only set as
+	// Result.Provenance.
+	SourceRequest Source = "request"
+)
+
+// A Confidence grades how far a decision can be relied on.
+type Confidence int
+
+const (
+	// ConfidenceNone means nothing is known about the address's location:
+	// the decision is a default.
+	ConfidenceNone Confidence = iota
+	// ConfidenceLow means the address is located, but in an anycast or
+	// satellite range that geolocates poorly.
+	ConfidenceLow
+	// ConfidenceHigh means the address is located by the dataset.
+	ConfidenceHigh
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case ConfidenceNone:
+		return "none"
+	case ConfidenceLow:
+		return "low"
+	case ConfidenceHigh:
+		return "high"
+	}
+	return fmt.Sprintf("Confidence(%d)", int(c))
+}
+
+// An Explanation says how a Matcher decided about an address, for audits.
+type Explanation struct {
+	Addr netip.Addr
+	Result
+	// Prefix is the dataset prefix that decided, as from MatchedPrefix, or
+	// the override that did.
+	Prefix netip.Prefix
+	// Table names the table Prefix came from if the address is EU by it:
+	// "EU", or "UK" or "microstates" if the Matcher treats those as EU.
+	Table   string
+	Country string
+	Source  Source
+	// Dataset is the GeoLite2 version the tables were built from, as
+	// YYYYMMDD.
+	Dataset    string
+	Confidence Confidence
+	// Reason describes the decision in a sentence.
+	Reason string
+	// Annotations are the address's tags, as from Matcher.Annotations.
+	Annotations []string
+}
+
+// Explain is like Lookup, but explains the decision.
+func Explain(addr netip.Addr) Explanation {
+	return defaultMatcher.Explain(addr)
+}
+
+// Explain is like the package-level Explain, but uses m's view of the
+// dataset.
+func (m *Matcher) Explain(addr netip.Addr) Explanation {
+	addr = addr.Unmap()
+	v := m.load()
+	e := Explanation{
+		Addr:        addr,
+		Country:     v.countries.lookup(addr),
+		Source:      SourceGeoLite2,
+		Dataset:     v.data.version,
+		Annotations: m.Annotations(addr),
+	}
+	if p, r, ok := m.override(addr); ok {
+		m.observe(addr, r)
+		e.Result, e.Prefix, e.Source = r, p, SourceOverride
+		e.Confidence = ConfidenceHigh
+		e.Reason = fmt.Sprintf("%s is in %s, overridden to EU %v", addr, p, r.EU)
+		return e
+	}
+	r, err := m.check(v, addr)
+	e.Result = r
+	e.Prefix, e.Table = v.matchedTable(addr)
+	switch {
+	case m.local != LocalUnclassified && IsLocal(addr):
+		e.Source = SourcePolicy
+		e.Reason = fmt.Sprintf("local address, EU %v by the local policy", r.EU)
+	case !addr.IsValid() || isSpecialUse(addr):
+		e.Source = SourcePolicy
+		kind := "invalid"
+		if addr.IsValid() {
+			kind = "special-purpose"
+		}
+		e.Reason = fmt.Sprintf("%s address, EU %v by the unclassified policy", kind, r.EU)
+	case errors.Is(err, ErrFamilyUnavailable):
+		e.Reason = fmt.Sprintf("no %s tables loaded; not EU by default", familyOf(addr))
+	case errors.Is(err, ErrUnknown):
+		e.Reason = "no data for " + addr.String() + "; not EU by default"
+	case r.EU:
+		e.Confidence = ConfidenceHigh
+		e.Reason = fmt.Sprintf("%s is in %s, in the %s table", addr, e.Prefix, e.Table)
+	default:
+		e.Confidence = ConfidenceHigh
+		e.Reason = fmt.Sprintf("%s is in %s, which holds no EU space", addr, e.Prefix)
+	}
+	if r.Uncertain && e.Confidence > ConfidenceLow {
+		e.Confidence = ConfidenceLow
+		e.Reason += "; anycast or satellite range"
+	}
+	return e
+}
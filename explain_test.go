@@ -0,0 +1,45 @@
+package eurip
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	withTables(t, map[*[]uint16][]uint16{
+		&v4Data:          buildTable("44.0.0.0/16"),
+		&gbV4Data:        buildTable("45.0.0.0/16"),
+		&uncertainV4Data: buildTable("44.0.1.0/24"),
+		&knownV4Data:     buildTable("44.0.0.0/8"),
+	})
+	m := NewMatcher(WithUKTreatedAsEU(true))
+	for _, tc := range []struct {
+		ip         string
+		eu         bool
+		prefix     string
+		table      string
+		source     Source
+		confidence Confidence
+	}{
+		{"44.0.0.1", true, "44.0.0.0/16", "EU", SourceGeoLite2, ConfidenceHigh},
+		{"::ffff:45.0.0.1", true, "45.0.0.0/16", "UK", SourceGeoLite2, ConfidenceHigh},
+		{"44.0.1.1", true, "44.0.0.0/16", "EU", SourceGeoLite2, ConfidenceLow},
+		{"46.0.0.1", false, "46.0.0.0/7", "", SourceGeoLite2, ConfidenceHigh},
+		{"10.0.0.1", false, "0.0.0.0/3", "", SourcePolicy, ConfidenceNone},
+	} {
+		e := m.Explain(netip.MustParseAddr(tc.ip))
+		if e.EU != tc.eu || e.Prefix.String() != tc.prefix || e.Table != tc.table || e.Source != tc.source ||
+			e.Confidence != tc.confidence || e.Dataset != Version || e.Reason == "" {
+			t.Errorf("Explain(%s) = %+v, want EU %v, prefix %s, table %q, source %s, confidence %v",
+				tc.ip, e, tc.eu, tc.prefix, tc.table, tc.source, tc.confidence)
+		}
+	}
+
+	e := NewMatcher(WithStrict(true)).Explain(netip.MustParseAddr("46.0.0.1"))
+	if !e.Unknown || e.Confidence != ConfidenceNone {
+		t.Errorf("strict Explain(46.0.0.1) = %+v, want unknown with no confidence", e)
+	}
+	if e := m.Explain(netip.Addr{}); e.Source != SourcePolicy || e.Prefix.IsValid() {
+		t.Errorf("Explain(invalid) = %+v", e)
+	}
+}
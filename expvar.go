@@ -0,0 +1,68 @@
+package eurip
+
+import (
+	"expvar"
+	"time"
+)
+
+// WithExpvar publishes the Matcher's lookup counters, along with the dataset
+// version and age, as an expvar.Map under name, so they appear in
+// /debug/vars. The counters are "lookups", "eu", and "uncertain", with
+// "version" and "age_seconds" alongside. Matchers created with the same name
+// share the map; like expvar.Publish, it panics if name is already taken by
+// another variable.
+func WithExpvar(name string) Option {
+	return func(m *Matcher) {
+		if v := expvar.Get(name); v != nil {
+			vars, ok := v.(*expvarMap)
+			if !ok {
+				panic("eurip: expvar " + name + " is already published")
+			}
+			m.vars = vars
+			return
+		}
+		m.vars = newExpvarMap(m.load().data.version)
+		expvar.Publish(name, m.vars)
+	}
+}
+
+// expvarMap is an expvar.Map with the counters kept at hand, so counting a
+// lookup doesn't need a map lookup.
+type expvarMap struct {
+	expvar.Map
+	lookups, eu, uncertain expvar.Int
+	version                expvar.String
+}
+
+func newExpvarMap(version string) *expvarMap {
+	v := &expvarMap{}
+	v.Init()
+	v.Set("lookups", &v.lookups)
+	v.Set("eu", &v.eu)
+	v.Set("uncertain", &v.uncertain)
+	v.version.Set(version)
+	v.Set("version", &v.version)
+	v.Set("age_seconds", expvar.Func(func() any {
+		published, err := time.Parse("20060102", v.version.Value())
+		if err != nil {
+			return nil
+		}
+		return int64(time.Since(published).Seconds())
+	}))
+	return v
+}
+
+// setVersion records a refreshed dataset's version.
+func (v *expvarMap) setVersion(version string) {
+	v.version.Set(version)
+}
+
+func (v *expvarMap) count(r Result) {
+	v.lookups.Add(1)
+	if r.EU {
+		v.eu.Add(1)
+	}
+	if r.Uncertain {
+		v.uncertain.Add(1)
+	}
+}
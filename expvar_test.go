@@ -0,0 +1,41 @@
+package eurip
+
+import (
+	"encoding/json"
+	"expvar"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestWithExpvar(t *testing.T) {
+	m := NewMatcher(WithExpvar("eurip_test"))
+	shared := NewMatcher(WithExpvar("eurip_test"))
+	m.IsFromEU(net.ParseIP("2.0.0.1"))
+	m.IsFromEU(nil)
+	m.Lookup(netip.MustParseAddr("1.0.0.1"))
+	shared.Lookup(netip.MustParseAddr("2001:420:4000:1::"))
+
+	var got struct {
+		Lookups, EU, Uncertain int
+		Version                string
+		AgeSeconds             int64 `json:"age_seconds"`
+	}
+	if err := json.Unmarshal([]byte(expvar.Get("eurip_test").String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	wantEU := 2
+	if !embeddedIPv6 {
+		wantEU = 1
+	}
+	if got.Lookups != 4 || got.EU != wantEU || got.Uncertain != 0 || got.Version != Version || got.AgeSeconds <= 0 {
+		t.Errorf("expvar eurip_test = %+v", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("WithExpvar(memstats) didn't panic")
+		}
+	}()
+	NewMatcher(WithExpvar("memstats"))
+}
@@ -0,0 +1,50 @@
+package eurip
+
+import "net/netip"
+
+// A Family is an address family a dataset may have tables for.
+type Family int
+
+// The address families.
+const (
+	IPv4 Family = 4
+	IPv6 Family = 6
+)
+
+func (f Family) String() string {
+	if f == IPv4 {
+		return "IPv4"
+	}
+	return "IPv6"
+}
+
+// Families returns the address families m's dataset has tables for. Both
+// are there unless the embedded IPv6 tables were left out with the
+// eurip_nov6 build tag, or a dataset loaded with NewMatcherFromBytes or
+// Refresh lacks one. Lookups of addresses in a missing family are not EU,
+// and Check returns an error wrapping ErrFamilyUnavailable for them.
+func (m *Matcher) Families() []Family {
+	var families []Family
+	d := m.load().data
+	if d.families[0] {
+		families = append(families, IPv4)
+	}
+	if d.families[1] {
+		families = append(families, IPv6)
+	}
+	return families
+}
+
+// familyOf returns the family of addr, which must be valid and unmapped.
+func familyOf(addr netip.Addr) Family {
+	if addr.Is4() {
+		return IPv4
+	}
+	return IPv6
+}
+
+// hasFamily reports whether d has tables for addr's family. addr must be
+// valid and unmapped.
+func (d *dataset) hasFamily(addr netip.Addr) bool {
+	return d.families[0] && addr.Is4() || d.families[1] && addr.Is6()
+}
@@ -0,0 +1,5 @@
+//go:build eurip_nov6
+
+package eurip
+
+const embeddedIPv6 = false
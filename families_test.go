@@ -0,0 +1,68 @@
+package eurip
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+// skipV6 reports whether a test case for ip, an address or prefix, must be
+// skipped because the embedded IPv6 tables were left out with the
+// eurip_nov6 build tag.
+func skipV6(ip string) bool {
+	if embeddedIPv6 {
+		return false
+	}
+	if p, err := netip.ParsePrefix(ip); err == nil {
+		return p.Addr().Is6() && !p.Addr().Is4In6()
+	}
+	addr, err := netip.ParseAddr(ip)
+	return err == nil && addr.Is6() && !addr.Is4In6()
+}
+
+func TestFamilies(t *testing.T) {
+	want := []Family{IPv4, IPv6}
+	if !embeddedIPv6 {
+		want = want[:1]
+	}
+	if got := NewMatcher().Families(); !slices.Equal(got, want) {
+		t.Errorf("Families() = %v, want %v", got, want)
+	}
+
+	d := embeddedDataset()
+	d.families[1] = false
+	b, err := newMatcher(d, nil).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMatcherFromBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Families(); !slices.Equal(got, []Family{IPv4}) {
+		t.Errorf("Families() of a dataset without IPv6 = %v, want [IPv4]", got)
+	}
+
+	v6 := netip.MustParseAddr("2001:420:4000:1::")
+	if r, err := m.Check(v6); !errors.Is(err, ErrFamilyUnavailable) || r.EU || !r.Unknown {
+		t.Errorf("Check(%s) = %+v, %v, want Unknown and ErrFamilyUnavailable", v6, r, err)
+	}
+	if m.IsFromEU(net.IP(v6.AsSlice())) {
+		t.Errorf("IsFromEU(%s) = true", v6)
+	}
+	if e := m.Explain(v6); e.Confidence != ConfidenceNone || e.Reason != "no IPv6 tables loaded; not EU by default" {
+		t.Errorf("Explain(%s) = %+v", v6, e)
+	}
+	if r, err := m.Check(netip.MustParseAddr("2.0.0.1")); err != nil || !r.EU {
+		t.Errorf("Check(2.0.0.1) = %+v, %v, want EU", r, err)
+	}
+	if _, err := m.Check(netip.MustParseAddr("fe80::1")); err != nil {
+		t.Errorf("Check(fe80::1) = %v, want special-purpose addresses left to the unclassified policy", err)
+	}
+	m.SetOverride(netip.MustParsePrefix("2001:420::/32"), Result{EU: true})
+	if r, err := m.Check(v6); err != nil || !r.EU {
+		t.Errorf("Check(%s) with an override = %+v, %v, want EU", v6, r, err)
+	}
+}
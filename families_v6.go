@@ -0,0 +1,7 @@
+//go:build !eurip_nov6
+
+package eurip
+
+// embeddedIPv6 is whether the embedded dataset includes its IPv6 tables,
+// which building with the eurip_nov6 tag leaves out.
+const embeddedIPv6 = true
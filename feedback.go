@@ -0,0 +1,49 @@
+package eurip
+
+import (
+	"math/rand/v2"
+	"net/netip"
+)
+
+// Feedback describes one lookup, for measuring how often the dataset
+// disagrees with locations users declare themselves.
+type Feedback struct {
+	// Addr is the address looked up, unmapped, or its /24 (IPv4) or /48
+	// (IPv6) network address if anonymized.
+	Addr   netip.Addr
+	Result Result
+	// Prefix is the dataset prefix that decided Result.EU. It is invalid for
+	// invalid addresses.
+	Prefix netip.Prefix
+}
+
+// WithFeedback calls fn on a random fraction rate, from 0 to 1, of the
+// Matcher's IsFromEU and Lookup calls. If anonymize is set, the host part
+// of addresses is zeroed first. fn runs synchronously in the looking-up
+// goroutine, so it should hand the Feedback off rather than block.
+func WithFeedback(rate float64, anonymize bool, fn func(Feedback)) Option {
+	return func(m *Matcher) {
+		m.feedback = &feedback{rate: rate, anonymize: anonymize, fn: fn}
+	}
+}
+
+type feedback struct {
+	rate      float64
+	anonymize bool
+	fn        func(Feedback)
+}
+
+func (f *feedback) observe(m *Matcher, addr netip.Addr, r Result) {
+	if f.rate < 1 && rand.Float64() >= f.rate {
+		return
+	}
+	fb := Feedback{Addr: addr, Result: r, Prefix: m.matchedPrefix(addr)}
+	if f.anonymize && addr.IsValid() {
+		bits := 48
+		if addr.Is4() {
+			bits = 24
+		}
+		fb.Addr = netip.PrefixFrom(addr.WithZone(""), bits).Masked().Addr()
+	}
+	f.fn(fb)
+}
@@ -0,0 +1,71 @@
+package eurip
+
+import (
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestLeaf(t *testing.T) {
+	prefixes := AppendEUPrefixes(nil, netip.Prefix{})
+	r := rand.New(rand.NewPCG(5, 6))
+	for range 5000 {
+		var a [4]byte
+		for i := range a {
+			a[i] = byte(r.Uint32())
+		}
+		addr := netip.AddrFrom4(a)
+		p, set := leaf(addr, v4Data)
+		if !p.Contains(addr) || set != IsFromEU(net.IP(a[:])) {
+			t.Fatalf("leaf(%s) = %s, %v", addr, p, set)
+		}
+		if _, found := slices.BinarySearchFunc(prefixes, p, comparePrefixes); found != set {
+			t.Fatalf("leaf(%s) = %s, %v, but AppendEUPrefixes has it: %v", addr, p, set, found)
+		}
+	}
+}
+
+func TestWithFeedback(t *testing.T) {
+	withTables(t, map[*[]uint16][]uint16{
+		&v4Data:   buildTable("2.0.0.0/12", "2.16.0.0/16"),
+		&gbV4Data: buildTable("2.32.0.0/11"),
+		&v6Data:   buildTable("2620:db8::/32"),
+	})
+	var got []Feedback
+	record := func(f Feedback) { got = append(got, f) }
+	m := NewMatcher(WithUKTreatedAsEU(true), WithFeedback(1, false, record))
+	m.Lookup(netip.MustParseAddr("2.1.2.3"))
+	m.IsFromEU(net.ParseIP("::ffff:2.33.0.1"))
+	m.Lookup(netip.MustParseAddr("2.17.0.1"))
+	want := []Feedback{
+		{netip.MustParseAddr("2.1.2.3"), Result{EU: true}, netip.MustParsePrefix("2.0.0.0/12")},
+		{netip.MustParseAddr("2.33.0.1"), Result{EU: true}, netip.MustParsePrefix("2.32.0.0/11")},
+		{netip.MustParseAddr("2.17.0.1"), Result{}, netip.MustParsePrefix("2.17.0.0/16")},
+	}
+	if !skipV6("2620:db9::1") {
+		m.Lookup(netip.MustParseAddr("2620:db9::1"))
+		want = append(want, Feedback{netip.MustParseAddr("2620:db9::1"), Result{}, netip.MustParsePrefix("2620:db9::/32")})
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("WithFeedback(1) got %v, want %v", got, want)
+	}
+
+	got = nil
+	m = NewMatcher(WithFeedback(1, true, record))
+	m.Lookup(netip.MustParseAddr("2.1.2.3"))
+	m.Lookup(netip.MustParseAddr("2620:db9:1:2::3"))
+	if len(got) != 2 || got[0].Addr != netip.MustParseAddr("2.1.2.0") || got[1].Addr != netip.MustParseAddr("2620:db9:1::") {
+		t.Errorf("anonymized feedback = %v", got)
+	}
+
+	got = nil
+	m = NewMatcher(WithFeedback(0, false, record))
+	for range 100 {
+		m.Lookup(netip.MustParseAddr("2.1.2.3"))
+	}
+	if len(got) != 0 {
+		t.Errorf("WithFeedback(0) got %d callbacks", len(got))
+	}
+}
@@ -0,0 +1,117 @@
+package eurip
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net/netip"
+	"os"
+	"testing"
+)
+
+// reference is a table built by process.py from a list of prefixes, with the
+// list to check it against by linear scan. testdata/fuzz.py generates them.
+type reference struct {
+	prefixes []netip.Prefix
+	data     []uint16
+}
+
+func loadReference(tb testing.TB, family string) reference {
+	tb.Helper()
+	f, err := os.Open("testdata/fuzz_" + family + ".txt")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+	var ref reference
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		ref.prefixes = append(ref.prefixes, netip.MustParsePrefix(s.Text()))
+	}
+	b, err := os.ReadFile("testdata/fuzz_" + family + ".btr")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for i := 0; i+1 < len(b); i += 2 {
+		ref.data = append(ref.data, binary.LittleEndian.Uint16(b[i:]))
+	}
+	return ref
+}
+
+func (ref reference) contains(addr netip.Addr) bool {
+	for _, p := range ref.prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// check compares walk and leaf for addr against a linear scan.
+func (ref reference) check(t *testing.T, addr netip.Addr) {
+	want := ref.contains(addr)
+	if got := walk(addr.AsSlice(), ref.data); got != want {
+		t.Fatalf("walk(%s) = %v, want %v", addr, got, want)
+	}
+	p, set := leaf(addr, ref.data)
+	if set != want || !p.Contains(addr) {
+		t.Fatalf("leaf(%s) = %s, %v, want a prefix holding it and %v", addr, p, set, want)
+	}
+	// Every address of a leaf prefix must share addr's answer.
+	for _, a := range []netip.Addr{p.Addr(), lastAddr(p)} {
+		if ref.contains(a) != want {
+			t.Fatalf("leaf(%s) = %s, but %s is %v", addr, p, a, !want)
+		}
+	}
+}
+
+// addBoundaries seeds f with the edges of each reference prefix, where
+// off-by-one bugs show.
+func addBoundaries(f *testing.F, ref reference, add func(netip.Addr)) {
+	for _, p := range ref.prefixes {
+		for _, a := range []netip.Addr{p.Addr(), p.Addr().Prev(), lastAddr(p), lastAddr(p).Next()} {
+			if a.IsValid() {
+				add(a)
+			}
+		}
+	}
+}
+
+func FuzzWalkV4(f *testing.F) {
+	ref := loadReference(f, "v4")
+	addBoundaries(f, ref, func(a netip.Addr) { f.Add(addrUint32(a)) })
+	f.Fuzz(func(t *testing.T, a uint32) {
+		ref.check(t, netip.AddrFrom4([4]byte{byte(a >> 24), byte(a >> 16), byte(a >> 8), byte(a)}))
+	})
+}
+
+func FuzzWalkV6(f *testing.F) {
+	ref := loadReference(f, "v6")
+	addBoundaries(f, ref, func(a netip.Addr) {
+		b := a.As16()
+		f.Add(binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:]))
+	})
+	f.Fuzz(func(t *testing.T, hi, lo uint64) {
+		var b [16]byte
+		binary.BigEndian.PutUint64(b[:8], hi)
+		binary.BigEndian.PutUint64(b[8:], lo)
+		ref.check(t, netip.AddrFrom16(b))
+	})
+}
+
+// FuzzLookup checks the embedded tables against the prefixes they export.
+func FuzzLookup(f *testing.F) {
+	ref := reference{prefixes: AppendEUPrefixes(nil, netip.Prefix{})}
+	for _, ip := range []string{"2.0.0.1", "1.0.0.1", "2001:420:4000:1::", "::ffff:2.0.0.1", "0.0.0.0", "ffff::"} {
+		f.Add(netip.MustParseAddr(ip).AsSlice())
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		addr, ok := netip.AddrFromSlice(b)
+		if !ok {
+			return
+		}
+		addr = addr.Unmap()
+		if got, want := Lookup(addr).EU, ref.contains(addr); got != want {
+			t.Fatalf("Lookup(%s).EU = %v, but the exported prefixes say %v", addr, got, want)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package httpmw
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/rmmh/eurip"
+)
+
+// BlockOptions configures BlockEU and BlockNonEU.
+type BlockOptions struct {
+	// Options says how clients are classified.
+	Options
+
+	// Status is the status code blocked clients get. If 0, it is 451
+	// Unavailable For Legal Reasons.
+	Status int
+
+	// Template renders the body of blocked responses, as HTML, with a
+	// *Blocked as its data. If nil, DefaultBlockTemplate is used.
+	Template *template.Template
+
+	// Allow lists request paths that are never blocked, such as health
+	// checks. A path ending in "/" allows every path under it.
+	Allow []string
+}
+
+// Blocked is the data BlockOptions.Template is executed with.
+type Blocked struct {
+	// Addr is the client's address, which is invalid if it couldn't be
+	// determined.
+	Addr   netip.Addr
+	Result eurip.Result
+	Status int
+}
+
+// DefaultBlockTemplate is the body BlockEU and BlockNonEU send by default.
+var DefaultBlockTemplate = template.Must(template.New("blocked").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Status}} Unavailable</title></head>
+<body>
+<h1>Unavailable in your region</h1>
+<p>This service is not available from your location.</p>
+</body>
+</html>
+`))
+
+// BlockEU returns a handler that serves blocked clients a BlockOptions
+// response, and passes other requests to next, with the Result in their
+// context as Middleware does. Clients are blocked if they are EU or
+// Uncertain, or if their address can't be determined.
+func BlockEU(next http.Handler, opts BlockOptions) http.Handler {
+	return opts.block(next, func(r eurip.Result) bool { return r.EU || r.Uncertain })
+}
+
+// BlockNonEU is like BlockEU, but blocks clients that aren't EU.
+func BlockNonEU(next http.Handler, opts BlockOptions) http.Handler {
+	return opts.block(next, func(r eurip.Result) bool { return !r.EU })
+}
+
+func (o *BlockOptions) block(next http.Handler, blocked func(eurip.Result) bool) http.Handler {
+	status := o.Status
+	if status == 0 {
+		status = http.StatusUnavailableForLegalReasons
+	}
+	tmpl := o.Template
+	if tmpl == nil {
+		tmpl = DefaultBlockTemplate
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if o.allowed(r.URL.Path) {
+			next.ServeHTTP(w, o.setHeaders(w, r, eurip.Result{}, "", false))
+			return
+		}
+		res, country, err := o.client(r)
+		o.vary(w)
+		if err == nil && !blocked(res) {
+			r = withClient(r, res, country)
+			next.ServeHTTP(w, o.setHeaders(w, r, res, country, true))
+			return
+		}
+		addr, _ := o.ClientAddr(r)
+		var body bytes.Buffer
+		if err := tmpl.Execute(&body, &Blocked{Addr: addr, Result: res, Status: status}); err != nil {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+		w.WriteHeader(status)
+		w.Write(body.Bytes())
+	})
+}
+
+func (o *BlockOptions) allowed(path string) bool {
+	for _, p := range o.Allow {
+		if path == p || strings.HasSuffix(p, "/") && strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package httpmw
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBlock(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	custom := template.Must(template.New("").Parse("{{.Status}} {{.Addr}} {{.Result.EU}}"))
+	for _, tc := range []struct {
+		name   string
+		h      http.Handler
+		remote string
+		path   string
+		code   int
+		body   string
+	}{
+		{"EU blocked", BlockEU(next, BlockOptions{}), "2.0.0.1:1", "/", 451, "Unavailable in your region"},
+		{"non-EU passed", BlockEU(next, BlockOptions{}), "1.0.0.1:1", "/", 200, "ok"},
+		{"unknown blocked", BlockEU(next, BlockOptions{}), "@", "/", 451, "Unavailable"},
+		{"non-EU blocked", BlockNonEU(next, BlockOptions{}), "1.0.0.1:1", "/", 451, "Unavailable"},
+		{"EU passed", BlockNonEU(next, BlockOptions{}), "[::ffff:2.0.0.1]:1", "/", 200, "ok"},
+		{"custom", BlockEU(next, BlockOptions{Status: 403, Template: custom}), "2.0.0.1:1", "/", 403, "403 2.0.0.1 true"},
+		{"allowed path", BlockEU(next, BlockOptions{Allow: []string{"/healthz"}}), "2.0.0.1:1", "/healthz", 200, "ok"},
+		{"allowed path exact", BlockEU(next, BlockOptions{Allow: []string{"/healthz"}}), "2.0.0.1:1", "/healthz/x", 451, "Unavailable"},
+		{"allowed subtree", BlockEU(next, BlockOptions{Allow: []string{"/status/"}}), "2.0.0.1:1", "/status/ready", 200, "ok"},
+		{"trusted header", BlockEU(next, BlockOptions{Options: Options{TrustedHeaders: []string{FlyClientIPHeader}}}), "1.0.0.1:1", "/", 451, "Unavailable"},
+	} {
+		r := httptest.NewRequest("GET", tc.path, nil)
+		r.RemoteAddr = tc.remote
+		r.Header.Set(FlyClientIPHeader, "2.0.0.1")
+		w := httptest.NewRecorder()
+		tc.h.ServeHTTP(w, r)
+		if w.Code != tc.code || !strings.Contains(w.Body.String(), tc.body) {
+			t.Errorf("%s: got %d %q, want %d containing %q", tc.name, w.Code, w.Body, tc.code, tc.body)
+		}
+	}
+}
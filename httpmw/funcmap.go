@@ -0,0 +1,52 @@
+package httpmw
+
+import (
+	"html/template"
+	"net/http"
+	"sync"
+
+	"github.com/rmmh/eurip"
+)
+
+// FuncMap returns template functions describing the client that sent r:
+//
+//	isEU           whether the client is EU
+//	isUncertain    whether the client's location is unreliable
+//	clientCountry  the client's ISO 3166-1 country code, or ""
+//	countryName    eurip.CountryName, to name it: {{countryName clientCountry "de"}}
+//
+// The client is classified once, on first use, reusing the Result and
+// country stored by Middleware. Fail-safe templates can test {{if or isEU isUncertain}}.
+//
+// Templates need their functions when parsed, so parse with FuncMap(nil),
+// whose functions describe no client, then bind each request's functions to
+// a clone:
+//
+//	t := template.Must(page.Clone()).Funcs(opts.FuncMap(r))
+func (o *Options) FuncMap(r *http.Request) template.FuncMap {
+	client := sync.OnceValues(func() (eurip.Result, string) {
+		if r == nil {
+			return eurip.Result{}, ""
+		}
+		res, country, err := o.client(r)
+		if err != nil {
+			return eurip.Result{}, ""
+		}
+		return res, country
+	})
+	return template.FuncMap{
+		"isEU": func() bool {
+			res, _ := client()
+			return res.EU
+		},
+		"isUncertain": func() bool {
+			res, _ := client()
+			return res.Uncertain
+		},
+		"clientCountry": func() string {
+			_, country := client()
+			return country
+		},
+		"countryName": eurip.CountryName,
+	}
+}
@@ -0,0 +1,51 @@
+package httpmw
+
+import (
+	"html/template"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/rmmh/eurip"
+)
+
+func TestFuncMap(t *testing.T) {
+	o := &Options{}
+	page := template.Must(template.New("").Funcs(o.FuncMap(nil)).Parse(
+		`{{if or isEU isUncertain}}banner{{else}}none{{end}} {{isEU}} {{isUncertain}} {{clientCountry}}`))
+	for _, tc := range []struct {
+		remote string
+		stored *eurip.Result
+		want   string
+	}{
+		{"2.0.0.1:1", nil, "banner true false " + eurip.Country(netip.MustParseAddr("2.0.0.1"))},
+		{"1.0.0.1:1", nil, "none false false "},
+		{"1.0.0.1:1", &eurip.Result{Uncertain: true}, "banner false true "},
+		{"@", nil, "none false false "},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tc.remote
+		if tc.stored != nil {
+			r = r.WithContext(eurip.NewContext(r.Context(), *tc.stored))
+		}
+		var b strings.Builder
+		if err := template.Must(page.Clone()).Funcs(o.FuncMap(r)).Execute(&b, nil); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tc.want {
+			t.Errorf("%s, stored %v: got %q, want %q", tc.remote, tc.stored, b.String(), tc.want)
+		}
+	}
+
+	var b strings.Builder
+	if err := page.Execute(&b, nil); err != nil || b.String() != "none false false " {
+		t.Errorf("FuncMap(nil) template = %q, %v", b.String(), err)
+	}
+
+	b.Reset()
+	name := template.Must(template.New("").Funcs(o.FuncMap(nil)).Parse(`{{countryName "DE" "de-AT"}}`))
+	if err := name.Execute(&b, nil); err != nil || b.String() != "Deutschland" {
+		t.Errorf("countryName template = %q, %v", b.String(), err)
+	}
+}
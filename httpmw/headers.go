@@ -0,0 +1,83 @@
+package httpmw
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rmmh/eurip"
+)
+
+// Headers set by Options.ResponseHeaders and Options.RequestHeaders.
+// ClientEUHeader is "true" or "false", and ClientCountryHeader is the
+// client's ISO 3166-1 country code, or empty if the dataset has none.
+const (
+	ClientEUHeader      = "X-Client-EU"
+	ClientCountryHeader = "X-Client-Country"
+)
+
+// Cache key fragments returned by Options.CacheKey.
+const (
+	CacheKeyEU      = "eu"
+	CacheKeyNonEU   = "noneu"
+	CacheKeyUnknown = "unknown"
+)
+
+// CacheKey returns a cache key fragment for the classification of the client
+// that sent r: CacheKeyEU, CacheKeyNonEU, or CacheKeyUnknown if its address
+// can't be determined or its location is Uncertain or Unknown. Keying
+// cached responses on it, rather than on the client address, keeps the
+// variants to three.
+func (o *Options) CacheKey(r *http.Request) string {
+	res, err := o.Lookup(r)
+	switch {
+	case err != nil || res.Uncertain || res.Unknown:
+		return CacheKeyUnknown
+	case res.EU:
+		return CacheKeyEU
+	}
+	return CacheKeyNonEU
+}
+
+// vary adds ClientEUHeader to w's Vary header if o asks for it.
+func (o *Options) vary(w http.ResponseWriter) {
+	if !o.Vary {
+		return
+	}
+	for _, v := range w.Header().Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(name), ClientEUHeader) {
+				return
+			}
+		}
+	}
+	w.Header().Add("Vary", ClientEUHeader)
+}
+
+// setHeaders sets the classification headers o asks for, and returns the
+// request to pass on. If the client is unknown, the headers are left unset,
+// but client-sent request headers are still removed.
+func (o *Options) setHeaders(w http.ResponseWriter, r *http.Request, res eurip.Result, country string, known bool) *http.Request {
+	if !o.ResponseHeaders && !o.RequestHeaders {
+		return r
+	}
+	if o.RequestHeaders {
+		r = r.Clone(r.Context())
+		r.Header.Del(ClientEUHeader)
+		r.Header.Del(ClientCountryHeader)
+	}
+	if !known {
+		return r
+	}
+	set := func(h http.Header) {
+		h.Set(ClientEUHeader, strconv.FormatBool(res.EU))
+		h.Set(ClientCountryHeader, country)
+	}
+	if o.ResponseHeaders {
+		set(w.Header())
+	}
+	if o.RequestHeaders {
+		set(r.Header)
+	}
+	return r
+}
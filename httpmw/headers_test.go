@@ -0,0 +1,117 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rmmh/eurip"
+)
+
+func TestHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		opts           Options
+		remote         string
+		resp, upstream string // X-Client-EU values, "-" if unset
+	}{
+		{Options{}, "2.0.0.1:1", "-", "spoofed"},
+		{Options{ResponseHeaders: true}, "2.0.0.1:1", "true", "spoofed"},
+		{Options{RequestHeaders: true}, "2.0.0.1:1", "-", "true"},
+		{Options{ResponseHeaders: true, RequestHeaders: true}, "1.0.0.1:1", "false", "false"},
+		{Options{ResponseHeaders: true, RequestHeaders: true}, "@", "-", "-"},
+	} {
+		var upstream http.Header
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { upstream = r.Header })
+		for name, h := range map[string]http.Handler{
+			"Middleware": Middleware(next, tc.opts),
+			"BlockNonEU": BlockNonEU(next, BlockOptions{Options: tc.opts}),
+			"BlockEU":    BlockEU(next, BlockOptions{Options: tc.opts}),
+		} {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tc.remote
+			r.Header.Set(ClientEUHeader, "spoofed")
+			w := httptest.NewRecorder()
+			upstream = nil
+			h.ServeHTTP(w, r)
+			if upstream == nil {
+				continue // blocked
+			}
+			get := func(h http.Header) string {
+				if v := h.Values(ClientEUHeader); len(v) > 0 {
+					return v[0]
+				}
+				return "-"
+			}
+			if got := get(w.Header()); got != tc.resp {
+				t.Errorf("%s %+v from %s: response %s = %q, want %q", name, tc.opts, tc.remote, ClientEUHeader, got, tc.resp)
+			}
+			if got := get(upstream); got != tc.upstream {
+				t.Errorf("%s %+v from %s: request %s = %q, want %q", name, tc.opts, tc.remote, ClientEUHeader, got, tc.upstream)
+			}
+			if ok := len(w.Header().Values(ClientCountryHeader)) > 0; ok != (tc.resp != "-") {
+				t.Errorf("%s %+v from %s: response has %s: %v", name, tc.opts, tc.remote, ClientCountryHeader, ok)
+			}
+		}
+	}
+}
+
+func TestHeadersAllowed(t *testing.T) {
+	// Allowed paths aren't classified, but spoofed headers must still go.
+	var upstream http.Header
+	h := BlockEU(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { upstream = r.Header }),
+		BlockOptions{Options: Options{RequestHeaders: true}, Allow: []string{"/healthz"}})
+	r := httptest.NewRequest("GET", "/healthz", nil)
+	r.RemoteAddr = "2.0.0.1:1"
+	r.Header.Set(ClientEUHeader, "false")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if v := upstream.Values(ClientEUHeader); len(v) > 0 {
+		t.Errorf("allowed request has %s: %q", ClientEUHeader, v)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	o := &Options{}
+	for _, tc := range []struct {
+		remote string
+		stored *eurip.Result
+		want   string
+	}{
+		{"2.0.0.1:1", nil, CacheKeyEU},
+		{"1.0.0.1:1", nil, CacheKeyNonEU},
+		{"1.0.0.1:1", &eurip.Result{EU: true, Uncertain: true}, CacheKeyUnknown},
+		{"1.0.0.1:1", &eurip.Result{Unknown: true}, CacheKeyUnknown},
+		{"@", nil, CacheKeyUnknown},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tc.remote
+		if tc.stored != nil {
+			r = r.WithContext(eurip.NewContext(r.Context(), *tc.stored))
+		}
+		if got := o.CacheKey(r); got != tc.want {
+			t.Errorf("CacheKey(%s, stored %v) = %q, want %q", tc.remote, tc.stored, got, tc.want)
+		}
+	}
+}
+
+func TestVary(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for name, h := range map[string]http.Handler{
+		"Middleware":         Middleware(next, Options{Vary: true}),
+		"BlockEU, blocked":   BlockEU(next, BlockOptions{Options: Options{Vary: true}}),
+		"BlockNonEU, passed": BlockNonEU(next, BlockOptions{Options: Options{Vary: true}}),
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "2.0.0.1:1"
+		w := httptest.NewRecorder()
+		w.Header().Set("Vary", "Accept-Encoding, x-client-eu")
+		h.ServeHTTP(w, r)
+		if got := w.Header().Values("Vary"); len(got) != 1 {
+			t.Errorf("%s added a duplicate Vary: %q", name, got)
+		}
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got := w.Header().Get("Vary"); got != ClientEUHeader {
+			t.Errorf("%s: Vary = %q, want %q", name, got, ClientEUHeader)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Package httpmw classifies the clients of net/http servers with eurip.
+package httpmw
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/rmmh/eurip"
+)
+
+// Client IP headers set by CDNs and edge proxies. Each holds the single
+// address the CDN received the request from, so unlike X-Forwarded-For it
+// needs no hop parsing, but it is trivially spoofed unless every request
+// passes through that CDN.
+const (
+	CFConnectingIPHeader = "CF-Connecting-IP"
+	TrueClientIPHeader   = "True-Client-IP" // Akamai, Cloudflare Enterprise
+	FlyClientIPHeader    = "Fly-Client-IP"
+)
+
+// Options configures how requests are classified. The zero value classifies
+// the connection's peer address with eurip's default Matcher.
+type Options struct {
+	// Matcher classifies addresses. If nil, a default Matcher is used.
+	Matcher *eurip.Matcher
+
+	// TrustedHeaders lists client IP headers, such as CFConnectingIPHeader, to
+	// take the client address from, in order of preference. Headers that are
+	// missing or malformed are skipped. Only enable the headers of the CDN
+	// in front of the server, since clients can set any of them.
+	TrustedHeaders []string
+
+	// TrustedProxies lists the networks of the load balancers and proxies in
+	// front of the server. If set, headers are only believed when the peer
+	// is in one of them, and X-Forwarded-For is read right to left, skipping
+	// trusted hops: the first untrusted hop is the client. Hops further
+	// left were added by the client and aren't believed.
+	TrustedProxies []netip.Prefix
+
+	// CountryOverride, if set, returns a country the application knows the
+	// client to be in, as an ISO 3166-1 alpha-2 code, such as a signed-in
+	// user's billing country or one saved in a cookie. When it reports ok,
+	// the client is classified by that country instead of its address, and
+	// the Result has Provenance eurip.SourceRequest. It may be called more
+	// than once per request.
+	CountryOverride func(r *http.Request) (country string, ok bool)
+
+	// ResponseHeaders makes Middleware and the Block handlers set
+	// ClientEUHeader and ClientCountryHeader on responses, so single-page
+	// apps and edge caches can act on the classification.
+	ResponseHeaders bool
+
+	// RequestHeaders makes them set the headers on the request passed to
+	// next instead, for reverse proxies to forward upstream. Copies the
+	// client sent are removed first, so upstream servers can believe them.
+	RequestHeaders bool
+
+	// Vary makes Middleware and the Block handlers add ClientEUHeader to the
+	// Vary header of responses. Behind a proxy that sets it on requests,
+	// with RequestHeaders or Envoy ext_authz, shared caches then keep one
+	// variant per classification rather than one per client address.
+	Vary bool
+}
+
+var defaultMatcher = eurip.NewMatcher()
+
+func (o *Options) matcher() *eurip.Matcher {
+	if o.Matcher == nil {
+		return defaultMatcher
+	}
+	return o.Matcher
+}
+
+// ClientAddr returns the address of the client that sent r: the first valid
+// trusted header, or else the client named by X-Forwarded-For if
+// TrustedProxies is set, or else the peer address from r.RemoteAddr. It
+// returns an error wrapping eurip.ErrInvalidAddr if the peer address or a
+// trusted X-Forwarded-For hop is malformed.
+func (o *Options) ClientAddr(r *http.Request) (netip.Addr, error) {
+	peer, err := remoteAddr(r)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	if len(o.TrustedProxies) > 0 && !o.trusted(peer) {
+		return peer, nil
+	}
+	for _, h := range o.TrustedHeaders {
+		if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get(h))); err == nil {
+			return addr.Unmap(), nil
+		}
+	}
+	if len(o.TrustedProxies) == 0 {
+		return peer, nil
+	}
+	return o.forwardedFor(r, peer)
+}
+
+// forwardedFor returns the rightmost untrusted X-Forwarded-For hop, or the
+// leftmost hop if they are all trusted.
+func (o *Options) forwardedFor(r *http.Request, peer netip.Addr) (netip.Addr, error) {
+	values := r.Header.Values("X-Forwarded-For")
+	if len(values) == 0 {
+		return peer, nil
+	}
+	// Repeated headers are one list, in order.
+	hops := strings.Split(strings.Join(values, ","), ",")
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		addr, err := parseHop(hop)
+		if err != nil {
+			return netip.Addr{}, fmt.Errorf("%w: X-Forwarded-For hop %q", eurip.ErrInvalidAddr, hop)
+		}
+		client = addr
+		if !o.trusted(addr) {
+			break
+		}
+	}
+	return client, nil
+}
+
+// parseHop parses an X-Forwarded-For entry, which some proxies write with a
+// port.
+func parseHop(s string) (netip.Addr, error) {
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	return addr.Unmap(), err
+}
+
+func (o *Options) trusted(addr netip.Addr) bool {
+	for _, p := range o.TrustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteAddr parses r.RemoteAddr, which is normally host:port but is just a
+// host for some test and unix socket setups.
+func remoteAddr(r *http.Request) (netip.Addr, error) {
+	addr, err := parseHop(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("%w: RemoteAddr %q", eurip.ErrInvalidAddr, r.RemoteAddr)
+	}
+	return addr, nil
+}
+
+// Lookup classifies the client that sent r, by its CountryOverride if that
+// has one, or else by address. If Middleware or a Block handler has already
+// classified it, it returns that Result from r's context.
+func (o *Options) Lookup(r *http.Request) (eurip.Result, error) {
+	if res, ok := eurip.FromContext(r.Context()); ok {
+		return res, nil
+	}
+	if country, ok := o.countryOverride(r); ok {
+		return o.matcher().CountryResult(country), nil
+	}
+	addr, err := o.ClientAddr(r)
+	if err != nil {
+		return eurip.Result{}, err
+	}
+	return o.matcher().Lookup(addr), nil
+}
+
+// countryOverride returns r's CountryOverride, upper-cased, if it has one.
+func (o *Options) countryOverride(r *http.Request) (string, bool) {
+	if o.CountryOverride == nil {
+		return "", false
+	}
+	country, ok := o.CountryOverride(r)
+	return strings.ToUpper(country), ok
+}
+
+// countryKey is the context key Middleware and the Block handlers store the
+// client's country under, next to its Result.
+type countryKey struct{}
+
+// withClient returns r with res and country in its context.
+func withClient(r *http.Request, res eurip.Result, country string) *http.Request {
+	ctx := eurip.NewContext(r.Context(), res)
+	return r.WithContext(context.WithValue(ctx, countryKey{}, country))
+}
+
+// client is like Lookup, but also returns the client's country: its
+// CountryOverride, or else the country of its address, or "" if unknown.
+// Both come from r's context if Middleware or a Block handler stored them.
+func (o *Options) client(r *http.Request) (eurip.Result, string, error) {
+	res, stored := eurip.FromContext(r.Context())
+	if country, ok := r.Context().Value(countryKey{}).(string); stored && ok {
+		return res, country, nil
+	}
+	if country, ok := o.countryOverride(r); ok {
+		if !stored {
+			res = o.matcher().CountryResult(country)
+		}
+		return res, country, nil
+	}
+	addr, err := o.ClientAddr(r)
+	if err != nil {
+		if stored {
+			return res, "", nil
+		}
+		return eurip.Result{}, "", err
+	}
+	m := o.matcher()
+	if !stored {
+		res = m.Lookup(addr)
+	}
+	return res, m.Country(addr), nil
+}
+
+// Middleware returns a handler that classifies each request's client and
+// passes the request to next with the Result in its context, for
+// eurip.FromContext and Options.Lookup in later handlers, and the client's
+// country, for the headers and FuncMap of later layers. Requests whose
+// client address can't be determined are passed on unchanged.
+func Middleware(next http.Handler, opts Options) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, country, err := opts.client(r)
+		if err == nil {
+			r = withClient(r, res, country)
+		}
+		opts.vary(w)
+		next.ServeHTTP(w, opts.setHeaders(w, r, res, country, err == nil))
+	})
+}
+
+// IsFromEU reports whether the client that sent r is probably in the EU. It
+// returns false if the client address is unknown.
+func (o *Options) IsFromEU(r *http.Request) bool {
+	res, err := o.Lookup(r)
+	return err == nil && res.EU
+}
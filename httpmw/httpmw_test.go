@@ -0,0 +1,175 @@
+package httpmw
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/rmmh/eurip"
+)
+
+func TestClientAddr(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		trusted []string
+		want    string
+	}{
+		{"peer", "2.0.0.1:1234", nil, nil, "2.0.0.1"},
+		{"peer v6", "[2001:420:4000:1::]:443", nil, nil, "2001:420:4000:1::"},
+		{"peer mapped", "[::ffff:2.0.0.1]:443", nil, nil, "2.0.0.1"},
+		{"peer without port", "2.0.0.1", nil, nil, "2.0.0.1"},
+		{"untrusted header ignored", "1.0.0.1:1", map[string]string{CFConnectingIPHeader: "2.0.0.1"}, nil, "1.0.0.1"},
+		{"cloudflare", "1.0.0.1:1", map[string]string{CFConnectingIPHeader: "2.0.0.1"}, []string{CFConnectingIPHeader}, "2.0.0.1"},
+		{"other header ignored", "1.0.0.1:1", map[string]string{TrueClientIPHeader: "2.0.0.1"}, []string{FlyClientIPHeader}, "1.0.0.1"},
+		{"preference order", "1.0.0.1:1",
+			map[string]string{TrueClientIPHeader: "2.0.0.1", FlyClientIPHeader: "2.0.0.2"},
+			[]string{FlyClientIPHeader, TrueClientIPHeader}, "2.0.0.2"},
+		{"malformed header skipped", "1.0.0.1:1",
+			map[string]string{CFConnectingIPHeader: "2.0.0.1, 3.0.0.1", TrueClientIPHeader: " 2.0.0.3 "},
+			[]string{CFConnectingIPHeader, TrueClientIPHeader}, "2.0.0.3"},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tc.remote
+		for k, v := range tc.headers {
+			r.Header.Set(k, v)
+		}
+		o := Options{TrustedHeaders: tc.trusted}
+		if got, err := o.ClientAddr(r); err != nil || got.String() != tc.want {
+			t.Errorf("%s: ClientAddr() = %v, %v, want %s", tc.name, got, err, tc.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "1.0.0.1:1"
+	r.Header.Set(CFConnectingIPHeader, "2.0.0.1")
+	o := Options{TrustedHeaders: []string{CFConnectingIPHeader}}
+	if !o.IsFromEU(r) {
+		t.Errorf("IsFromEU() = false with trusted %s: 2.0.0.1", CFConnectingIPHeader)
+	}
+	if (&Options{}).IsFromEU(r) {
+		t.Errorf("IsFromEU() = true for peer 1.0.0.1")
+	}
+
+	r.RemoteAddr = "@"
+	if _, err := (&Options{}).Lookup(r); !errors.Is(err, eurip.ErrInvalidAddr) {
+		t.Errorf("Lookup() with RemoteAddr %q = %v, want ErrInvalidAddr", r.RemoteAddr, err)
+	}
+}
+
+func TestClientAddrTrustedProxies(t *testing.T) {
+	o := Options{
+		TrustedHeaders: []string{CFConnectingIPHeader},
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")},
+	}
+	for _, tc := range []struct {
+		name    string
+		remote  string
+		xff     []string
+		cdn     string
+		want    string
+		wantErr bool
+	}{
+		{"direct", "2.0.0.1:1", nil, "", "2.0.0.1", false},
+		{"direct spoofing xff", "1.0.0.1:1", []string{"2.0.0.1"}, "", "1.0.0.1", false},
+		{"direct spoofing cdn header", "1.0.0.1:1", nil, "2.0.0.1", "1.0.0.1", false},
+		{"via proxy", "10.0.0.1:1", []string{"2.0.0.1"}, "", "2.0.0.1", false},
+		{"via proxy, cdn header", "10.0.0.1:1", []string{"1.0.0.1"}, "2.0.0.1", "2.0.0.1", false},
+		{"via proxy, no header", "10.0.0.1:1", nil, "", "10.0.0.1", false},
+		{"client prepends spoofed hop", "10.0.0.1:1", []string{"2.0.0.1, 1.0.0.1"}, "", "1.0.0.1", false},
+		{"chain of proxies", "10.0.0.1:1", []string{"1.0.0.1, 2.0.0.1, 10.2.0.1", "[fd00::1]:80"}, "", "2.0.0.1", false},
+		{"all hops trusted", "10.0.0.1:1", []string{"10.3.0.1, 10.2.0.1"}, "", "10.3.0.1", false},
+		{"hop with port", "10.0.0.1:1", []string{"2.0.0.1:4711"}, "", "2.0.0.1", false},
+		{"garbage left of client", "10.0.0.1:1", []string{"nonsense, 2.0.0.1"}, "", "2.0.0.1", false},
+		{"malformed trusted hop", "10.0.0.1:1", []string{"2.0.0.1, nonsense, 10.2.0.1"}, "", "", true},
+		{"empty hop", "10.0.0.1:1", []string{"2.0.0.1,"}, "", "", true},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tc.remote
+		for _, v := range tc.xff {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if tc.cdn != "" {
+			r.Header.Set(CFConnectingIPHeader, tc.cdn)
+		}
+		got, err := o.ClientAddr(r)
+		if tc.wantErr {
+			if !errors.Is(err, eurip.ErrInvalidAddr) {
+				t.Errorf("%s: ClientAddr() = %v, %v, want ErrInvalidAddr", tc.name, got, err)
+			}
+			continue
+		}
+		if err != nil || got.String() != tc.want {
+			t.Errorf("%s: ClientAddr() = %v, %v, want %s", tc.name, got, err, tc.want)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var got []string
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, ok := eurip.FromContext(r.Context())
+		// Lookup must reuse the stored Result, not classify the peer.
+		again, err := (&Options{}).Lookup(r)
+		got = append(got, fmt.Sprintf("%v %v %v %v", res.EU, ok, again.EU, err))
+	}), Options{TrustedHeaders: []string{CFConnectingIPHeader}})
+	for _, remote := range []string{"1.0.0.1:1", "@"} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remote
+		r.Header.Set(CFConnectingIPHeader, "2.0.0.1")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	want := []string{"true true true <nil>", `false false false eurip: invalid address: RemoteAddr "@"`}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Middleware results:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCountryOverride(t *testing.T) {
+	o := Options{ResponseHeaders: true, CountryOverride: func(r *http.Request) (string, bool) {
+		c, err := r.Cookie("country")
+		if err != nil {
+			return "", false
+		}
+		return c.Value, true
+	}}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "1.0.0.1:1"
+	if res, err := o.Lookup(r); err != nil || res.EU || res.Provenance != "" {
+		t.Errorf("Lookup() without the cookie = %+v, %v, want not EU by address", res, err)
+	}
+	r.AddCookie(&http.Cookie{Name: "country", Value: "de"})
+	if res, err := o.Lookup(r); err != nil || !res.EU || res.Provenance != eurip.SourceRequest {
+		t.Errorf("Lookup() with country=de = %+v, %v, want EU from the request", res, err)
+	}
+
+	w := httptest.NewRecorder()
+	Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), o).ServeHTTP(w, r)
+	if eu, country := w.Header().Get(ClientEUHeader), w.Header().Get(ClientCountryHeader); eu != "true" || country != "DE" {
+		t.Errorf("headers with country=de = %s: %q, %s: %q, want true, DE", ClientEUHeader, eu, ClientCountryHeader, country)
+	}
+
+	// Later layers read the country from the context rather than asking
+	// CountryOverride again.
+	calls := 0
+	counted := o
+	counted.CountryOverride = func(r *http.Request) (string, bool) {
+		calls++
+		return o.CountryOverride(r)
+	}
+	var country string
+	inner := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		country = counted.FuncMap(r)["clientCountry"].(func() string)()
+	})
+	Middleware(Middleware(inner, counted), counted).ServeHTTP(httptest.NewRecorder(), r)
+	if calls != 1 || country != "DE" {
+		t.Errorf("nested Middleware and FuncMap: %d CountryOverride calls, clientCountry %q, want 1, DE", calls, country)
+	}
+}
@@ -0,0 +1,63 @@
+package eurip
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// IsLocal reports whether addr is an IPv6 unique local address, in
+// fc00::/7, or a link-local one, in fe80::/10 or 169.254.0.0/16. Service
+// meshes and sidecar proxies often connect from these, so a request from
+// one says nothing about the client's location. IPv4-mapped IPv6 addresses
+// are treated as IPv4.
+func IsLocal(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.Is6() && addr.IsPrivate() || addr.IsLinkLocalUnicast()
+}
+
+// A LocalPolicy says how a Matcher answers for local addresses, as reported
+// by IsLocal. They are otherwise special-purpose addresses like any other,
+// left to the UnclassifiedPolicy.
+type LocalPolicy int
+
+const (
+	// LocalUnclassified leaves them to the UnclassifiedPolicy. It is the
+	// default.
+	LocalUnclassified LocalPolicy = iota
+	// LocalError makes Check return an error wrapping ErrLocal. Lookup and
+	// IsFromEU, which can't, report them as not EU.
+	LocalError
+	// LocalUnknown reports them as not EU but Unknown, as strict Matchers
+	// report public addresses without data, and Check returns an error
+	// wrapping ErrUnknown.
+	LocalUnknown
+	// LocalAsLocal reports them as not EU but Local, without an error, so
+	// callers can treat them as coming from their own network.
+	LocalAsLocal
+)
+
+// WithLocalPolicy sets how the Matcher answers for local addresses, taking
+// precedence over the UnclassifiedPolicy for them.
+func WithLocalPolicy(p LocalPolicy) Option {
+	return func(m *Matcher) {
+		m.local = p
+	}
+}
+
+// checkLocal answers for a local addr by m's LocalPolicy, which must not be
+// LocalUnclassified.
+func (m *Matcher) checkLocal(addr netip.Addr) (Result, error) {
+	var r Result
+	var err error
+	switch m.local {
+	case LocalError:
+		err = fmt.Errorf("%w: %s", ErrLocal, addr)
+	case LocalUnknown:
+		r.Unknown = true
+		err = fmt.Errorf("%w: %s is local", ErrUnknown, addr)
+	case LocalAsLocal:
+		r.Local = true
+	}
+	m.observe(addr, r)
+	return r, err
+}
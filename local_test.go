@@ -0,0 +1,62 @@
+package eurip
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestIsLocal(t *testing.T) {
+	for ip, want := range map[string]bool{
+		"fd00::1":            true,
+		"fc00::1":            true,
+		"fe80::1":            true,
+		"169.254.169.254":    true,
+		"::ffff:169.254.0.1": true,
+		"10.0.0.1":           false,
+		"::1":                false,
+		"ff02::1":            false,
+		"2001:420:4000:1::":  false,
+	} {
+		if got := IsLocal(netip.MustParseAddr(ip)); got != want {
+			t.Errorf("IsLocal(%s) = %v, want %v", ip, got, want)
+		}
+	}
+}
+
+func TestLocalPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy         LocalPolicy
+		unknown, local bool
+		err            error
+	}{
+		{LocalError, false, false, ErrLocal},
+		{LocalUnknown, true, false, ErrUnknown},
+		{LocalAsLocal, false, true, nil},
+	} {
+		// The local policy takes precedence over failing safe.
+		m := NewMatcher(WithLocalPolicy(tc.policy), WithUnclassifiedPolicy(UnclassifiedEU))
+		for _, ip := range []string{"fd12:3456::1", "fe80::1"} {
+			addr := netip.MustParseAddr(ip)
+			r, err := m.Check(addr)
+			if r.EU || r.Unknown != tc.unknown || r.Local != tc.local || !errors.Is(err, tc.err) || (err == nil) != (tc.err == nil) {
+				t.Errorf("policy %d: Check(%s) = %+v, %v, want Unknown %v, Local %v, %v", tc.policy, ip, r, err, tc.unknown, tc.local, tc.err)
+			}
+			if m.IsFromEU(net.ParseIP(ip)) {
+				t.Errorf("policy %d: IsFromEU(%s) = true", tc.policy, ip)
+			}
+			if e := m.Explain(addr); e.Source != SourcePolicy || e.Result != r {
+				t.Errorf("policy %d: Explain(%s) = %+v, want the local policy's %+v", tc.policy, ip, e, r)
+			}
+		}
+		if r, _ := m.Check(netip.MustParseAddr("10.0.0.1")); !r.EU {
+			t.Errorf("policy %d: Check(10.0.0.1) = %+v, want the unclassified policy's EU", tc.policy, r)
+		}
+	}
+
+	m := NewMatcher(WithUnclassifiedPolicy(UnclassifiedEU))
+	if r, _ := m.Check(netip.MustParseAddr("fd00::1")); !r.EU || r.Local {
+		t.Errorf("LocalUnclassified: Check(fd00::1) = %+v, want the unclassified policy's EU", r)
+	}
+}
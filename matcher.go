@@ -0,0 +1,459 @@
+package eurip
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+)
+
+// A Matcher tests addresses against a configurable view of the embedded
+// dataset. Create one with NewMatcher; the package-level functions use a
+// Matcher with default options.
+//
+// A Matcher is safe for concurrent use. Lookups never block, and may run in
+// parallel with Refresh, LoadTorExits, and override and annotation changes,
+// each of which swaps in new tables whole: a lookup sees either the old
+// tables or the new ones, never a mix.
+type Matcher struct {
+	// cur is the view lookups use. It is replaced whole when the dataset is
+	// refreshed, so each lookup sees a single dataset.
+	cur atomic.Pointer[view]
+	// torExits is the list LoadTorExits last loaded, or nil.
+	torExits atomic.Pointer[map[netip.Addr]struct{}]
+	// overrides is nil if there are none. Writers hold overridesMu and
+	// replace it with a changed copy.
+	overrides   atomic.Pointer[PrefixMap[Result]]
+	overridesMu sync.Mutex
+	// annotations is nil if there are none, and is replaced like
+	// overrides.
+	annotations   atomic.Pointer[PrefixMap[[]string]]
+	annotationsMu sync.Mutex
+	// sampled caches the weight tables of RandomEUAddr, RandomNonEUAddr,
+	// and their IPv6 variants for the view and overrides they were last
+	// built from.
+	sampled atomic.Pointer[samplerSet]
+
+	feedback        *feedback
+	vars            *expvarMap
+	refreshHook     func(RefreshEvent)
+	unclassified    UnclassifiedPolicy
+	local           LocalPolicy
+	strict          bool
+	uk, microstates bool
+}
+
+// A view is the tables a Matcher takes from one dataset.
+type view struct {
+	// data is the dataset the tables below are taken from.
+	data *dataset
+	// tables are unioned: an address is EU if any table contains it. eu
+	// maps their prefixes to the tables' names, and is what lookups read.
+	tables []table
+	eu     *PrefixMap[string]
+	// uncertain holds ranges whose geolocation is unreliable.
+	uncertain table
+	// known holds every range the source database lists, for strict.
+	known table
+	// strict says whether IPv4 and IPv6 lookups are strict: if m is, and
+	// known has data for the family.
+	strict [2]bool
+	// datacenter holds hosting and cloud ranges.
+	datacenter table
+	countries  countryTable
+	// countryEU says whether each country code counts as EU for tables.
+	countryEU []bool
+
+	// stepsOnce guards steps, WorstCaseSteps' bound on the table walks of
+	// IPv4 and IPv6 lookups, which is computed on first use.
+	stepsOnce sync.Once
+	steps     [2]Steps
+}
+
+// table is a pair of bitset DAGs, one per address family.
+type table struct {
+	v4, v6 []uint16
+	name   string // for Explain
+}
+
+// An Option configures a Matcher.
+type Option func(*Matcher)
+
+// WithUKTreatedAsEU makes the UK count as part of the EU, for pre-Brexit
+// analysis or for treating UK visitors the same as EU ones. It is off by
+// default. The embedded dataset has no UK table, so the option only has an
+// effect on one loaded with NewMatcherFromBytes or Refresh; see
+// CheckTables.
+func WithUKTreatedAsEU(uk bool) Option {
+	return func(m *Matcher) {
+		m.uk = uk
+	}
+}
+
+// WithMicrostatesTreatedAsEU makes Andorra, Monaco, San Marino, and Vatican
+// City count as part of the EU. They aren't members, but use the euro and
+// are often in scope for VAT or privacy purposes. It is off by default. The
+// embedded dataset has no microstate table, so the option only has an effect
+// on one loaded with NewMatcherFromBytes or Refresh; see CheckTables.
+func WithMicrostatesTreatedAsEU(microstates bool) Option {
+	return func(m *Matcher) {
+		m.microstates = microstates
+	}
+}
+
+// An UnclassifiedPolicy says how a Matcher answers for addresses it can't
+// classify: invalid or nil ones, and special-purpose ones like 10.0.0.0/8 or
+// fe80::/10 that aren't located anywhere.
+type UnclassifiedPolicy int
+
+const (
+	// UnclassifiedNotEU reports them as not EU. It is the default.
+	UnclassifiedNotEU UnclassifiedPolicy = iota
+	// UnclassifiedEU reports them as EU, and Uncertain, failing safe toward
+	// showing compliance UI.
+	UnclassifiedEU
+	// UnclassifiedError makes Check return an error: one wrapping
+	// ErrInvalidAddr for invalid addresses, or ErrUnclassified. Lookup and
+	// IsFromEU, which can't, report them as not EU.
+	UnclassifiedError
+)
+
+// WithUnclassifiedPolicy sets how the Matcher answers for addresses it can't
+// classify.
+func WithUnclassifiedPolicy(p UnclassifiedPolicy) Option {
+	return func(m *Matcher) {
+		m.unclassified = p
+	}
+}
+
+// WithStrict makes the Matcher distinguish public addresses the dataset has
+// no data for from confident non-EU answers: lookups of them set
+// Result.Unknown, and Check returns an error wrapping ErrUnknown. This needs
+// a dataset generated with its known table, which the embedded one lacks;
+// for address families without one, the option is ignored, as CheckTables
+// reports. Special-purpose addresses follow the UnclassifiedPolicy instead.
+// It is off by default.
+func WithStrict(strict bool) Option {
+	return func(m *Matcher) {
+		m.strict = strict
+	}
+}
+
+// NewMatcher returns a Matcher over the embedded dataset.
+func NewMatcher(opts ...Option) *Matcher {
+	return newMatcher(embeddedDataset(), opts)
+}
+
+func newMatcher(d *dataset, opts []Option) *Matcher {
+	m := &Matcher{}
+	// Options may read the dataset, as WithExpvar does its version.
+	m.cur.Store(&view{data: d})
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.cur.Store(m.newView(d))
+	return m
+}
+
+// newView returns a view of d with the tables m's options select.
+func (m *Matcher) newView(d *dataset) *view {
+	v := &view{
+		data:       d,
+		tables:     []table{d.eu},
+		uncertain:  d.uncertain,
+		known:      d.known,
+		datacenter: d.datacenter,
+		countries:  d.countries,
+	}
+	if m.uk {
+		v.tables = append(v.tables, d.uk)
+	}
+	if m.microstates {
+		v.tables = append(v.tables, d.micro)
+	}
+	layers := make([]dagLayer[string], len(v.tables))
+	for i, t := range v.tables {
+		layers[i] = dagLayer[string]{t.v4, t.v6, t.name}
+	}
+	v.eu = dagPrefixMap(layers...)
+	v.strict = [2]bool{m.strict && !d.known.empty(IPv4), m.strict && !d.known.empty(IPv6)}
+	v.countryEU = make([]bool, len(d.countries.codes))
+	for i, code := range d.countries.codes {
+		v.countryEU[i] = m.countsAsEU(code)
+	}
+	return v
+}
+
+// load returns the view lookups should use.
+func (m *Matcher) load() *view {
+	return m.cur.Load()
+}
+
+var defaultMatcher = NewMatcher()
+
+// IsFromEU returns true if the given IP is probably in the EU.
+func (m *Matcher) IsFromEU(ipAddress net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ipAddress)
+	addr = addr.Unmap()
+	if _, r, ok := m.override(addr); ok {
+		m.observe(addr, r)
+		return r.EU
+	}
+	if m.local != LocalUnclassified && IsLocal(addr) {
+		r, _ := m.checkLocal(addr)
+		return r.EU
+	}
+	if m.unclassified != UnclassifiedNotEU && (!ok || isSpecialUse(addr)) {
+		r, _ := m.checkUnclassified(addr)
+		return r.EU
+	}
+	if !ok {
+		m.observe(addr, Result{})
+		return false
+	}
+	v := m.load()
+	eu := v.isEU(addr)
+	if m.feedback != nil || m.vars != nil {
+		m.observe(addr, Result{EU: eu, Uncertain: v.uncertain.contains(addr)})
+	}
+	return eu
+}
+
+// Result is the outcome of looking up an address.
+type Result struct {
+	// EU is true if the address is probably in the EU.
+	EU bool
+	// Uncertain is true if the address is in an anycast or satellite range,
+	// which geolocate poorly whatever EU says, or couldn't be classified and
+	// got EU from UnclassifiedEU. Callers that must fail safe, say by showing
+	// a consent banner, should treat these as EU. The embedded dataset has no
+	// table of anycast and satellite ranges, so only UnclassifiedEU sets it
+	// there.
+	Uncertain bool
+	// Unknown is true if the address is public but the dataset has no data
+	// for it, so EU is a guess. It is only set by WithStrict matchers, for
+	// addresses of a family the dataset lacks, and for local addresses by
+	// LocalUnknown matchers.
+	Unknown bool
+	// Local is true if the address is local, as reported by IsLocal. It is
+	// only set by LocalAsLocal matchers.
+	Local bool
+	// Provenance is SourceRequest if the Result came from something the
+	// application knows about the request, as from CountryResult, rather
+	// than from looking up an address. It is otherwise empty, and Explain
+	// tells which data decided.
+	Provenance Source
+}
+
+// Lookup classifies addr. IPv4-mapped IPv6 addresses are treated as IPv4.
+func (m *Matcher) Lookup(addr netip.Addr) Result {
+	r, _ := m.Check(addr)
+	return r
+}
+
+// Check is like Lookup, but returns an error for addresses it can't
+// classify if m has the UnclassifiedError policy, or for addresses the
+// dataset has no data for if m is strict. Overridden addresses never
+// return an error.
+func (m *Matcher) Check(addr netip.Addr) (Result, error) {
+	addr = addr.Unmap()
+	if _, r, ok := m.override(addr); ok {
+		m.observe(addr, r)
+		return r, nil
+	}
+	return m.check(m.load(), addr)
+}
+
+// check is like Check against v, without overrides, for an unmapped addr.
+func (m *Matcher) check(v *view, addr netip.Addr) (Result, error) {
+	if m.local != LocalUnclassified && IsLocal(addr) {
+		return m.checkLocal(addr)
+	}
+	if m.unclassified != UnclassifiedNotEU && (!addr.IsValid() || isSpecialUse(addr)) {
+		return m.checkUnclassified(addr)
+	}
+	if addr.IsValid() && !isSpecialUse(addr) && !v.data.hasFamily(addr) {
+		r := Result{Unknown: true}
+		m.observe(addr, r)
+		return r, fmt.Errorf("%w: no %s tables for %s", ErrFamilyUnavailable, familyOf(addr), addr)
+	}
+	r := Result{EU: v.isEU(addr), Uncertain: v.uncertain.contains(addr)}
+	var err error
+	if !r.EU && addr.IsValid() && !isSpecialUse(addr) && v.isStrict(addr) && !v.known.contains(addr) {
+		r.Unknown = true
+		err = fmt.Errorf("%w: no data for %s", ErrUnknown, addr)
+	}
+	m.observe(addr, r)
+	return r, err
+}
+
+func (m *Matcher) checkUnclassified(addr netip.Addr) (Result, error) {
+	var r Result
+	var err error
+	switch {
+	case m.unclassified == UnclassifiedEU:
+		r = Result{EU: true, Uncertain: true}
+	case !addr.IsValid():
+		err = ErrInvalidAddr
+	default:
+		err = fmt.Errorf("%w: %s is special-purpose", ErrUnclassified, addr)
+	}
+	m.observe(addr, r)
+	return r, err
+}
+
+// observe reports a lookup to the feedback hook and expvar counters, if set.
+func (m *Matcher) observe(addr netip.Addr, r Result) {
+	if m.vars != nil {
+		m.vars.count(r)
+	}
+	if m.feedback != nil {
+		m.feedback.observe(m, addr, r)
+	}
+}
+
+// LookupString is like Check, but parses s first. It returns an error
+// wrapping ErrInvalidAddr if s isn't an IP address.
+func (m *Matcher) LookupString(s string) (Result, error) {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %w", ErrInvalidAddr, err)
+	}
+	return m.Check(addr)
+}
+
+// isEU reports whether any table holds addr, which must be unmapped.
+func (v *view) isEU(addr netip.Addr) bool {
+	return v.eu.contains(addr)
+}
+
+// MatchedPrefix is like the package-level MatchedPrefix, but uses m's view
+// of the dataset, with m's overrides applied.
+func (m *Matcher) MatchedPrefix(addr netip.Addr) netip.Prefix {
+	return m.matchedPrefix(addr.Unmap())
+}
+
+// matchedPrefix returns the prefix that decides whether addr, which must be
+// unmapped, is EU: the override holding it, the EU prefix holding it, or
+// else the smallest non-EU block of any table holding it. The prefix is
+// invalid if addr is.
+func (m *Matcher) matchedPrefix(addr netip.Addr) netip.Prefix {
+	if p, _, ok := m.override(addr); ok {
+		return p
+	}
+	p, _ := m.load().matchedTable(addr)
+	return p
+}
+
+// matchedTable is like matchedPrefix, but also returns the name of the
+// table holding addr, or "" if it is not EU.
+func (v *view) matchedTable(addr netip.Addr) (netip.Prefix, string) {
+	if !addr.IsValid() {
+		return netip.Prefix{}, ""
+	}
+	p, table, _ := v.eu.block(addr)
+	return p, table
+}
+
+// isStrict reports whether lookups of addr, which must be valid and unmapped,
+// are strict.
+func (v *view) isStrict(addr netip.Addr) bool {
+	if addr.Is4() {
+		return v.strict[0]
+	}
+	return v.strict[1]
+}
+
+// empty reports whether t holds no addresses of family f.
+func (t table) empty(f Family) bool {
+	data := t.v4
+	if f == IPv6 {
+		data = t.v6
+	}
+	return len(data) < 2 || data[0] == 0 && data[1] == 0
+}
+
+// contains reports whether t holds addr, which must be unmapped.
+func (t table) contains(addr netip.Addr) bool {
+	if addr.Is4() {
+		a := addr.As4()
+		return walk(a[:], t.v4)
+	}
+	if addr.Is6() {
+		a := addr.As16()
+		return walk(a[:], t.v6)
+	}
+	return false
+}
+
+// AppendEUPrefixes is like the package-level AppendEUPrefixes, but uses m's
+// view of the dataset, with m's overrides applied.
+//
+// Without overrides, nothing is allocated beyond growing dst; with them,
+// the prefixes are taken from the overridden ranges, which are built on
+// each call.
+func (m *Matcher) AppendEUPrefixes(dst []netip.Prefix, within netip.Prefix) []netip.Prefix {
+	v, o := m.load(), m.overrides.Load()
+	if !within.IsValid() {
+		dst = v.appendOverriddenPrefixes(dst, netip.PrefixFrom(netip.IPv4Unspecified(), 0), o)
+		return v.appendOverriddenPrefixes(dst, netip.PrefixFrom(netip.IPv6Unspecified(), 0), o)
+	}
+	within = within.Masked()
+	if within.Addr().Is4In6() && within.Bits() >= 96 {
+		within = netip.PrefixFrom(within.Addr().Unmap(), within.Bits()-96)
+	}
+	return v.appendOverriddenPrefixes(dst, within, o)
+}
+
+// appendOverriddenPrefixes is like appendPrefixes, with the EU answers of
+// overrides o, which may be nil, applied.
+func (v *view) appendOverriddenPrefixes(dst []netip.Prefix, within netip.Prefix, o *PrefixMap[Result]) []netip.Prefix {
+	if o == nil {
+		return v.appendPrefixes(dst, within)
+	}
+	for _, r := range v.appendRanges(nil, within, o) {
+		if r.EU {
+			dst = r.AppendPrefixes(dst)
+		}
+	}
+	return dst
+}
+
+// appendPrefixes appends the prefixes of every table, within one family, in
+// address order and aggregated.
+func (v *view) appendPrefixes(dst []netip.Prefix, within netip.Prefix) []netip.Prefix {
+	return v.eu.appendCovered(dst, within)
+}
+
+func comparePrefixes(a, b netip.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return a.Bits() - b.Bits()
+}
+
+// aggregate merges sorted prefixes in place, dropping those covered by an
+// earlier prefix and joining sibling pairs into their parent, and returns
+// the shortened slice.
+func aggregate(ps []netip.Prefix) []netip.Prefix {
+	out := ps[:0]
+	for _, p := range ps {
+		if n := len(out); n > 0 && out[n-1].Contains(p.Addr()) {
+			continue
+		}
+		out = append(out, p)
+		for n := len(out); n >= 2; n = len(out) {
+			a, b := out[n-2], out[n-1]
+			if a.Bits() != b.Bits() || a.Bits() == 0 {
+				break
+			}
+			parent := netip.PrefixFrom(a.Addr(), a.Bits()-1).Masked()
+			if parent.Addr() != a.Addr() || !parent.Contains(b.Addr()) {
+				break
+			}
+			out = append(out[:n-2], parent)
+		}
+	}
+	return out
+}
@@ -0,0 +1,179 @@
+package eurip
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+// buildTable encodes prefixes as a bitset DAG, without the deduplication
+// that process.py does.
+func buildTable(prefixes ...string) []uint16 {
+	type node struct {
+		children [16]*node
+		set      uint16
+	}
+	root := &node{}
+	for _, s := range prefixes {
+		p := netip.MustParsePrefix(s).Masked()
+		a := p.Addr().AsSlice()
+		nibble := func(i int) int { return int(a[i/2]>>(4*(1-i%2))) & 0xf }
+		n := root
+		i := 0
+		for ; (i+1)*4 < p.Bits(); i++ {
+			if n.children[nibble(i)] == nil {
+				n.children[nibble(i)] = &node{}
+			}
+			n = n.children[nibble(i)]
+		}
+		span := 1 << ((i+1)*4 - p.Bits())
+		for c := nibble(i); c < nibble(i)+span; c++ {
+			n.set |= 1 << c
+		}
+	}
+	// Lay nodes out breadth-first, then emit them with child indexes.
+	order := []*node{root}
+	index := map[*node]int{}
+	size := 0
+	for i := 0; i < len(order); i++ {
+		n := order[i]
+		index[n] = size
+		size += 2
+		for _, c := range n.children {
+			if c != nil {
+				order = append(order, c)
+				size++
+			}
+		}
+	}
+	var data []uint16
+	for _, n := range order {
+		var hasChild uint16
+		var ptrs []uint16
+		for c, child := range n.children {
+			if child != nil {
+				hasChild |= 1 << c
+				ptrs = append(ptrs, uint16(index[child]))
+			}
+		}
+		data = append(data, hasChild, n.set)
+		data = append(data, ptrs...)
+	}
+	return data
+}
+
+func TestBuildTable(t *testing.T) {
+	data := buildTable("10.0.0.0/8", "192.168.4.0/22")
+	for _, tc := range []struct {
+		ip      string
+		is_euro bool
+	}{
+		{"10.1.2.3", true},
+		{"11.0.0.0", false},
+		{"192.168.3.255", false},
+		{"192.168.4.0", true},
+		{"192.168.7.255", true},
+		{"192.168.8.0", false},
+	} {
+		if got := walk(net.ParseIP(tc.ip).To4(), data); got != tc.is_euro {
+			t.Errorf("walk(%s) = %v, want %v", tc.ip, got, tc.is_euro)
+		}
+	}
+}
+
+func withTables(t *testing.T, tables map[*[]uint16][]uint16) {
+	for v, data := range tables {
+		old := *v
+		*v = data
+		t.Cleanup(func() { *v = old })
+	}
+}
+
+func TestWithUKTreatedAsEU(t *testing.T) {
+	withTables(t, map[*[]uint16][]uint16{
+		&gbV4Data: buildTable("81.2.69.0/24", "2.16.0.0/12"),
+		&gbV6Data: buildTable("2620:db8::/32"),
+	})
+	uk := NewMatcher(WithUKTreatedAsEU(true))
+	for _, m := range []*Matcher{NewMatcher(), NewMatcher(WithUKTreatedAsEU(false)), uk} {
+		want := m == uk
+		for _, ip := range []string{"81.2.69.142", "2.16.0.0", "2620:db8::1"} {
+			if skipV6(ip) {
+				continue
+			}
+			if got := m.IsFromEU(net.ParseIP(ip)); got != want {
+				t.Errorf("IsFromEU(%s) = %v with UK treated as EU: %v", ip, got, want)
+			}
+		}
+		if !m.IsFromEU(net.ParseIP("2.0.0.1")) {
+			t.Errorf("IsFromEU(2.0.0.1) = false")
+		}
+	}
+
+	got := uk.AppendEUPrefixes(nil, netip.MustParsePrefix("2.0.0.0/10"))
+	want := []netip.Prefix{netip.MustParsePrefix("2.0.0.0/11"), netip.MustParsePrefix("2.32.0.0/12")}
+	if !slices.Equal(got, want) {
+		t.Errorf("AppendEUPrefixes(2.0.0.0/10) = %v, want %v", got, want)
+	}
+}
+
+func TestWithMicrostatesTreatedAsEU(t *testing.T) {
+	withTables(t, map[*[]uint16][]uint16{
+		&microV4Data: buildTable("198.51.100.0/24"),
+		&microV6Data: buildTable("2620:db9::/32"),
+	})
+	micro := NewMatcher(WithMicrostatesTreatedAsEU(true))
+	both := NewMatcher(WithMicrostatesTreatedAsEU(true), WithUKTreatedAsEU(true))
+	for _, m := range []*Matcher{NewMatcher(), NewMatcher(WithMicrostatesTreatedAsEU(false)), micro, both} {
+		want := m == micro || m == both
+		for _, ip := range []string{"198.51.100.1", "2620:db9::1"} {
+			if skipV6(ip) {
+				continue
+			}
+			if got := m.IsFromEU(net.ParseIP(ip)); got != want {
+				t.Errorf("IsFromEU(%s) = %v with microstates treated as EU: %v", ip, got, want)
+			}
+		}
+	}
+}
+
+func TestLookupUncertain(t *testing.T) {
+	withTables(t, map[*[]uint16][]uint16{
+		&uncertainV4Data: buildTable("2.1.0.0/16", "198.51.100.0/24"),
+	})
+	m := NewMatcher()
+	for _, tc := range []struct {
+		ip   string
+		want Result
+	}{
+		{"2.0.0.1", Result{EU: true}},
+		{"2.1.0.1", Result{EU: true, Uncertain: true}},
+		{"::ffff:2.1.0.1", Result{EU: true, Uncertain: true}},
+		{"198.51.100.7", Result{Uncertain: true}},
+		{"1.0.0.1", Result{}},
+		{"2001:420:4000:1::", Result{EU: true}},
+	} {
+		if skipV6(tc.ip) {
+			continue
+		}
+		if got := m.Lookup(netip.MustParseAddr(tc.ip)); got != tc.want {
+			t.Errorf("Lookup(%s) = %+v, want %+v", tc.ip, got, tc.want)
+		}
+	}
+	if got := m.Lookup(netip.Addr{}); got != (Result{}) {
+		t.Errorf("Lookup(invalid) = %+v, want zero Result", got)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	var ps []netip.Prefix
+	for _, s := range []string{"10.0.0.0/9", "10.0.0.0/16", "10.128.0.0/10", "10.192.0.0/10", "11.0.0.0/8", "12.0.0.0/8"} {
+		ps = append(ps, netip.MustParsePrefix(s))
+	}
+	got := aggregate(ps)
+	want := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/7"), netip.MustParsePrefix("12.0.0.0/8")}
+	if !slices.Equal(got, want) {
+		t.Errorf("aggregate() = %v, want %v", got, want)
+	}
+}
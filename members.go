@@ -0,0 +1,63 @@
+package eurip
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// MembersVersion is the date from which EUMembers, EEAMembers, and
+// SchengenMembers are correct, as YYYY-MM-DD: Bulgaria and Romania joined
+// Schengen in full on 2025-01-01. It changes whenever a set does, so
+// applications can record which definition a decision was made under. It
+// dates the sets, not any dataset: the embedded one, Version 20180501, was
+// built with an older list.
+const MembersVersion = "2025-01-01"
+
+// A CountrySet is an immutable set of ISO 3166-1 alpha-2 country codes.
+type CountrySet struct {
+	m map[string]bool
+}
+
+func newCountrySet(codes string) CountrySet {
+	s := CountrySet{m: map[string]bool{}}
+	for _, c := range strings.Fields(codes) {
+		s.m[c] = true
+	}
+	return s
+}
+
+// The membership lists process.py generates datasets with, for reuse in
+// logic that isn't about addresses, such as billing countries. The embedded
+// 20180501 dataset predates them: it was generated with the codes EL and UK,
+// which GeoLite2 doesn't use, so Greece and the UK are missing from its EU
+// table. Datasets generated with the current process.py match EUMembers.
+var (
+	// EUMembers holds the member states of the European Union.
+	EUMembers = newCountrySet("AT BE BG CY CZ DE DK EE ES FI FR GR HR HU " +
+		"IE IT LT LU LV MT NL PL PT RO SE SI SK")
+	// EEAMembers holds the members of the European Economic Area, across
+	// which the GDPR applies: the EU, Iceland, Liechtenstein, and Norway.
+	EEAMembers = newCountrySet(strings.Join(EUMembers.Codes(), " ") + " IS LI NO")
+	// SchengenMembers holds the members of the Schengen Area: the EU except
+	// Cyprus and Ireland, and Iceland, Liechtenstein, Norway, and
+	// Switzerland.
+	SchengenMembers = newCountrySet("AT BE BG CZ DE DK EE ES FI FR GR HR HU " +
+		"IT LT LU LV MT NL PL PT RO SE SI SK CH IS LI NO")
+)
+
+// Contains reports whether s holds the country with the given ISO code,
+// which may be in either case.
+func (s CountrySet) Contains(iso string) bool {
+	return s.m[strings.ToUpper(iso)]
+}
+
+// Len returns the number of countries in s.
+func (s CountrySet) Len() int {
+	return len(s.m)
+}
+
+// Codes returns a new slice of the codes in s, in alphabetical order.
+func (s CountrySet) Codes() []string {
+	return slices.Sorted(maps.Keys(s.m))
+}
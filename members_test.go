@@ -0,0 +1,40 @@
+package eurip
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMembers(t *testing.T) {
+	if n := EUMembers.Len(); n != 27 {
+		t.Errorf("EUMembers.Len() = %d, want 27", n)
+	}
+	eu := EUMembers.Codes()
+	if !slices.IsSorted(eu) {
+		t.Errorf("EUMembers.Codes() = %v, not sorted", eu)
+	}
+	for _, c := range eu {
+		if !EEAMembers.Contains(c) {
+			t.Errorf("EEAMembers lacks EU member %s", c)
+		}
+		if want := c != "CY" && c != "IE"; SchengenMembers.Contains(c) != want {
+			t.Errorf("SchengenMembers.Contains(%s) = %v, want %v", c, !want, want)
+		}
+	}
+	if got := slices.DeleteFunc(EEAMembers.Codes(), EUMembers.Contains); !slices.Equal(got, []string{"IS", "LI", "NO"}) {
+		t.Errorf("EEAMembers outside the EU = %v", got)
+	}
+	if got := slices.DeleteFunc(SchengenMembers.Codes(), EUMembers.Contains); !slices.Equal(got, []string{"CH", "IS", "LI", "NO"}) {
+		t.Errorf("SchengenMembers outside the EU = %v", got)
+	}
+	if !EUMembers.Contains("de") || EUMembers.Contains("GB") || EUMembers.Contains("") {
+		t.Errorf("EUMembers.Contains(de, GB, \"\") = %v, %v, %v, want true, false, false",
+			EUMembers.Contains("de"), EUMembers.Contains("GB"), EUMembers.Contains(""))
+	}
+
+	codes := EUMembers.Codes()
+	codes[0] = "XX"
+	if EUMembers.Contains("XX") || !EUMembers.Contains("AT") {
+		t.Error("modifying Codes() changed EUMembers")
+	}
+}
@@ -0,0 +1,340 @@
+package eurip
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net/netip"
+	"time"
+)
+
+// WriteMMDB writes the EU prefix set to w as a MaxMind DB (MMDB) file.
+//
+// The database is an IPv6 tree with IPv4 networks under ::/96 and an alias
+// for ::ffff:0:0/96, as MaxMind lays out its own databases; IPv6 ranges, and
+// overrides, inside those two blocks are left out. EU networks
+// carry a GeoIP2 Country style record, {"country": {"is_in_european_union":
+// true, "iso_code": "DE"}}, so existing readers can query it unchanged. The
+// iso_code is present where there is country data, and non-EU space
+// without country data has no record.
+func WriteMMDB(w io.Writer) error {
+	return defaultMatcher.WriteMMDB(w)
+}
+
+// WriteMMDB is like the package-level WriteMMDB, but uses m's view of the
+// dataset, with m's overrides applied.
+func (m *Matcher) WriteMMDB(w io.Writer) error {
+	v := m.load()
+	var t mmdbTree
+	t.nodes = make([]mmdbNode, 1)
+	var ipv4Compat [16]byte
+	v4Root := t.path(ipv4Compat, 96)
+	mapped := netip.IPv6Unspecified().As16()
+	mapped[10], mapped[11] = 0xff, 0xff
+	t.link(mapped, 96, v4Root)
+
+	records := map[Range]int32{}
+	var prefixes []netip.Prefix
+	for _, r := range v.appendAllRanges(nil, m.overrides.Load()) {
+		if !r.EU && r.Country == "" {
+			continue
+		}
+		key := Range{EU: r.EU, Country: r.Country}
+		record, ok := records[key]
+		if !ok {
+			record = t.addRecord(mmdbCountryRecord(r.EU, r.Country))
+			records[key] = record
+		}
+		prefixes = appendRangePrefixes(prefixes[:0], r.Start, r.End)
+		if r.Start.Is6() {
+			for _, q := range mmdbIPv4Blocks {
+				prefixes = excludePrefix(prefixes, q)
+			}
+		}
+		for _, p := range prefixes {
+			addr, bits := p.Addr().As16(), p.Bits()
+			if p.Addr().Is4() {
+				bits += 96
+			}
+			t.insert(addr, bits, record)
+		}
+	}
+	return t.write(w, mmdbMetadata(len(t.nodes), v.data.version))
+}
+
+// mmdbIPv4Blocks are the IPv6 blocks WriteMMDB points at the IPv4 tree.
+// IPv6 ranges leave them out, so that they never replace IPv4 answers.
+var mmdbIPv4Blocks = []netip.Prefix{
+	netip.MustParsePrefix("::/96"),
+	netip.MustParsePrefix("::ffff:0:0/96"),
+}
+
+// excludePrefix returns the fewest prefixes covering the space of ps, which
+// must be disjoint, outside q: prefixes within q are dropped, and those
+// holding q are replaced by the siblings of q's ancestors below them.
+func excludePrefix(ps []netip.Prefix, q netip.Prefix) []netip.Prefix {
+	out := ps[:0:0]
+	for _, p := range ps {
+		switch {
+		case !p.Overlaps(q):
+			out = append(out, p)
+		case p.Bits() < q.Bits():
+			for bits := p.Bits() + 1; bits <= q.Bits(); bits++ {
+				a := netip.PrefixFrom(q.Addr(), bits).Masked().Addr().As16()
+				a[(bits-1)/8] ^= 0x80 >> ((bits - 1) % 8)
+				out = append(out, netip.PrefixFrom(netip.AddrFrom16(a), bits).Masked())
+			}
+		}
+	}
+	return out
+}
+
+func mmdbCountryRecord(eu bool, country string) []byte {
+	var fields int
+	if eu {
+		fields++
+	}
+	if country != "" {
+		fields++
+	}
+	var e mmdbEncoder
+	e.mapHeader(1)
+	e.string("country")
+	e.mapHeader(fields)
+	if eu {
+		e.string("is_in_european_union")
+		e.bool(true)
+	}
+	if country != "" {
+		e.string("iso_code")
+		e.string(country)
+	}
+	return e.buf
+}
+
+func mmdbMetadata(nodeCount int, version string) func(recordSize int) []byte {
+	epoch := uint64(0)
+	if t, err := time.Parse("20060102", version); err == nil {
+		epoch = uint64(t.Unix())
+	}
+	return func(recordSize int) []byte {
+		var e mmdbEncoder
+		e.mapHeader(9)
+		e.string("binary_format_major_version")
+		e.uint(mmdbUint16, 2)
+		e.string("binary_format_minor_version")
+		e.uint(mmdbUint16, 0)
+		e.string("build_epoch")
+		e.uint(mmdbUint64, epoch)
+		e.string("database_type")
+		e.string("EurIP-Country")
+		e.string("description")
+		e.mapHeader(1)
+		e.string("en")
+		e.string("EU IP ranges derived from GeoLite2 " + version)
+		e.string("ip_version")
+		e.uint(mmdbUint16, 6)
+		e.string("languages")
+		e.arrayHeader(0)
+		e.string("node_count")
+		e.uint(mmdbUint32, uint64(nodeCount))
+		e.string("record_size")
+		e.uint(mmdbUint16, uint64(recordSize))
+		return e.buf
+	}
+}
+
+// mmdbNode holds the left (0 bit) and right (1 bit) records of a search tree
+// node. A positive record is a node index, a negative one is -1-i for data
+// record i, and zero means no data (the root is never a child).
+type mmdbNode struct {
+	rec [2]int32
+}
+
+type mmdbTree struct {
+	nodes   []mmdbNode
+	records [][]byte
+}
+
+func (t *mmdbTree) addRecord(b []byte) int32 {
+	t.records = append(t.records, b)
+	return int32(-len(t.records))
+}
+
+func addrBit(addr [16]byte, i int) int {
+	return int(addr[i/8]>>(7-i%8)) & 1
+}
+
+// path returns the node reached after following the first bits of addr,
+// creating nodes as needed.
+func (t *mmdbTree) path(addr [16]byte, bits int) int32 {
+	n := int32(0)
+	for i := 0; i < bits; i++ {
+		b := addrBit(addr, i)
+		next := t.nodes[n].rec[b]
+		if next <= 0 {
+			// Split a covering data record (or no data) into a new node.
+			t.nodes = append(t.nodes, mmdbNode{[2]int32{next, next}})
+			next = int32(len(t.nodes) - 1)
+			t.nodes[n].rec[b] = next
+		}
+		n = next
+	}
+	return n
+}
+
+// link points the record for the prefix addr/bits at an existing record.
+func (t *mmdbTree) link(addr [16]byte, bits int, rec int32) {
+	n := t.path(addr, bits-1)
+	t.nodes[n].rec[addrBit(addr, bits-1)] = rec
+}
+
+// insert points the records for the prefix addr/bits at rec. Where the prefix
+// already holds a node, such as the IPv4 tree's root for 0.0.0.0/0, rec fills
+// the records below it instead, so that no node (or alias of one) is lost.
+func (t *mmdbTree) insert(addr [16]byte, bits int, rec int32) {
+	if bits == 0 {
+		t.fill(0, rec)
+		return
+	}
+	n := t.path(addr, bits-1)
+	b := addrBit(addr, bits-1)
+	if next := t.nodes[n].rec[b]; next > 0 {
+		t.fill(next, rec)
+		return
+	}
+	t.nodes[n].rec[b] = rec
+}
+
+// fill points every record below node n that isn't a node at rec.
+func (t *mmdbTree) fill(n, rec int32) {
+	for b, next := range t.nodes[n].rec {
+		if next > 0 {
+			t.fill(next, rec)
+		} else {
+			t.nodes[n].rec[b] = rec
+		}
+	}
+}
+
+func (t *mmdbTree) write(w io.Writer, metadata func(recordSize int) []byte) error {
+	nodeCount := len(t.nodes)
+	offsets := make([]int, len(t.records))
+	dataSize := 0
+	for i, r := range t.records {
+		offsets[i] = dataSize
+		dataSize += len(r)
+	}
+	recordSize := 24
+	if largest := nodeCount + 16 + dataSize; largest >= 1<<28 {
+		recordSize = 32
+	} else if largest >= 1<<24 {
+		recordSize = 28
+	}
+	value := func(r int32) uint32 {
+		switch {
+		case r > 0:
+			return uint32(r)
+		case r < 0:
+			return uint32(nodeCount + 16 + offsets[-1-r])
+		}
+		return uint32(nodeCount)
+	}
+
+	bw := bufio.NewWriter(w)
+	var node [8]byte
+	for _, n := range t.nodes {
+		l, r := value(n.rec[0]), value(n.rec[1])
+		switch recordSize {
+		case 24:
+			node[0], node[1], node[2] = byte(l>>16), byte(l>>8), byte(l)
+			node[3], node[4], node[5] = byte(r>>16), byte(r>>8), byte(r)
+		case 28:
+			node[0], node[1], node[2] = byte(l>>16), byte(l>>8), byte(l)
+			node[3] = byte(l>>24)<<4 | byte(r>>24)&0xf
+			node[4], node[5], node[6] = byte(r>>16), byte(r>>8), byte(r)
+		case 32:
+			binary.BigEndian.PutUint32(node[0:], l)
+			binary.BigEndian.PutUint32(node[4:], r)
+		}
+		bw.Write(node[:recordSize/4])
+	}
+	bw.Write(make([]byte, 16))
+	for _, r := range t.records {
+		bw.Write(r)
+	}
+	bw.WriteString("\xab\xcd\xefMaxMind.com")
+	bw.Write(metadata(recordSize))
+	return bw.Flush()
+}
+
+// MaxMind DB data section types.
+const (
+	mmdbString  = 2
+	mmdbUint16  = 5
+	mmdbUint32  = 6
+	mmdbMap     = 7
+	mmdbUint64  = 9
+	mmdbArray   = 11
+	mmdbBoolean = 14
+)
+
+// mmdbEncoder serializes values in the MaxMind DB data section format.
+type mmdbEncoder struct {
+	buf []byte
+}
+
+func (e *mmdbEncoder) control(typ, size int) {
+	ctrl := byte(0)
+	if typ <= 7 {
+		ctrl = byte(typ) << 5
+	}
+	var ext []byte
+	switch {
+	case size < 29:
+		ctrl |= byte(size)
+	case size < 285:
+		ctrl |= 29
+		ext = []byte{byte(size - 29)}
+	case size < 65821:
+		ctrl |= 30
+		s := size - 285
+		ext = []byte{byte(s >> 8), byte(s)}
+	default:
+		ctrl |= 31
+		s := size - 65821
+		ext = []byte{byte(s >> 16), byte(s >> 8), byte(s)}
+	}
+	e.buf = append(e.buf, ctrl)
+	if typ > 7 {
+		e.buf = append(e.buf, byte(typ-7))
+	}
+	e.buf = append(e.buf, ext...)
+}
+
+func (e *mmdbEncoder) string(s string) {
+	e.control(mmdbString, len(s))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *mmdbEncoder) uint(typ int, v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	n := 0
+	for n < 8 && b[n] == 0 {
+		n++
+	}
+	e.control(typ, 8-n)
+	e.buf = append(e.buf, b[n:]...)
+}
+
+func (e *mmdbEncoder) bool(v bool) {
+	size := 0
+	if v {
+		size = 1
+	}
+	e.control(mmdbBoolean, size)
+}
+
+func (e *mmdbEncoder) mapHeader(n int)   { e.control(mmdbMap, n) }
+func (e *mmdbEncoder) arrayHeader(n int) { e.control(mmdbArray, n) }
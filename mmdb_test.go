@@ -0,0 +1,197 @@
+package eurip
+
+import (
+	"bytes"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+// mmdbReader is a minimal MaxMind DB reader for checking WriteMMDB output.
+type mmdbReader struct {
+	t          *testing.T
+	db         []byte
+	nodeCount  int
+	recordSize int
+}
+
+func newMMDBReader(t *testing.T, db []byte) *mmdbReader {
+	marker := []byte("\xab\xcd\xefMaxMind.com")
+	i := bytes.LastIndex(db, marker)
+	if i < 0 {
+		t.Fatal("metadata marker not found")
+	}
+	r := &mmdbReader{t: t, db: db}
+	meta, _ := r.decode(db[i+len(marker):], 0)
+	m := meta.(map[string]any)
+	if m["ip_version"] != uint64(6) || m["binary_format_major_version"] != uint64(2) {
+		t.Fatalf("unexpected metadata %v", m)
+	}
+	r.nodeCount = int(m["node_count"].(uint64))
+	r.recordSize = int(m["record_size"].(uint64))
+	return r
+}
+
+func (r *mmdbReader) record(node, bit int) int {
+	size := r.recordSize / 4
+	b := r.db[node*size : (node+1)*size]
+	get := func(b []byte) int {
+		v := 0
+		for _, x := range b {
+			v = v<<8 | int(x)
+		}
+		return v
+	}
+	switch r.recordSize {
+	case 28:
+		if bit == 0 {
+			return int(b[3]>>4)<<24 | get(b[:3])
+		}
+		return int(b[3]&0xf)<<24 | get(b[4:])
+	default:
+		return get(b[bit*size/2 : (bit+1)*size/2])
+	}
+}
+
+func (r *mmdbReader) lookup(addr netip.Addr) any {
+	a := addr.As16()
+	if addr.Is4() {
+		a[10], a[11] = 0, 0 // IPv4 lives under ::/96
+	}
+	node := 0
+	for i := 0; i < 128 && node < r.nodeCount; i++ {
+		node = r.record(node, addrBit(a, i))
+	}
+	if node == r.nodeCount {
+		return nil
+	}
+	if node < r.nodeCount {
+		r.t.Fatalf("lookup(%s) ran off the tree", addr)
+	}
+	data := r.db[r.nodeCount*r.recordSize/4+16:]
+	v, _ := r.decode(data, node-r.nodeCount-16)
+	return v
+}
+
+func (r *mmdbReader) decode(b []byte, off int) (any, int) {
+	ctrl := b[off]
+	off++
+	typ := int(ctrl >> 5)
+	if typ == 0 {
+		typ = int(b[off]) + 7
+		off++
+	}
+	size := int(ctrl & 0x1f)
+	switch size {
+	case 29:
+		size = 29 + int(b[off])
+		off++
+	case 30:
+		size = 285 + int(b[off])<<8 | int(b[off+1])
+		off += 2
+	case 31:
+		size = 65821 + (int(b[off])<<16 | int(b[off+1])<<8 | int(b[off+2]))
+		off += 3
+	}
+	switch typ {
+	case mmdbString:
+		return string(b[off : off+size]), off + size
+	case mmdbUint16, mmdbUint32, mmdbUint64:
+		v := uint64(0)
+		for _, x := range b[off : off+size] {
+			v = v<<8 | uint64(x)
+		}
+		return v, off + size
+	case mmdbBoolean:
+		return size != 0, off
+	case mmdbMap:
+		m := map[string]any{}
+		for i := 0; i < size; i++ {
+			var k, v any
+			k, off = r.decode(b, off)
+			v, off = r.decode(b, off)
+			m[k.(string)] = v
+		}
+		return m, off
+	case mmdbArray:
+		a := []any{}
+		for i := 0; i < size; i++ {
+			var v any
+			v, off = r.decode(b, off)
+			a = append(a, v)
+		}
+		return a, off
+	}
+	r.t.Fatalf("unsupported MMDB type %d", typ)
+	return nil, 0
+}
+
+func TestWriteMMDB(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteMMDB(&buf); err != nil {
+		t.Fatal(err)
+	}
+	r := newMMDBReader(t, buf.Bytes())
+	for _, tc := range []struct {
+		ip      string
+		is_euro bool
+	}{
+		{"2.0.0.1", true},
+		{"1.0.0.1", false},
+		{"2.15.255.255", true},
+		{"2.16.0.0", false},
+		{"::ffff:2.0.0.1", true},
+		{"::0", false},
+		{"2001:420:4000:1::", true},
+	} {
+		if skipV6(tc.ip) {
+			continue
+		}
+		rec := r.lookup(netip.MustParseAddr(tc.ip))
+		if !tc.is_euro {
+			if rec != nil {
+				t.Errorf("lookup(%s) = %v, want no record", tc.ip, rec)
+			}
+			continue
+		}
+		fields, _ := rec.(map[string]any)
+		country, ok := fields["country"].(map[string]any)
+		if rec == nil || !ok || country["is_in_european_union"] != true {
+			t.Errorf("lookup(%s) = %v, want EU record", tc.ip, rec)
+		}
+	}
+}
+
+func TestWriteMMDBCoveringIPv6Override(t *testing.T) {
+	var plain bytes.Buffer
+	if err := NewMatcher().WriteMMDB(&plain); err != nil {
+		t.Fatal(err)
+	}
+	want := newMMDBReader(t, plain.Bytes())
+	for _, override := range []string{"::/0", "::/8", "::/64"} {
+		m := NewMatcher()
+		m.SetOverride(netip.MustParsePrefix(override), Result{EU: true})
+		var buf bytes.Buffer
+		if err := m.WriteMMDB(&buf); err != nil {
+			t.Fatal(err)
+		}
+		r := newMMDBReader(t, buf.Bytes())
+		// IPv4 answers, including through the ::ffff:0:0/96 alias, are
+		// untouched by the IPv6 override.
+		for _, ip := range []string{"1.0.0.1", "2.0.0.1", "2.16.0.0", "::ffff:1.0.0.1", "::ffff:2.0.0.1"} {
+			addr := netip.MustParseAddr(ip)
+			if got, want := r.lookup(addr), want.lookup(addr); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: lookup(%s) = %v, want %v", override, ip, got, want)
+			}
+		}
+		if skipV6(override) {
+			continue
+		}
+		addr := netip.MustParseAddr("::1:0:0:1") // in the override, outside ::/96
+		fields, _ := r.lookup(addr).(map[string]any)
+		country, _ := fields["country"].(map[string]any)
+		if country["is_in_european_union"] != true {
+			t.Errorf("%s: lookup(%s) = %v, want EU record", override, addr, fields)
+		}
+	}
+}
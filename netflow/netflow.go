@@ -0,0 +1,349 @@
+// Package netflow decodes NetFlow v5, NetFlow v9, and IPFIX export packets,
+// classifying each flow's source and destination with eurip, so flow
+// collectors can apply the same dataset as request-time checks.
+//
+// sFlow, which samples packet headers rather than exporting flow records,
+// is not supported.
+package netflow
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"github.com/rmmh/eurip"
+)
+
+// ErrMalformed means a packet is truncated, has an unknown version, or has
+// sets whose lengths don't fit it.
+var ErrMalformed = errors.New("netflow: malformed packet")
+
+// A Flow is one flow record's addresses and counters, with the
+// classification of each address. Fields the exporter's template omits are
+// zero.
+type Flow struct {
+	Src, Dst             netip.Addr
+	SrcPort, DstPort     uint16
+	Protocol             uint8
+	Packets, Bytes       uint64
+	SrcResult, DstResult eurip.Result
+}
+
+// Information elements read from v9 and IPFIX records. The two share
+// numbering for these.
+const (
+	fieldBytes    = 1
+	fieldPackets  = 2
+	fieldProtocol = 4
+	fieldSrcPort  = 7
+	fieldSrcV4    = 8
+	fieldDstPort  = 11
+	fieldDstV4    = 12
+	fieldSrcV6    = 27
+	fieldDstV6    = 28
+)
+
+// varLen is the IPFIX field length of variable-length fields, whose length
+// is prefixed to each value.
+const varLen = 0xffff
+
+// A field is one entry of a template. Enterprise-specific fields are kept
+// only to skip their values.
+type field struct {
+	id, len    uint16
+	enterprise bool
+}
+
+// templateKey scopes template IDs, which exporters assign per source ID (v9)
+// or observation domain (IPFIX).
+type templateKey struct {
+	exporter netip.Addr
+	version  uint16
+	domain   uint32
+	id       uint16
+}
+
+// A Decoder decodes export packets, remembering the v9 and IPFIX templates
+// they define. The zero value is ready to use, with eurip's default Matcher.
+// A Decoder is not safe for concurrent use.
+//
+// It remembers at most 4096 templates per exporter, across its source IDs
+// and observation domains, so exporters can't grow it without bound.
+// Templates beyond that are ignored until some are withdrawn, as if they
+// hadn't arrived.
+type Decoder struct {
+	// Matcher classifies addresses. If nil, a default Matcher is used.
+	Matcher *eurip.Matcher
+
+	templates map[templateKey][]field
+	// counts is the number of templates of each exporter.
+	counts map[netip.Addr]int
+}
+
+// maxTemplates bounds the templates a Decoder remembers per exporter.
+const maxTemplates = 4096
+
+var defaultMatcher = eurip.NewMatcher()
+
+func (d *Decoder) matcher() *eurip.Matcher {
+	if d.Matcher == nil {
+		return defaultMatcher
+	}
+	return d.Matcher
+}
+
+// Decode decodes one export packet received from exporter, returning its
+// flows. v9 and IPFIX data sets whose template hasn't arrived yet are
+// skipped; exporters resend templates periodically, so flows are decoded
+// once the first has. Options templates and their data are ignored.
+func (d *Decoder) Decode(exporter netip.Addr, b []byte) ([]Flow, error) {
+	if len(b) < 2 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrMalformed, len(b))
+	}
+	var flows []Flow
+	var err error
+	switch v := binary.BigEndian.Uint16(b); v {
+	case 5:
+		flows, err = decodeV5(b)
+	case 9:
+		flows, err = d.decodeV9(exporter.Unmap(), b)
+	case 10:
+		flows, err = d.decodeIPFIX(exporter.Unmap(), b)
+	default:
+		return nil, fmt.Errorf("%w: version %d", ErrMalformed, v)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := d.matcher()
+	for i := range flows {
+		f := &flows[i]
+		if f.Src.IsValid() {
+			f.SrcResult = m.Lookup(f.Src)
+		}
+		if f.Dst.IsValid() {
+			f.DstResult = m.Lookup(f.Dst)
+		}
+	}
+	return flows, nil
+}
+
+// decodeV5 decodes a NetFlow v5 packet: a 24-byte header holding the record
+// count, then fixed 48-byte IPv4 records.
+func decodeV5(b []byte) ([]Flow, error) {
+	const headerLen, recordLen = 24, 48
+	if len(b) < headerLen {
+		return nil, fmt.Errorf("%w: v5 header is %d bytes", ErrMalformed, len(b))
+	}
+	n := int(binary.BigEndian.Uint16(b[2:]))
+	if len(b) < headerLen+n*recordLen {
+		return nil, fmt.Errorf("%w: v5 packet of %d bytes holds fewer than %d records", ErrMalformed, len(b), n)
+	}
+	flows := make([]Flow, n)
+	for i := range flows {
+		r := b[headerLen+i*recordLen:]
+		flows[i] = Flow{
+			Src:      netip.AddrFrom4([4]byte(r[0:4])),
+			Dst:      netip.AddrFrom4([4]byte(r[4:8])),
+			Packets:  uint64(binary.BigEndian.Uint32(r[16:])),
+			Bytes:    uint64(binary.BigEndian.Uint32(r[20:])),
+			SrcPort:  binary.BigEndian.Uint16(r[32:]),
+			DstPort:  binary.BigEndian.Uint16(r[34:]),
+			Protocol: r[38],
+		}
+	}
+	return flows, nil
+}
+
+// decodeV9 decodes a NetFlow v9 packet: a 20-byte header ending in the
+// source ID, then flowsets. Flowset 0 holds templates, 1 options templates,
+// and 256 and up data records for that template.
+func (d *Decoder) decodeV9(exporter netip.Addr, b []byte) ([]Flow, error) {
+	const headerLen = 20
+	if len(b) < headerLen {
+		return nil, fmt.Errorf("%w: v9 header is %d bytes", ErrMalformed, len(b))
+	}
+	key := templateKey{exporter: exporter, version: 9, domain: binary.BigEndian.Uint32(b[16:])}
+	return d.decodeSets(b[headerLen:], key, 0)
+}
+
+// decodeIPFIX decodes an IPFIX message: a 16-byte header holding the message
+// length and ending in the observation domain, then sets. Set 2 holds
+// templates, 3 options templates, and 256 and up data records.
+func (d *Decoder) decodeIPFIX(exporter netip.Addr, b []byte) ([]Flow, error) {
+	const headerLen = 16
+	if len(b) < headerLen {
+		return nil, fmt.Errorf("%w: IPFIX header is %d bytes", ErrMalformed, len(b))
+	}
+	n := int(binary.BigEndian.Uint16(b[2:]))
+	if n < headerLen || n > len(b) {
+		return nil, fmt.Errorf("%w: IPFIX message length %d in %d bytes", ErrMalformed, n, len(b))
+	}
+	key := templateKey{exporter: exporter, version: 10, domain: binary.BigEndian.Uint32(b[12:])}
+	return d.decodeSets(b[headerLen:n], key, 2)
+}
+
+// decodeSets decodes the sets following a v9 or IPFIX header. templateSet
+// is the ID of template sets in this version.
+func (d *Decoder) decodeSets(b []byte, key templateKey, templateSet uint16) ([]Flow, error) {
+	var flows []Flow
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, fmt.Errorf("%w: %d trailing bytes", ErrMalformed, len(b))
+		}
+		id, n := binary.BigEndian.Uint16(b), int(binary.BigEndian.Uint16(b[2:]))
+		if n < 4 || n > len(b) {
+			return nil, fmt.Errorf("%w: set %d length %d in %d bytes", ErrMalformed, id, n, len(b))
+		}
+		body := b[4:n]
+		b = b[n:]
+		switch {
+		case id == templateSet:
+			if err := d.readTemplates(body, key); err != nil {
+				return nil, err
+			}
+		case id >= 256:
+			key.id = id
+			if fields, ok := d.templates[key]; ok {
+				var err error
+				if flows, err = appendRecords(flows, body, fields); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return flows, nil
+}
+
+// readTemplates records the templates in a template set. Each is an ID and
+// a field count, then each field's ID and length; IPFIX fields with the
+// high bit of their ID set are followed by an enterprise number. An IPFIX
+// template with no fields withdraws that ID; v9 has no withdrawals, so
+// there one is skipped.
+func (d *Decoder) readTemplates(b []byte, key templateKey) error {
+	for len(b) >= 4 {
+		key.id = binary.BigEndian.Uint16(b)
+		count := int(binary.BigEndian.Uint16(b[2:]))
+		b = b[4:]
+		if count == 0 {
+			if _, ok := d.templates[key]; ok && key.version == 10 {
+				delete(d.templates, key)
+				if d.counts[key.exporter]--; d.counts[key.exporter] == 0 {
+					delete(d.counts, key.exporter)
+				}
+			}
+			continue
+		}
+		fields := make([]field, count)
+		for i := range fields {
+			if len(b) < 4 {
+				return fmt.Errorf("%w: template %d truncated", ErrMalformed, key.id)
+			}
+			f := field{id: binary.BigEndian.Uint16(b), len: binary.BigEndian.Uint16(b[2:])}
+			b = b[4:]
+			if key.version == 10 && f.id&0x8000 != 0 {
+				if len(b) < 4 {
+					return fmt.Errorf("%w: template %d truncated", ErrMalformed, key.id)
+				}
+				f.id &^= 0x8000
+				f.enterprise = true
+				b = b[4:]
+			}
+			if f.len == varLen && key.version != 10 {
+				return fmt.Errorf("%w: template %d has a variable-length field", ErrMalformed, key.id)
+			}
+			fields[i] = f
+		}
+		if _, ok := d.templates[key]; !ok {
+			if d.counts[key.exporter] >= maxTemplates {
+				continue
+			}
+			if d.templates == nil {
+				d.templates = make(map[templateKey][]field)
+				d.counts = make(map[netip.Addr]int)
+			}
+			d.counts[key.exporter]++
+		}
+		d.templates[key] = fields
+	}
+	return nil
+}
+
+// appendRecords appends the flows in a data set's records to flows. Records
+// are read until fewer bytes remain than the shortest record could take,
+// which is padding.
+func appendRecords(flows []Flow, b []byte, fields []field) ([]Flow, error) {
+	minLen := 0
+	for _, f := range fields {
+		if f.len == varLen {
+			minLen++
+		} else {
+			minLen += int(f.len)
+		}
+	}
+	if minLen == 0 {
+		return flows, nil
+	}
+	for len(b) >= minLen {
+		var f Flow
+		for _, fd := range fields {
+			n := int(fd.len)
+			if fd.len == varLen {
+				if len(b) < 1 {
+					return nil, fmt.Errorf("%w: record truncated", ErrMalformed)
+				}
+				n, b = int(b[0]), b[1:]
+				if n == 255 {
+					if len(b) < 2 {
+						return nil, fmt.Errorf("%w: record truncated", ErrMalformed)
+					}
+					n, b = int(binary.BigEndian.Uint16(b)), b[2:]
+				}
+			}
+			if len(b) < n {
+				return nil, fmt.Errorf("%w: record truncated", ErrMalformed)
+			}
+			if !fd.enterprise {
+				f.set(fd.id, b[:n])
+			}
+			b = b[n:]
+		}
+		flows = append(flows, f)
+	}
+	return flows, nil
+}
+
+// set stores the value of information element id in f, if it is one Flow
+// holds. Counters may be sent in fewer bytes than their full width.
+func (f *Flow) set(id uint16, v []byte) {
+	switch id {
+	case fieldBytes:
+		f.Bytes = uintValue(v)
+	case fieldPackets:
+		f.Packets = uintValue(v)
+	case fieldProtocol:
+		f.Protocol = uint8(uintValue(v))
+	case fieldSrcPort:
+		f.SrcPort = uint16(uintValue(v))
+	case fieldDstPort:
+		f.DstPort = uint16(uintValue(v))
+	case fieldSrcV4, fieldSrcV6:
+		if addr, ok := netip.AddrFromSlice(v); ok {
+			f.Src = addr.Unmap()
+		}
+	case fieldDstV4, fieldDstV6:
+		if addr, ok := netip.AddrFromSlice(v); ok {
+			f.Dst = addr.Unmap()
+		}
+	}
+}
+
+// uintValue decodes a big-endian unsigned integer of up to 8 bytes.
+func uintValue(v []byte) uint64 {
+	var n uint64
+	for _, c := range v {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
@@ -0,0 +1,236 @@
+package netflow
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/rmmh/eurip"
+)
+
+var exporter = netip.MustParseAddr("192.0.2.1")
+
+// hasIPv6 is whether the embedded dataset has IPv6 tables, which the
+// eurip_nov6 build tag leaves out.
+var hasIPv6 = slices.Contains(eurip.NewMatcher().Families(), eurip.IPv6)
+
+func be16(b []byte, v uint16) []byte { return binary.BigEndian.AppendUint16(b, v) }
+func be32(b []byte, v uint32) []byte { return binary.BigEndian.AppendUint32(b, v) }
+
+// set returns a v9 flowset or IPFIX set with the given ID and body.
+func set(id uint16, body []byte) []byte {
+	return append(be16(be16(nil, id), uint16(4+len(body))), body...)
+}
+
+// v9 returns a v9 packet from source ID 7 holding sets.
+func v9(sets ...[]byte) []byte {
+	b := be16(nil, 9)
+	b = be16(b, uint16(len(sets)))
+	b = append(b, make([]byte, 12)...)
+	b = be32(b, 7)
+	for _, s := range sets {
+		b = append(b, s...)
+	}
+	return b
+}
+
+// ipfix returns an IPFIX message from observation domain 7 holding sets.
+func ipfix(sets ...[]byte) []byte {
+	var body []byte
+	for _, s := range sets {
+		body = append(body, s...)
+	}
+	b := be16(nil, 10)
+	b = be16(b, uint16(16+len(body)))
+	b = append(b, make([]byte, 8)...)
+	b = be32(b, 7)
+	return append(b, body...)
+}
+
+// template returns a template record for id with the given field IDs and
+// lengths.
+func template(id uint16, fields ...uint16) []byte {
+	b := be16(be16(nil, id), uint16(len(fields)/2))
+	for _, f := range fields {
+		b = be16(b, f)
+	}
+	return b
+}
+
+func TestDecodeV5(t *testing.T) {
+	b := be16(nil, 5)
+	b = be16(b, 2)
+	b = append(b, make([]byte, 20)...)
+	for _, r := range []struct {
+		src, dst string
+		sport    uint16
+	}{{"2.0.0.1", "1.0.0.1", 443}, {"1.0.0.1", "2.0.0.1", 80}} {
+		rec := make([]byte, 48)
+		copy(rec, netip.MustParseAddr(r.src).AsSlice())
+		copy(rec[4:], netip.MustParseAddr(r.dst).AsSlice())
+		binary.BigEndian.PutUint32(rec[16:], 3)
+		binary.BigEndian.PutUint32(rec[20:], 1500)
+		binary.BigEndian.PutUint16(rec[32:], r.sport)
+		binary.BigEndian.PutUint16(rec[34:], 50000)
+		rec[38] = 6
+		b = append(b, rec...)
+	}
+	var d Decoder
+	flows, err := d.Decode(exporter, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flows) != 2 {
+		t.Fatalf("Decode = %d flows, want 2", len(flows))
+	}
+	f := flows[0]
+	if f.Src != netip.MustParseAddr("2.0.0.1") || f.SrcPort != 443 || f.DstPort != 50000 || f.Protocol != 6 ||
+		f.Packets != 3 || f.Bytes != 1500 || !f.SrcResult.EU || f.DstResult.EU {
+		t.Errorf("flow 0 = %+v", f)
+	}
+	if flows[1].SrcResult.EU || !flows[1].DstResult.EU {
+		t.Errorf("flow 1 = %+v", flows[1])
+	}
+
+	if _, err := d.Decode(exporter, b[:len(b)-1]); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Decode of a truncated v5 packet = %v, want ErrMalformed", err)
+	}
+}
+
+func TestDecodeV9(t *testing.T) {
+	tmpl := set(0, template(256, fieldSrcV6, 16, fieldDstV4, 4, fieldPackets, 4, fieldBytes, 8, fieldProtocol, 1))
+	rec := append(netip.MustParseAddr("2001:420:4000:1::").AsSlice(), netip.MustParseAddr("1.0.0.1").AsSlice()...)
+	rec = be32(rec, 2)
+	rec = binary.BigEndian.AppendUint64(rec, 1<<33)
+	rec = append(rec, 17)
+	// Two records, padded to a multiple of four bytes.
+	data := set(256, append(append(append([]byte(nil), rec...), rec...), 0, 0))
+
+	var d Decoder
+	if flows, err := d.Decode(exporter, v9(data)); err != nil || len(flows) != 0 {
+		t.Errorf("Decode before the template = %v, %v, want no flows", flows, err)
+	}
+	flows, err := d.Decode(exporter, v9(tmpl, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flows) != 2 {
+		t.Fatalf("Decode = %d flows, want 2", len(flows))
+	}
+	if f := flows[1]; f.Src != netip.MustParseAddr("2001:420:4000:1::") || f.Dst != netip.MustParseAddr("1.0.0.1") ||
+		f.Packets != 2 || f.Bytes != 1<<33 || f.Protocol != 17 || f.SrcResult.EU != hasIPv6 || f.DstResult.EU {
+		t.Errorf("flow = %+v", f)
+	}
+	if flows, _ := d.Decode(exporter, v9(data)); len(flows) != 2 {
+		t.Errorf("Decode after the template = %d flows, want 2", len(flows))
+	}
+	if flows, _ := d.Decode(netip.MustParseAddr("192.0.2.2"), v9(data)); len(flows) != 0 {
+		t.Errorf("Decode from another exporter used the template: %d flows", len(flows))
+	}
+	if flows, _ := d.Decode(exporter, ipfix(data)); len(flows) != 0 {
+		t.Errorf("Decode of IPFIX used a v9 template: %d flows", len(flows))
+	}
+	if flows, _ := d.Decode(exporter, v9(set(0, template(256)), data)); len(flows) != 2 {
+		t.Errorf("Decode after an empty v9 template = %d flows, want 2: v9 has no withdrawals", len(flows))
+	}
+}
+
+func TestDecodeTemplateLimit(t *testing.T) {
+	var tmpls []byte
+	for id := range uint16(maxTemplates + 1) {
+		tmpls = append(tmpls, template(256+id, fieldSrcV4, 4)...)
+	}
+	first, last := uint16(256), uint16(256+maxTemplates)
+	rec := netip.MustParseAddr("2.0.0.1").AsSlice()
+
+	var d Decoder
+	if _, err := d.Decode(exporter, ipfix(set(2, tmpls))); err != nil {
+		t.Fatal(err)
+	}
+	if flows, _ := d.Decode(exporter, ipfix(set(first, rec))); len(flows) != 1 {
+		t.Errorf("Decode with the first template = %d flows, want 1", len(flows))
+	}
+	if flows, _ := d.Decode(exporter, ipfix(set(last, rec))); len(flows) != 0 {
+		t.Errorf("Decode with a template past the limit = %d flows, want 0", len(flows))
+	}
+	if flows, _ := d.Decode(netip.MustParseAddr("192.0.2.2"), ipfix(set(2, template(last, fieldSrcV4, 4)), set(last, rec))); len(flows) != 1 {
+		t.Errorf("Decode from another exporter = %d flows, want 1: the limit is per exporter", len(flows))
+	}
+	// Withdrawing a template makes room for another.
+	if flows, _ := d.Decode(exporter, ipfix(set(2, append(template(first), template(last, fieldSrcV4, 4)...)), set(last, rec))); len(flows) != 1 {
+		t.Errorf("Decode after withdrawing a template = %d flows, want 1", len(flows))
+	}
+}
+
+func TestDecodeIPFIX(t *testing.T) {
+	// An enterprise field and a variable-length one, to be skipped.
+	tmpl := template(300, fieldSrcV4, 4, 0x8000|fieldDstV4, 4)
+	tmpl = be32(tmpl, 29305)
+	tmpl = append(tmpl, template(0, 82, varLen, fieldDstV4, 4, fieldBytes, 2)[4:]...)
+	binary.BigEndian.PutUint16(tmpl[2:], 5)
+	var rec []byte
+	rec = append(rec, netip.MustParseAddr("1.0.0.1").AsSlice()...)
+	rec = append(rec, 1, 1, 1, 1)
+	rec = append(rec, 3, 'e', 't', 'h')
+	rec = append(rec, netip.MustParseAddr("2.0.0.1").AsSlice()...)
+	rec = be16(rec, 1200)
+	long := append([]byte(nil), rec[:8]...)
+	long = append(long, 255, 0, 3, 'e', 't', 'h')
+	long = append(long, rec[12:]...)
+
+	var d Decoder
+	flows, err := d.Decode(exporter, ipfix(set(2, tmpl), set(300, append(rec, long...))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flows) != 2 {
+		t.Fatalf("Decode = %d flows, want 2", len(flows))
+	}
+	for _, f := range flows {
+		if f.Src != netip.MustParseAddr("1.0.0.1") || f.Dst != netip.MustParseAddr("2.0.0.1") || f.Bytes != 1200 ||
+			f.SrcResult.EU || !f.DstResult.EU {
+			t.Errorf("flow = %+v", f)
+		}
+	}
+
+	if _, err := d.Decode(exporter, ipfix(set(2, tmpl), set(300, rec[:14]))); err != nil {
+		t.Errorf("Decode with a short trailing record = %v, want it read as padding", err)
+	}
+	if _, err := d.Decode(exporter, ipfix(set(300, append(rec, rec[:16]...)))); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Decode of a truncated record = %v, want ErrMalformed", err)
+	}
+	if flows, _ := d.Decode(exporter, ipfix(set(2, template(300)), set(300, rec))); len(flows) != 0 {
+		t.Errorf("Decode after withdrawing the template = %d flows, want 0", len(flows))
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	var d Decoder
+	for name, b := range map[string][]byte{
+		"empty":           nil,
+		"version":         be16(nil, 8),
+		"v9 header":       be16(nil, 9),
+		"set length":      v9([]byte{1, 0, 0, 40}),
+		"trailing bytes":  v9([]byte{1, 0}),
+		"IPFIX length":    append(be16(be16(nil, 10), 100), make([]byte, 14)...),
+		"template fields": v9(set(0, []byte{1, 0, 0, 2, 0, fieldSrcV4, 0, 4, 0, fieldSrcPort})),
+	} {
+		if _, err := d.Decode(exporter, b); !errors.Is(err, ErrMalformed) {
+			t.Errorf("Decode(%s) = %v, want ErrMalformed", name, err)
+		}
+	}
+}
+
+// FuzzDecode checks that no packet panics the decoder, including ones
+// referring to templates earlier packets defined.
+func FuzzDecode(f *testing.F) {
+	f.Add(v9(set(0, template(256, fieldSrcV4, 4, fieldBytes, 2)), set(256, []byte{2, 0, 0, 1, 0, 9})))
+	f.Add(ipfix(set(2, template(256, 82, varLen, fieldDstV6, 16)), set(256, []byte{255, 0, 1, 'x'})))
+	f.Add(be16(be16(make([]byte, 0, 72), 5), 1))
+	var d Decoder
+	f.Fuzz(func(t *testing.T, b []byte) {
+		d.Decode(exporter, b)
+	})
+}
@@ -0,0 +1,82 @@
+package eurip
+
+import (
+	"iter"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+)
+
+// SetOverride makes lookups of addresses in p return r, whatever the
+// dataset or UnclassifiedPolicy says: for correcting known misgeolocations,
+// or for pinning test networks. The longest override holding an address
+// wins. Lookups running meanwhile see the overrides before or after the
+// change, never a mix.
+//
+// Each change copies the override table, so for loading many at once build
+// a PrefixMap and use SetOverrides.
+func (m *Matcher) SetOverride(p netip.Prefix, r Result) {
+	m.updateOverrides(func(o *PrefixMap[Result]) { o.Set(p, r) })
+}
+
+// DeleteOverride removes the override for exactly p, reporting whether
+// there was one.
+func (m *Matcher) DeleteOverride(p netip.Prefix) bool {
+	var deleted bool
+	m.updateOverrides(func(o *PrefixMap[Result]) { deleted = o.Delete(p) })
+	return deleted
+}
+
+// SetOverrides replaces all of m's overrides with a copy of o, which may be
+// nil to remove them.
+func (m *Matcher) SetOverrides(o *PrefixMap[Result]) {
+	m.overridesMu.Lock()
+	defer m.overridesMu.Unlock()
+	if o == nil || o.Len() == 0 {
+		m.overrides.Store(nil)
+		return
+	}
+	m.overrides.Store(o.Clone())
+}
+
+// Overrides yields m's overrides, as of the call, in address order.
+func (m *Matcher) Overrides() iter.Seq2[netip.Prefix, Result] {
+	o := m.overrides.Load()
+	if o == nil {
+		o = &PrefixMap[Result]{}
+	}
+	return o.All()
+}
+
+// updateOverrides applies fn to a copy of the overrides and swaps it in.
+func (m *Matcher) updateOverrides(fn func(*PrefixMap[Result])) {
+	updatePrefixMap(&m.overridesMu, &m.overrides, fn)
+}
+
+// updatePrefixMap applies fn to a copy of the PrefixMap in p, or an empty
+// one, and swaps it in, storing nil if it is left empty. Writers are
+// serialized by mu, so none of their changes are lost; readers never wait,
+// and never see a map being changed.
+func updatePrefixMap[V any](mu *sync.Mutex, p *atomic.Pointer[PrefixMap[V]], fn func(*PrefixMap[V])) {
+	mu.Lock()
+	defer mu.Unlock()
+	pm := &PrefixMap[V]{}
+	if old := p.Load(); old != nil {
+		pm = old.Clone()
+	}
+	fn(pm)
+	if pm.Len() == 0 {
+		pm = nil
+	}
+	p.Store(pm)
+}
+
+// override returns the override for addr, which must be unmapped, and its
+// prefix.
+func (m *Matcher) override(addr netip.Addr) (netip.Prefix, Result, bool) {
+	o := m.overrides.Load()
+	if o == nil {
+		return netip.Prefix{}, Result{}, false
+	}
+	return o.Lookup(addr)
+}
@@ -0,0 +1,146 @@
+package eurip
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestOverrides(t *testing.T) {
+	m := NewMatcher(WithUnclassifiedPolicy(UnclassifiedError))
+	eu, other, private := netip.MustParseAddr("2.0.0.1"), netip.MustParseAddr("1.0.0.1"), netip.MustParseAddr("10.1.2.3")
+
+	m.SetOverride(netip.MustParsePrefix("2.0.0.0/24"), Result{})
+	m.SetOverride(netip.MustParsePrefix("1.0.0.0/8"), Result{EU: true})
+	m.SetOverride(netip.MustParsePrefix("1.0.0.0/24"), Result{EU: true, Uncertain: true})
+	m.SetOverride(netip.MustParsePrefix("10.0.0.0/8"), Result{EU: true})
+	for _, tc := range []struct {
+		addr netip.Addr
+		want Result
+	}{
+		{eu, Result{}},
+		{netip.AddrFrom16(eu.As16()), Result{}},
+		{other, Result{EU: true, Uncertain: true}},
+		{netip.MustParseAddr("1.1.0.1"), Result{EU: true}},
+		{private, Result{EU: true}},
+		{netip.MustParseAddr("2.0.1.1"), Result{EU: true}},
+	} {
+		if got, err := m.Check(tc.addr); got != tc.want || err != nil {
+			t.Errorf("Check(%s) = %+v, %v, want %+v", tc.addr, got, err, tc.want)
+		}
+		if got := m.IsFromEU(net.IP(tc.addr.AsSlice())); got != tc.want.EU {
+			t.Errorf("IsFromEU(%s) = %v, want %v", tc.addr, got, tc.want.EU)
+		}
+	}
+	if e := m.Explain(other); e.Source != SourceOverride || e.Prefix != netip.MustParsePrefix("1.0.0.0/24") || !e.EU {
+		t.Errorf("Explain(%s) = %+v, want the /24 override", other, e)
+	}
+
+	var n int
+	for p, r := range m.Overrides() {
+		if n == 0 && (p != netip.MustParsePrefix("1.0.0.0/8") || !r.EU) {
+			t.Errorf("first override = %s, %+v", p, r)
+		}
+		n++
+	}
+	if n != 4 {
+		t.Errorf("Overrides yielded %d, want 4", n)
+	}
+
+	if !m.DeleteOverride(netip.MustParsePrefix("10.0.0.0/8")) || m.DeleteOverride(netip.MustParsePrefix("10.0.0.0/8")) {
+		t.Error("DeleteOverride(10.0.0.0/8) should succeed once")
+	}
+	if _, err := m.Check(private); err == nil {
+		t.Errorf("Check(%s) after DeleteOverride succeeded; want the unclassified error", private)
+	}
+
+	var bulk PrefixMap[Result]
+	bulk.Set(netip.MustParsePrefix("44.0.0.0/8"), Result{EU: true})
+	m.SetOverrides(&bulk)
+	bulk.Set(netip.MustParsePrefix("45.0.0.0/8"), Result{EU: true})
+	if !m.Lookup(netip.MustParseAddr("44.0.0.1")).EU || m.Lookup(netip.MustParseAddr("45.0.0.1")).EU || !m.Lookup(eu).EU {
+		t.Error("SetOverrides didn't replace the overrides with a copy")
+	}
+	m.SetOverrides(nil)
+	if m.Lookup(netip.MustParseAddr("44.0.0.1")).EU {
+		t.Error("SetOverrides(nil) kept overrides")
+	}
+}
+
+func TestOverridesApplyToExports(t *testing.T) {
+	withTables(t, map[*[]uint16][]uint16{&v4Data: buildTable("44.0.0.0/16")})
+	m := NewMatcher()
+	m.SetOverride(netip.MustParsePrefix("44.0.128.0/17"), Result{})
+	m.SetOverride(netip.MustParsePrefix("44.0.200.0/24"), Result{EU: true})
+	m.SetOverride(netip.MustParsePrefix("45.0.0.0/24"), Result{EU: true})
+	m.SetOverride(netip.MustParsePrefix("255.255.255.255/32"), Result{EU: true})
+
+	v4 := netip.MustParsePrefix("0.0.0.0/0")
+	want := []netip.Prefix{
+		netip.MustParsePrefix("44.0.0.0/17"),
+		netip.MustParsePrefix("44.0.200.0/24"),
+		netip.MustParsePrefix("45.0.0.0/24"),
+		netip.MustParsePrefix("255.255.255.255/32"),
+	}
+	if got := m.AppendEUPrefixes(nil, v4); !slices.Equal(got, want) {
+		t.Errorf("AppendEUPrefixes(%s) = %v, want %v", v4, got, want)
+	}
+	if got := m.AppendEUPrefixes(nil, netip.MustParsePrefix("44.0.192.0/18")); !slices.Equal(got, want[1:2]) {
+		t.Errorf("AppendEUPrefixes(44.0.192.0/18) = %v, want %v", got, want[1:2])
+	}
+	all := m.AppendEUPrefixes(nil, netip.Prefix{})
+	if !slices.Equal(all[:len(want)], want) {
+		t.Errorf("AppendEUPrefixes() starts %v, want %v", all[:min(len(all), len(want))], want)
+	}
+
+	ranges := m.AppendRanges(nil)
+	for i, r := range ranges[1:] {
+		if r.Start != ranges[i].End.Next() && r.Start != netip.IPv6Unspecified() {
+			t.Fatalf("AppendRanges leaves a gap between %+v and %+v", ranges[i], r)
+		}
+	}
+	var buf bytes.Buffer
+	if err := m.WriteMMDB(&buf); err != nil {
+		t.Fatal(err)
+	}
+	db := newMMDBReader(t, buf.Bytes())
+	for ip, eu := range map[string]bool{
+		"44.0.0.1":        true,
+		"44.0.129.1":      false,
+		"44.0.200.1":      true,
+		"45.0.0.1":        true,
+		"45.0.1.1":        false,
+		"255.255.255.255": true,
+	} {
+		addr := netip.MustParseAddr(ip)
+		if got := db.lookup(addr) != nil; got != eu {
+			t.Errorf("WriteMMDB has a record for %s: %v, want %v", ip, got, eu)
+		}
+		i, _ := slices.BinarySearchFunc(ranges, addr, func(r Range, a netip.Addr) int { return r.End.Compare(a) })
+		if i == len(ranges) || !ranges[i].Contains(addr) || ranges[i].EU != eu {
+			t.Errorf("AppendRanges has no range with EU %v holding %s", eu, ip)
+		}
+		if m.Lookup(addr).EU != eu {
+			t.Errorf("Lookup(%s).EU = %v, want %v", ip, !eu, eu)
+		}
+	}
+	if p := m.MatchedPrefix(netip.MustParseAddr("44.0.129.1")); p != netip.MustParsePrefix("44.0.128.0/17") {
+		t.Errorf("MatchedPrefix(44.0.129.1) = %s, want the override 44.0.128.0/17", p)
+	}
+
+	src := rand.NewPCG(1, 2)
+	for range 200 {
+		if addr := m.RandomEUAddr(src); !m.Lookup(addr).EU {
+			t.Fatalf("RandomEUAddr() = %s, which the overrides make non-EU", addr)
+		}
+	}
+	m.SetOverride(netip.MustParsePrefix("44.0.0.0/16"), Result{})
+	for range 200 {
+		if addr := m.RandomEUAddr(src); !m.Lookup(addr).EU {
+			t.Fatalf("RandomEUAddr() after another override = %s, which is non-EU", addr)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package eurip
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestUnclassifiedPolicy(t *testing.T) {
+	withTables(t, map[*[]uint16][]uint16{&v4Data: buildTable("2.0.0.0/12")})
+	for _, tc := range []struct {
+		policy  UnclassifiedPolicy
+		eu      bool
+		err     error
+		special error
+	}{
+		{UnclassifiedNotEU, false, nil, nil},
+		{UnclassifiedEU, true, nil, nil},
+		{UnclassifiedError, false, ErrInvalidAddr, ErrUnclassified},
+	} {
+		m := NewMatcher(WithUnclassifiedPolicy(tc.policy))
+		if got := m.IsFromEU(nil); got != tc.eu {
+			t.Errorf("policy %d: IsFromEU(nil) = %v, want %v", tc.policy, got, tc.eu)
+		}
+		if got := m.IsFromEU(net.ParseIP("192.168.1.1")); got != tc.eu {
+			t.Errorf("policy %d: IsFromEU(192.168.1.1) = %v, want %v", tc.policy, got, tc.eu)
+		}
+		if r, err := m.Check(netip.Addr{}); r.EU != tc.eu || !errors.Is(err, tc.err) || (err == nil) != (tc.err == nil) {
+			t.Errorf("policy %d: Check(invalid) = %+v, %v, want EU %v, %v", tc.policy, r, err, tc.eu, tc.err)
+		}
+		for _, ip := range []string{"10.1.2.3", "::1", "fe80::1", "::ffff:127.0.0.1"} {
+			r, err := m.Check(netip.MustParseAddr(ip))
+			if r.EU != tc.eu || r.Uncertain != tc.eu || !errors.Is(err, tc.special) || (err == nil) != (tc.special == nil) {
+				t.Errorf("policy %d: Check(%s) = %+v, %v, want EU %v, %v", tc.policy, ip, r, err, tc.eu, tc.special)
+			}
+			if got := m.Lookup(netip.MustParseAddr(ip)); got.EU != tc.eu {
+				t.Errorf("policy %d: Lookup(%s) = %+v, want EU %v", tc.policy, ip, got, tc.eu)
+			}
+		}
+		// Public addresses are unaffected.
+		for ip, want := range map[string]bool{"2.0.0.1": true, "1.0.0.1": false} {
+			if r, err := m.Check(netip.MustParseAddr(ip)); r.EU != want || err != nil {
+				t.Errorf("policy %d: Check(%s) = %+v, %v, want EU %v", tc.policy, ip, r, err, want)
+			}
+		}
+	}
+}
+
+func TestStrict(t *testing.T) {
+	withTables(t, map[*[]uint16][]uint16{
+		&v4Data:      buildTable("44.0.0.0/26"),
+		&v6Data:      buildTable("2620:db8::/32"),
+		&knownV4Data: buildTable("44.0.0.0/25"),
+		&knownV6Data: buildTable("2620:db8::/31"),
+	})
+	for _, tc := range []struct {
+		ip      string
+		eu      bool
+		unknown bool
+	}{
+		{"44.0.0.1", true, false},
+		{"44.0.0.65", false, false},
+		{"44.0.0.129", false, true},
+		{"::ffff:44.0.0.129", false, true},
+		{"2620:db8::1", true, false},
+		{"2620:db9::1", false, false},
+		{"2620:dba::1", false, true},
+		{"10.0.0.1", false, false}, // special-purpose: up to the policy
+	} {
+		if skipV6(tc.ip) {
+			continue
+		}
+		addr := netip.MustParseAddr(tc.ip)
+		r, err := NewMatcher(WithStrict(true)).Check(addr)
+		if r.EU != tc.eu || r.Unknown != tc.unknown || errors.Is(err, ErrUnknown) != tc.unknown || (err == nil) == tc.unknown {
+			t.Errorf("strict Check(%s) = %+v, %v, want EU %v, unknown %v", tc.ip, r, err, tc.eu, tc.unknown)
+		}
+		if r, err := NewMatcher().Check(addr); r.EU != tc.eu || r.Unknown || err != nil {
+			t.Errorf("Check(%s) = %+v, %v, want EU %v", tc.ip, r, err, tc.eu)
+		}
+	}
+	if _, err := NewMatcher(WithStrict(true), WithUnclassifiedPolicy(UnclassifiedError)).Check(netip.MustParseAddr("10.0.0.1")); !errors.Is(err, ErrUnclassified) {
+		t.Errorf("strict Check(10.0.0.1) with UnclassifiedError = %v, want ErrUnclassified", err)
+	}
+}
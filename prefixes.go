@@ -0,0 +1,136 @@
+package eurip
+
+import (
+	"math/bits"
+	"net/netip"
+)
+
+// AppendEUPrefixes appends the EU prefixes overlapping within to dst, in
+// address order, and returns the extended slice. Prefixes are clipped to
+// within, so a within that lies entirely inside an EU prefix yields within
+// itself. The zero Prefix selects everything: IPv4 first, then IPv6.
+//
+// Nothing is allocated beyond growing dst, so a reused buffer makes repeated
+// calls allocation-free.
+func AppendEUPrefixes(dst []netip.Prefix, within netip.Prefix) []netip.Prefix {
+	return defaultMatcher.AppendEUPrefixes(dst, within)
+}
+
+// prefixWalker enumerates the set ranges of a bitset DAG as CIDR prefixes.
+type prefixWalker struct {
+	nibblePath
+	dst    []netip.Prefix
+	data   []uint16
+	within netip.Prefix
+}
+
+func appendPrefixes(dst []netip.Prefix, data []uint16, within netip.Prefix) []netip.Prefix {
+	w := prefixWalker{nibblePath: nibblePath{is4: within.Addr().Is4()}, dst: dst, data: data, within: within}
+	w.node(0, 0)
+	return w.dst
+}
+
+// node visits the node at index p, which sits depth nibbles into the address.
+func (w *prefixWalker) node(p, depth int) {
+	hasChild, setChild := w.data[p], w.data[p+1]
+	child := p + 2
+	for n := 0; n < 16; {
+		if hasChild&(1<<n) != 0 {
+			w.setNibble(depth, n)
+			if w.within.Overlaps(w.prefix(4 * (depth + 1))) {
+				w.node(int(w.data[child]), depth+1)
+			}
+			w.setNibble(depth, 0)
+			child++
+			n++
+			continue
+		}
+		if setChild&(1<<n) == 0 {
+			n++
+			continue
+		}
+		// Emit the largest aligned block of set children starting at n.
+		k := alignedBlock(n, func(mask uint16) bool { return setChild&mask == mask })
+		w.setNibble(depth, n)
+		if p, ok := clip(w.prefix(4*depth+4-k), w.within); ok {
+			w.dst = append(w.dst, p)
+		}
+		w.setNibble(depth, 0)
+		n += 1 << k
+	}
+}
+
+// alignedBlock returns the log2 size of the largest aligned block of children
+// starting at n whose mask satisfies full.
+func alignedBlock(n int, full func(mask uint16) bool) int {
+	k := 4
+	for ; k > 0; k-- {
+		size := 1 << k
+		if n%size == 0 && full(uint16((1<<size)-1)<<n) {
+			break
+		}
+	}
+	return k
+}
+
+// nibblePath tracks the address a DAG walk has reached.
+type nibblePath struct {
+	addr [16]byte
+	is4  bool
+}
+
+func (w *nibblePath) setNibble(depth, n int) {
+	b := &w.addr[depth/2]
+	if depth%2 == 0 {
+		*b = *b&0x0f | byte(n)<<4
+	} else {
+		*b = *b&0xf0 | byte(n)
+	}
+}
+
+func (w *nibblePath) prefix(bits int) netip.Prefix {
+	if w.is4 {
+		return netip.PrefixFrom(netip.AddrFrom4([4]byte(w.addr[:4])), bits)
+	}
+	return netip.PrefixFrom(netip.AddrFrom16(w.addr), bits)
+}
+
+// clip returns the intersection of p and within, if they overlap.
+func clip(p, within netip.Prefix) (netip.Prefix, bool) {
+	if !within.Overlaps(p) {
+		return netip.Prefix{}, false
+	}
+	if p.Bits() < within.Bits() {
+		return within, true
+	}
+	return p, true
+}
+
+// leaf returns the block of data's leaves holding addr, which must be
+// unmapped, and whether it is set. The block is the largest aligned run of
+// like children, so set blocks are the prefixes AppendEUPrefixes reports.
+func leaf(addr netip.Addr, data []uint16) (netip.Prefix, bool) {
+	a := addr.AsSlice()
+	p := 0
+	for depth := 0; depth < len(a)*2; depth++ {
+		n := int(a[depth/2]>>4) & 0xf
+		if depth%2 == 1 {
+			n = int(a[depth/2]) & 0xf
+		}
+		hasChild, setChild := data[p], data[p+1]
+		if hasChild&(1<<n) != 0 {
+			p = int(data[p+2+bits.OnesCount16(hasChild&(1<<n-1))])
+			continue
+		}
+		set := setChild&(1<<n) != 0
+		k := 4
+		for ; k > 0; k-- {
+			mask := uint16((1<<(1<<k))-1) << (n &^ (1<<k - 1))
+			if hasChild&mask == 0 && (set && setChild&mask == mask || !set && setChild&mask == 0) {
+				break
+			}
+		}
+		return netip.PrefixFrom(addr, 4*depth+4-k).Masked(), set
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), false
+}
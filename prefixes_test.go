@@ -0,0 +1,74 @@
+package eurip
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestAppendEUPrefixesMatchesLookup(t *testing.T) {
+	prefixes := AppendEUPrefixes(nil, netip.Prefix{})
+	if len(prefixes) == 0 {
+		t.Fatal("no EU prefixes")
+	}
+	for i, p := range prefixes {
+		if i > 0 && !prefixes[i-1].Addr().Less(p.Addr()) {
+			t.Fatalf("prefixes out of order: %s before %s", prefixes[i-1], p)
+		}
+		first := p.Addr()
+		if !IsFromEU(net.IP(first.AsSlice())) {
+			t.Errorf("IsFromEU(%s) = false, first address of %s", first, p)
+		}
+		if prev := first.Prev(); prev.IsValid() && prev.BitLen() == first.BitLen() {
+			if i > 0 && prefixes[i-1].Contains(prev) {
+				continue
+			}
+			if IsFromEU(net.IP(prev.AsSlice())) {
+				t.Errorf("IsFromEU(%s) = true, just before %s", prev, p)
+			}
+		}
+	}
+}
+
+func TestAppendEUPrefixesWithin(t *testing.T) {
+	for _, tc := range []struct {
+		within string
+		want   []string
+	}{
+		{"2.0.0.0/12", []string{"2.0.0.0/12"}},
+		{"2.1.2.0/24", []string{"2.1.2.0/24"}},
+		{"2.16.0.0/18", []string{"2.16.6.0/23"}},
+		{"1.0.0.0/24", nil},
+		{"::ffff:2.1.0.0/112", []string{"2.1.0.0/16"}},
+		{"2001:420:4000::/40", []string{"2001:420:4000::/40"}},
+	} {
+		if skipV6(tc.within) {
+			continue
+		}
+		var got []string
+		for _, p := range AppendEUPrefixes(nil, netip.MustParsePrefix(tc.within)) {
+			got = append(got, p.String())
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("AppendEUPrefixes(%s) = %v, want %v", tc.within, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("AppendEUPrefixes(%s) = %v, want %v", tc.within, got, tc.want)
+				break
+			}
+		}
+	}
+}
+
+func TestAppendEUPrefixesReusesBuffer(t *testing.T) {
+	within := netip.MustParsePrefix("2.0.0.0/8")
+	buf := AppendEUPrefixes(nil, within)
+	allocs := testing.AllocsPerRun(10, func() {
+		buf = AppendEUPrefixes(buf[:0], within)
+	})
+	if allocs != 0 {
+		t.Errorf("AppendEUPrefixes allocated %v times with a reused buffer", allocs)
+	}
+}
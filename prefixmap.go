@@ -0,0 +1,488 @@
+package eurip
+
+import (
+	"iter"
+	"net/netip"
+	"slices"
+	"sync"
+)
+
+// A PrefixMap maps prefixes to values of type V, and finds the longest
+// prefix holding an address. IPv4 prefixes and IPv4-mapped IPv6 prefixes of
+// at least /96 are the same keys, and IPv4-mapped addresses are looked up as
+// IPv4. The zero value is an empty map.
+//
+// A PrefixMap is safe for concurrent reads, but writes need exclusive
+// access. Clone it to update a copy while readers use the original.
+//
+// Matchers look addresses up in a PrefixMap too, holding the prefixes of
+// the EU tables they count, which is read in place from the dataset's
+// bitset DAGs rather than built into a trie. The first write to such a map,
+// or a clone of it, copies the prefixes into a trie.
+type PrefixMap[V any] struct {
+	v4, v6 *prefixNode[V]
+	n      int
+	// dags, if not nil, holds the map's prefixes instead of the trie. It
+	// is never changed, so clones share it.
+	dags *dagLayers[V]
+}
+
+// dagLayers maps the prefixes of bitset DAGs, as AppendEUPrefixes reports
+// them, to a value per DAG. Like a trie, it finds the longest prefix
+// holding an address in any layer; a prefix in several layers has the
+// value of the first.
+type dagLayers[V any] struct {
+	layers []dagLayer[V]
+	// lenOnce guards n, which is only counted if Len is called.
+	lenOnce sync.Once
+	n       int
+}
+
+// A dagLayer is a pair of bitset DAGs, one per address family, whose
+// prefixes map to value.
+type dagLayer[V any] struct {
+	v4, v6 []uint16
+	value  V
+}
+
+// data returns l's DAG for addr's family, which must be unmapped, or nil if
+// it has none.
+func (l *dagLayer[V]) data(addr netip.Addr) []uint16 {
+	data := l.v6
+	if addr.Is4() {
+		data = l.v4
+	}
+	if len(data) < 2 {
+		return nil
+	}
+	return data
+}
+
+// dagPrefixMap returns a PrefixMap of the prefixes of layers, read in place.
+// The DAGs must be valid, as Validate checks.
+func dagPrefixMap[V any](layers ...dagLayer[V]) *PrefixMap[V] {
+	return &PrefixMap[V]{dags: &dagLayers[V]{layers: layers}}
+}
+
+// thaw copies the prefixes of pm's DAG layers, if it has any, into its trie,
+// for writing.
+func (pm *PrefixMap[V]) thaw() {
+	if pm.dags == nil {
+		return
+	}
+	all := (&PrefixMap[V]{dags: pm.dags}).All()
+	pm.dags = nil
+	for p, v := range all {
+		pm.Set(p, v)
+	}
+}
+
+// prefixNode is a node of a binary trie: its children extend its prefix
+// with a 0 or 1 bit.
+type prefixNode[V any] struct {
+	child [2]*prefixNode[V]
+	value V
+	set   bool
+}
+
+// normalize returns p masked, with IPv4-mapped prefixes unmapped.
+func normalize(p netip.Prefix) netip.Prefix {
+	p = p.Masked()
+	if p.Addr().Is4In6() && p.Bits() >= 96 {
+		p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+	}
+	return p
+}
+
+func (pm *PrefixMap[V]) root(addr netip.Addr, create bool) **prefixNode[V] {
+	r := &pm.v6
+	if addr.Is4() {
+		r = &pm.v4
+	}
+	if *r == nil && create {
+		*r = new(prefixNode[V])
+	}
+	return r
+}
+
+// bit returns bit i of a, counting from the most significant.
+func bit(a []byte, i int) int {
+	return int(a[i/8]>>(7-i%8)) & 1
+}
+
+// Set maps p to v, replacing any value p had. Invalid prefixes are ignored.
+func (pm *PrefixMap[V]) Set(p netip.Prefix, v V) {
+	if !p.IsValid() {
+		return
+	}
+	pm.thaw()
+	p = normalize(p)
+	a := p.Addr().AsSlice()
+	n := *pm.root(p.Addr(), true)
+	for i := range p.Bits() {
+		b := bit(a, i)
+		if n.child[b] == nil {
+			n.child[b] = new(prefixNode[V])
+		}
+		n = n.child[b]
+	}
+	if !n.set {
+		pm.n++
+	}
+	n.value, n.set = v, true
+}
+
+// node returns the node for p, or nil.
+func (pm *PrefixMap[V]) node(p netip.Prefix) *prefixNode[V] {
+	if !p.IsValid() {
+		return nil
+	}
+	p = normalize(p)
+	a := p.Addr().AsSlice()
+	n := *pm.root(p.Addr(), false)
+	for i := 0; n != nil && i < p.Bits(); i++ {
+		n = n.child[bit(a, i)]
+	}
+	return n
+}
+
+// Get returns the value mapped to exactly p.
+func (pm *PrefixMap[V]) Get(p netip.Prefix) (V, bool) {
+	var zero V
+	if pm.dags != nil {
+		if !p.IsValid() {
+			return zero, false
+		}
+		p = normalize(p)
+		for i := range pm.dags.layers {
+			l := &pm.dags.layers[i]
+			if data := l.data(p.Addr()); data != nil {
+				if q, set := leaf(p.Addr(), data); set && q == p {
+					return l.value, true
+				}
+			}
+		}
+		return zero, false
+	}
+	if n := pm.node(p); n != nil && n.set {
+		return n.value, true
+	}
+	return zero, false
+}
+
+// Delete removes p from the map, reporting whether it was there. Nodes left
+// without values or children are pruned.
+func (pm *PrefixMap[V]) Delete(p netip.Prefix) bool {
+	if !p.IsValid() {
+		return false
+	}
+	pm.thaw()
+	p = normalize(p)
+	a := p.Addr().AsSlice()
+	r := pm.root(p.Addr(), false)
+	path := []**prefixNode[V]{r}
+	for i := 0; *r != nil && i < p.Bits(); i++ {
+		r = &(*r).child[bit(a, i)]
+		path = append(path, r)
+	}
+	if *r == nil || !(*r).set {
+		return false
+	}
+	var zero V
+	(*r).value, (*r).set = zero, false
+	pm.n--
+	for i := len(path) - 1; i >= 0; i-- {
+		n := *path[i]
+		if n.set || n.child[0] != nil || n.child[1] != nil {
+			break
+		}
+		*path[i] = nil
+	}
+	return true
+}
+
+// Lookup returns the longest prefix in the map holding addr, and its value.
+func (pm *PrefixMap[V]) Lookup(addr netip.Addr) (netip.Prefix, V, bool) {
+	addr = addr.Unmap()
+	var value V
+	if !addr.IsValid() {
+		return netip.Prefix{}, value, false
+	}
+	if pm.dags != nil {
+		p, value, _, ok := pm.dags.lookup(addr)
+		return p, value, ok
+	}
+	a := addr.AsSlice()
+	bits := -1
+	n := *pm.root(addr, false)
+	for i := 0; n != nil; i++ {
+		if n.set {
+			bits, value = i, n.value
+		}
+		if i == addr.BitLen() {
+			break
+		}
+		n = n.child[bit(a, i)]
+	}
+	if bits < 0 {
+		return netip.Prefix{}, value, false
+	}
+	p, _ := addr.Prefix(bits)
+	return p, value, true
+}
+
+// LookupAll yields every prefix in the map holding addr, and its value,
+// shortest first.
+func (pm *PrefixMap[V]) LookupAll(addr netip.Addr) iter.Seq2[netip.Prefix, V] {
+	return func(yield func(netip.Prefix, V) bool) {
+		addr := addr.Unmap()
+		if !addr.IsValid() {
+			return
+		}
+		if pm.dags != nil {
+			pm.dags.lookupAll(addr, yield)
+			return
+		}
+		a := addr.AsSlice()
+		n := *pm.root(addr, false)
+		for i := 0; n != nil; i++ {
+			if n.set {
+				p, _ := addr.Prefix(i)
+				if !yield(p, n.value) {
+					return
+				}
+			}
+			if i == addr.BitLen() {
+				break
+			}
+			n = n.child[bit(a, i)]
+		}
+	}
+}
+
+// Len returns the number of prefixes in the map.
+func (pm *PrefixMap[V]) Len() int {
+	if d := pm.dags; d != nil {
+		d.lenOnce.Do(func() {
+			for range pm.All() {
+				d.n++
+			}
+		})
+		return d.n
+	}
+	return pm.n
+}
+
+// All yields every prefix in the map and its value, IPv4 first and then
+// IPv6, in address order with shorter prefixes before those they hold.
+func (pm *PrefixMap[V]) All() iter.Seq2[netip.Prefix, V] {
+	return func(yield func(netip.Prefix, V) bool) {
+		if pm.dags != nil {
+			_ = pm.dags.all(netip.PrefixFrom(netip.IPv4Unspecified(), 0), yield) &&
+				pm.dags.all(netip.PrefixFrom(netip.IPv6Unspecified(), 0), yield)
+			return
+		}
+		var a [16]byte
+		_ = pm.v4.walk(a[:4], 0, yield) && pm.v6.walk(a[:], 0, yield)
+	}
+}
+
+func (n *prefixNode[V]) walk(a []byte, depth int, yield func(netip.Prefix, V) bool) bool {
+	if n == nil {
+		return true
+	}
+	if n.set {
+		addr, _ := netip.AddrFromSlice(a)
+		if !yield(netip.PrefixFrom(addr, depth), n.value) {
+			return false
+		}
+	}
+	for b, c := range n.child {
+		if c == nil {
+			continue
+		}
+		if b == 1 {
+			a[depth/8] |= 0x80 >> (depth % 8)
+		}
+		if !c.walk(a, depth+1, yield) {
+			return false
+		}
+		a[depth/8] &^= 0x80 >> (depth % 8)
+	}
+	return true
+}
+
+// Clone returns a copy of pm that can be changed independently. Values are
+// copied, not deep-copied.
+func (pm *PrefixMap[V]) Clone() *PrefixMap[V] {
+	return &PrefixMap[V]{v4: pm.v4.clone(), v6: pm.v6.clone(), n: pm.n, dags: pm.dags}
+}
+
+func (n *prefixNode[V]) clone() *prefixNode[V] {
+	if n == nil {
+		return nil
+	}
+	c := *n
+	c.child = [2]*prefixNode[V]{n.child[0].clone(), n.child[1].clone()}
+	return &c
+}
+
+// lookup returns the longest prefix of any layer holding addr, which must be
+// valid and unmapped, and its value, or else the largest block of leaves
+// around addr that no layer holds any of.
+func (d *dagLayers[V]) lookup(addr netip.Addr) (p netip.Prefix, value V, block netip.Prefix, ok bool) {
+	for i := range d.layers {
+		l := &d.layers[i]
+		data := l.data(addr)
+		if data == nil {
+			continue
+		}
+		q, set := leaf(addr, data)
+		switch {
+		case set && (!ok || q.Bits() > p.Bits()):
+			p, value, ok = q, l.value, true
+		case !set && (!block.IsValid() || q.Bits() > block.Bits()):
+			block = q
+		}
+	}
+	if !block.IsValid() {
+		block = netip.PrefixFrom(addr, 0).Masked()
+	}
+	return p, value, block, ok
+}
+
+// lookupAll yields every prefix of any layer holding addr, which must be
+// valid and unmapped, and its value, shortest first.
+func (d *dagLayers[V]) lookupAll(addr netip.Addr, yield func(netip.Prefix, V) bool) {
+	type match struct {
+		p     netip.Prefix
+		value V
+	}
+	var matches []match
+	for i := range d.layers {
+		l := &d.layers[i]
+		if data := l.data(addr); data != nil {
+			if p, set := leaf(addr, data); set {
+				matches = append(matches, match{p, l.value})
+			}
+		}
+	}
+	// Stable, so that a prefix in several layers yields the first's value.
+	slices.SortStableFunc(matches, func(a, b match) int { return a.p.Bits() - b.p.Bits() })
+	for i, m := range matches {
+		if i > 0 && m.p == matches[i-1].p {
+			continue
+		}
+		if !yield(m.p, m.value) {
+			return
+		}
+	}
+}
+
+// all yields the prefixes of every layer within family, the whole IPv4 or
+// IPv6 space, in the order All does, and reports whether yield asked for
+// more.
+func (d *dagLayers[V]) all(family netip.Prefix, yield func(netip.Prefix, V) bool) bool {
+	type entry struct {
+		p     netip.Prefix
+		layer int
+	}
+	var entries []entry
+	var prefixes []netip.Prefix
+	for i := range d.layers {
+		data := d.layers[i].data(family.Addr())
+		if data == nil {
+			continue
+		}
+		prefixes = appendPrefixes(prefixes[:0], data, family)
+		for _, p := range prefixes {
+			entries = append(entries, entry{p, i})
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b entry) int { return comparePrefixes(a.p, b.p) })
+	for i, e := range entries {
+		if i > 0 && e.p == entries[i-1].p {
+			continue
+		}
+		if !yield(e.p, d.layers[e.layer].value) {
+			return false
+		}
+	}
+	return true
+}
+
+// contains reports whether any prefix in pm holds addr, which must be
+// unmapped. For DAG layers it stops at the first that does.
+func (pm *PrefixMap[V]) contains(addr netip.Addr) bool {
+	if pm.dags == nil {
+		_, _, ok := pm.Lookup(addr)
+		return ok
+	}
+	for i := range pm.dags.layers {
+		l := &pm.dags.layers[i]
+		if addr.Is4() {
+			a := addr.As4()
+			if walk(a[:], l.v4) {
+				return true
+			}
+		} else if addr.Is6() {
+			a := addr.As16()
+			if walk(a[:], l.v6) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// block returns the longest prefix in pm holding addr, which must be valid
+// and unmapped, and its value, or else the largest prefix around addr
+// holding no prefix in pm, with ok false.
+func (pm *PrefixMap[V]) block(addr netip.Addr) (p netip.Prefix, value V, ok bool) {
+	if pm.dags != nil {
+		p, value, block, ok := pm.dags.lookup(addr)
+		if !ok {
+			p = block
+		}
+		return p, value, ok
+	}
+	if p, value, ok := pm.Lookup(addr); ok {
+		return p, value, true
+	}
+	// Tries are pruned, so the space below the first missing child on
+	// addr's path holds nothing.
+	a := addr.AsSlice()
+	bits := 0
+	for n := *pm.root(addr, false); n != nil && bits < addr.BitLen(); bits++ {
+		n = n.child[bit(a, bits)]
+	}
+	b, _ := addr.Prefix(bits)
+	return b, value, false
+}
+
+// appendCovered appends the fewest prefixes covering the space that the
+// prefixes in pm cover within within, which must be masked and unmapped, to
+// dst, in address order. A map of a single DAG layer is walked without
+// allocating.
+func (pm *PrefixMap[V]) appendCovered(dst []netip.Prefix, within netip.Prefix) []netip.Prefix {
+	start := len(dst)
+	if pm.dags != nil {
+		for i := range pm.dags.layers {
+			if data := pm.dags.layers[i].data(within.Addr()); data != nil {
+				dst = appendPrefixes(dst, data, within)
+			}
+		}
+		if len(pm.dags.layers) == 1 {
+			return dst
+		}
+	} else {
+		for p := range pm.All() {
+			if p, ok := clip(p, within); ok {
+				dst = append(dst, p)
+			}
+		}
+	}
+	added := dst[start:]
+	slices.SortFunc(added, comparePrefixes)
+	return dst[:start+len(aggregate(added))]
+}
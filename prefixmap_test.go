@@ -0,0 +1,178 @@
+package eurip
+
+import (
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestPrefixMap(t *testing.T) {
+	var pm PrefixMap[string]
+	for _, p := range []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "0.0.0.0/0", "2620:db8::/32", "2620:db8:1::/48"} {
+		pm.Set(netip.MustParsePrefix(p), p)
+	}
+	pm.Set(netip.MustParsePrefix("::ffff:10.1.2.3/128"), "host")
+	pm.Set(netip.MustParsePrefix("10.1.2.77/16"), "10.1.0.0/16 again")
+	if pm.Len() != 7 {
+		t.Errorf("Len() = %d, want 7", pm.Len())
+	}
+	for ip, want := range map[string]string{
+		"10.1.2.3":        "host",
+		"::ffff:10.1.2.3": "host",
+		"10.1.2.4":        "10.1.2.0/24",
+		"10.1.3.1":        "10.1.0.0/16 again",
+		"10.2.0.0":        "10.0.0.0/8",
+		"192.0.2.1":       "0.0.0.0/0",
+		"2620:db8:1::5":   "2620:db8:1::/48",
+		"2620:db8:2::5":   "2620:db8::/32",
+		"2620:db9::":      "",
+	} {
+		_, got, ok := pm.Lookup(netip.MustParseAddr(ip))
+		if got != want || ok != (want != "") {
+			t.Errorf("Lookup(%s) = %q, %v, want %q", ip, got, ok, want)
+		}
+	}
+	if p, _, _ := pm.Lookup(netip.MustParseAddr("10.1.2.4")); p != netip.MustParsePrefix("10.1.2.0/24") {
+		t.Errorf("Lookup(10.1.2.4) prefix = %v", p)
+	}
+	var all []string
+	for _, v := range pm.LookupAll(netip.MustParseAddr("::ffff:10.1.2.3")) {
+		all = append(all, v)
+	}
+	if want := []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16 again", "10.1.2.0/24", "host"}; !slices.Equal(all, want) {
+		t.Errorf("LookupAll(10.1.2.3) = %v, want %v", all, want)
+	}
+
+	clone := pm.Clone()
+	if !pm.Delete(netip.MustParsePrefix("10.1.2.0/24")) || pm.Delete(netip.MustParsePrefix("10.1.2.0/24")) {
+		t.Error("Delete(10.1.2.0/24) should succeed once")
+	}
+	if _, got, _ := pm.Lookup(netip.MustParseAddr("10.1.2.4")); got != "10.1.0.0/16 again" {
+		t.Errorf("after Delete, Lookup(10.1.2.4) = %q", got)
+	}
+	if _, got, _ := clone.Lookup(netip.MustParseAddr("10.1.2.4")); got != "10.1.2.0/24" {
+		t.Errorf("clone changed by Delete: Lookup(10.1.2.4) = %q", got)
+	}
+	var keys []string
+	for p := range pm.All() {
+		keys = append(keys, p.String())
+	}
+	want := []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16", "10.1.2.3/32", "2620:db8::/32", "2620:db8:1::/48"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("All() = %v, want %v", keys, want)
+	}
+}
+
+// TestPrefixMapRandom checks Lookup against a linear scan of the prefixes.
+func TestPrefixMapRandom(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	randAddr := func() netip.Addr {
+		// Few distinct leading bytes, so prefixes nest.
+		return netip.AddrFrom4([4]byte{byte(r.IntN(4)), byte(r.IntN(256)), byte(r.IntN(256)), byte(r.IntN(256))})
+	}
+	var pm PrefixMap[int]
+	ref := map[netip.Prefix]int{}
+	for i := range 2000 {
+		p := netip.PrefixFrom(randAddr(), r.IntN(33)).Masked()
+		if r.IntN(4) == 0 {
+			delete(ref, p)
+			pm.Delete(p)
+			continue
+		}
+		ref[p] = i
+		pm.Set(p, i)
+	}
+	if pm.Len() != len(ref) {
+		t.Fatalf("Len() = %d, want %d", pm.Len(), len(ref))
+	}
+	for range 5000 {
+		addr := randAddr()
+		best, bestV := netip.Prefix{}, 0
+		for p, v := range ref {
+			if p.Contains(addr) && (!best.IsValid() || p.Bits() > best.Bits()) {
+				best, bestV = p, v
+			}
+		}
+		p, v, ok := pm.Lookup(addr)
+		if ok != best.IsValid() || p != best || v != bestV {
+			t.Fatalf("Lookup(%s) = %v, %d, %v, want %v, %d", addr, p, v, ok, best, bestV)
+		}
+	}
+}
+
+// TestDAGPrefixMap checks a PrefixMap read from bitset DAGs, as Matchers use
+// for the EU tables, against a trie of the same prefixes.
+func TestDAGPrefixMap(t *testing.T) {
+	dags := dagPrefixMap(
+		dagLayer[string]{buildTable("10.0.0.0/8", "44.0.0.0/16"), buildTable("2620:db8::/32"), "a"},
+		dagLayer[string]{buildTable("10.1.0.0/16", "45.0.0.0/24", "44.0.0.0/16"), []uint16{0, 0}, "b"},
+	)
+	var trie PrefixMap[string]
+	for p, v := range dags.All() {
+		trie.Set(p, v)
+	}
+	want := []string{"10.0.0.0/8 a", "10.1.0.0/16 b", "44.0.0.0/16 a", "45.0.0.0/24 b", "2620:db8::/32 a"}
+	var got []string
+	for p, v := range dags.All() {
+		got = append(got, p.String()+" "+v)
+	}
+	if !slices.Equal(got, want) || dags.Len() != len(want) {
+		t.Errorf("All() = %v, Len() = %d, want %v", got, dags.Len(), want)
+	}
+	for _, ip := range []string{"10.1.2.3", "10.2.0.1", "44.0.0.1", "45.0.0.1", "45.0.1.1", "::ffff:10.1.0.1", "2620:db8::1", "2620:db9::1", "9.0.0.1"} {
+		addr := netip.MustParseAddr(ip)
+		p, v, ok := dags.Lookup(addr)
+		wp, wv, wok := trie.Lookup(addr)
+		if p != wp || v != wv || ok != wok {
+			t.Errorf("Lookup(%s) = %v, %q, %v, want %v, %q, %v", ip, p, v, ok, wp, wv, wok)
+		}
+		if dags.contains(addr.Unmap()) != wok {
+			t.Errorf("contains(%s) = %v, want %v", ip, !wok, wok)
+		}
+		var all, wantAll []string
+		for p, v := range dags.LookupAll(addr) {
+			all = append(all, p.String()+" "+v)
+		}
+		for p, v := range trie.LookupAll(addr) {
+			wantAll = append(wantAll, p.String()+" "+v)
+		}
+		if !slices.Equal(all, wantAll) {
+			t.Errorf("LookupAll(%s) = %v, want %v", ip, all, wantAll)
+		}
+		// Blocks around unheld addresses hold nothing in either form.
+		if b, _, ok := dags.block(addr.Unmap()); !ok {
+			for p := range trie.All() {
+				if b.Overlaps(p) {
+					t.Errorf("block(%s) = %s, which overlaps %s", ip, b, p)
+				}
+			}
+		}
+	}
+	if v, ok := dags.Get(netip.MustParsePrefix("45.0.0.0/24")); v != "b" || !ok {
+		t.Errorf("Get(45.0.0.0/24) = %q, %v", v, ok)
+	}
+	if _, ok := dags.Get(netip.MustParsePrefix("45.0.0.0/25")); ok {
+		t.Error("Get(45.0.0.0/25) found a prefix the map doesn't have")
+	}
+	covered := dags.appendCovered(nil, netip.MustParsePrefix("0.0.0.0/0"))
+	if want := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("44.0.0.0/16"), netip.MustParsePrefix("45.0.0.0/24")}; !slices.Equal(covered, want) {
+		t.Errorf("appendCovered(0.0.0.0/0) = %v, want %v", covered, want)
+	}
+
+	// Writing to a clone copies the prefixes into its own trie.
+	clone := dags.Clone()
+	clone.Set(netip.MustParsePrefix("10.1.2.0/24"), "c")
+	if !clone.Delete(netip.MustParsePrefix("10.0.0.0/8")) || clone.Len() != len(want) {
+		t.Errorf("after Set and Delete, clone has %d prefixes, want %d", clone.Len(), len(want))
+	}
+	if _, v, _ := clone.Lookup(netip.MustParseAddr("10.1.2.3")); v != "c" {
+		t.Errorf("clone Lookup(10.1.2.3) = %q, want c", v)
+	}
+	if _, v, _ := dags.Lookup(netip.MustParseAddr("10.1.2.3")); v != "b" || dags.Len() != len(want) {
+		t.Errorf("writing the clone changed the original: Lookup(10.1.2.3) = %q, Len() = %d", v, dags.Len())
+	}
+	if b, _, ok := clone.block(netip.MustParseAddr("10.2.0.1")); ok || b.Overlaps(netip.MustParsePrefix("10.1.0.0/16")) || !b.Contains(netip.MustParseAddr("10.2.0.1")) {
+		t.Errorf("clone block(10.2.0.1) = %s, %v", b, ok)
+	}
+}
@@ -6,50 +6,185 @@ to test for European IP addresses.
 
 # pylint: disable=invalid-name
 
+import argparse
 import csv
+import hashlib
 import io
+import json
 import os
 import ipaddress
 import struct
 import zipfile
 
 
-# TODO: update for Brexit?
+# EU member states, as the ISO 3166-1 codes GeoLite2 uses. These sets are
+# exported as EUMembers, EEAMembers, and SchengenMembers in members.go.
 EU_COUNTRIES = set(
-    "AT BE BG CY CZ DE DK EE EL ES FI FR HR HU "
-    "IE IT LT LU LV MT NL PL PT RO SE SI SK UK".split())
+    "AT BE BG CY CZ DE DK EE ES FI FR GR HR HU "
+    "IE IT LT LU LV MT NL PL PT RO SE SI SK".split())
+
+# The UK left the EU in 2020. It gets its own table so that matchers can
+# still opt into pre-Brexit semantics.
+UK_COUNTRIES = {"GB"}
+
+# Microstates that use the euro and sit inside the EU's borders without being
+# members: Andorra, Monaco, San Marino, and Vatican City.
+MICROSTATE_COUNTRIES = {"AD", "MC", "SM", "VA"}
+
+# Named country sets for --set. GDPR applies across the EEA: the EU plus
+# Iceland, Liechtenstein, and Norway.
+EEA_COUNTRIES = EU_COUNTRIES | {"IS", "LI", "NO"}
+SCHENGEN_COUNTRIES = (EU_COUNTRIES - {"CY", "IE"}) | {"CH", "IS", "LI", "NO"}
+COUNTRY_SETS = {
+    'eu': EU_COUNTRIES,
+    'eea': EEA_COUNTRIES,
+    'gdpr': EEA_COUNTRIES,
+    'schengen': SCHENGEN_COUNTRIES,
+}
+
+def in_countries(countries):
+    """Selects networks located in one of countries."""
+    return lambda row, country: country in countries
+
+def is_uncertain(row, country):
+    """Selects anycast and satellite networks, whose location is unreliable."""
+    return row.get('is_anycast') == '1' or row.get('is_satellite_provider') == '1'
+
+def is_listed(row, country):
+    """Selects every network in the database, located or not."""
+    return True
+
+# Countries the country table maps networks to: all those a table is built for.
+COUNTRY_TABLE_COUNTRIES = EU_COUNTRIES | UK_COUNTRIES | MICROSTATE_COUNTRIES
+
+# Output tables, by the file prefix they are written under, and the
+# predicate selecting their networks.
+TABLES = {
+    'euro': in_countries(EU_COUNTRIES),
+    'euro_gb': in_countries(UK_COUNTRIES),
+    'euro_micro': in_countries(MICROSTATE_COUNTRIES),
+    'uncertain': is_uncertain,
+    # Space the database has any data for, so strict matchers can tell a
+    # confident non-EU answer from an address nothing is known about.
+    'known': is_listed,
+}
+
+COUNTRY_ZIP = 'GeoLite2-Country-CSV.zip'
+ASN_ZIP = 'GeoLite2-ASN-CSV.zip'
+CITY_ZIP = 'GeoLite2-City-CSV.zip'
+
+# Digests of the source databases, in sha256sum format. codegen.py embeds them
+# in the generated code, so data updates can be traced to a snapshot.
+SOURCES = 'sources.txt'
+
+def sha256(path):
+    """Returns the hex SHA-256 digest of the file at path."""
+    h = hashlib.sha256()
+    with open(path, 'rb') as f:
+        for chunk in iter(lambda: f.read(1 << 20), b''):
+            h.update(chunk)
+    return h.hexdigest()
+
+def read_sources():
+    """Returns {filename: digest} from SOURCES."""
+    try:
+        return {name: digest for digest, name in (l.split() for l in open(SOURCES))}
+    except IOError:
+        return {}
+
+def record_source(path):
+    """Updates SOURCES with the digest of path."""
+    sources = read_sources()
+    sources[path] = sha256(path)
+    with open(SOURCES, 'w') as f:
+        for name in sorted(sources):
+            f.write('%s  %s\n' % (sources[name], name))
+
+# Whether malformed input rows are skipped rather than fatal, set by --lenient,
+# and the rows skipped, as {'source', 'line', 'reason'} dicts for the report.
+LENIENT = False
+SKIPPED = []
+
+def valid_rows(rows, source, family, check=None):
+    """
+    Returns the rows of a GeoLite2 family ('IPv4' or 'IPv6') blocks CSV from
+    source whose network is a well-formed family network, and that check, if
+    given, doesn't reject by raising ValueError or KeyError. A malformed row
+    is an error, or with --lenient, is skipped and recorded in SKIPPED.
+    """
+    out = []
+    for line, row in enumerate(rows, 2):
+        try:
+            net = ipaddress.ip_network(row['network'])
+            if 'IPv%d' % net.version != family:
+                raise ValueError('%s is not an %s network' % (net, family))
+            if check:
+                check(row)
+        except (ValueError, KeyError) as e:
+            where = '%s %s.csv line %d' % (source, family, line)
+            if not LENIENT:
+                raise ValueError('%s: %s (use --lenient to skip malformed rows)' % (where, e))
+            SKIPPED.append({'source': '%s %s.csv' % (source, family), 'line': line, 'reason': str(e)})
+            continue
+        out.append(row)
+    return out
 
-def get_euro_cidrs(path):
+def load_csv(f, suffix):
+    """Returns a DictReader over the entry of zipfile f ending with suffix."""
+    entry = next(e for e in f.filelist if e.filename.endswith(suffix))
+    contents = f.read(entry).decode('utf8')
+    return csv.DictReader(io.StringIO(contents))
+
+def get_cidrs(path):
+    """
+    Returns {table: (ipv4 networks, ipv6 networks)}, {family: [(network,
+    country)]} for the country table, the database version, and counts of the
+    input networks for the report: {'tables': {table: [ipv4, ipv6]},
+    'countries': {country: [ipv4, ipv6]}, 'skipped': [row]}.
+    """
     f = zipfile.ZipFile(path)
 
     def load(suffix):
-        entry = next(e for e in f.filelist if e.filename.endswith(suffix))
-        contents = f.read(entry).decode('utf8')
-        return csv.DictReader(io.StringIO(contents))
+        return load_csv(f, suffix)
 
     ipv4 = load('IPv4.csv')
     ipv6 = load('IPv6.csv')
     locs = load('Locations-en.csv')
     version = os.path.dirname(f.filelist[0].filename).split('_')[1]
 
-    eu_locs = set()
-    for loc in locs:
-        if loc['country_iso_code'] in EU_COUNTRIES:
-            eu_locs.add(loc['geoname_id'])
-
-    ipv4_cidrs = [row['network'] for row in ipv4 if row['geoname_id'] in eu_locs]
-    ipv6_cidrs = [row['network'] for row in ipv6 if row['geoname_id'] in eu_locs]
-
-    ipv4_cidrs.sort()
-    ipv6_cidrs.sort()
+    loc_countries = {loc['geoname_id']: loc['country_iso_code'] for loc in locs}
+    skipped = len(SKIPPED)
+    ipv4 = valid_rows(ipv4, path, 'IPv4')
+    ipv6 = valid_rows(ipv6, path, 'IPv6')
 
     def collapse(cs):
-        return list(ipaddress.collapse_addresses(ipaddress.ip_network(c) for c in cs))
-
-    ipv4_collapsed = collapse(ipv4_cidrs)
-    ipv6_collapsed = collapse(ipv6_cidrs)
-
-    return ipv4_collapsed, ipv6_collapsed, version
+        return aggregate([ipaddress.ip_network(c) for c in cs])
+
+    tables = {}
+    counts = {'tables': {}, 'countries': {}, 'skipped': SKIPPED[skipped:]}
+    def select(rows, pred):
+        return sorted(row['network'] for row in rows
+                      if pred(row, loc_countries.get(row['geoname_id'])))
+
+    for name, pred in TABLES.items():
+        ipv4_cidrs = select(ipv4, pred)
+        ipv6_cidrs = select(ipv6, pred)
+        tables[name] = collapse(ipv4_cidrs), collapse(ipv6_cidrs)
+        counts['tables'][name] = [len(ipv4_cidrs), len(ipv6_cidrs)]
+
+    for i, rows in enumerate((ipv4, ipv6)):
+        for row in rows:
+            country = loc_countries.get(row['geoname_id']) or '-'
+            counts['countries'].setdefault(country, [0, 0])[i] += 1
+
+    countries = {}
+    for family, rows in (('IPv4', ipv4), ('IPv6', ipv6)):
+        countries[family] = sorted(
+            (ipaddress.ip_network(row['network']), loc_countries[row['geoname_id']])
+            for row in rows
+            if loc_countries.get(row['geoname_id']) in COUNTRY_TABLE_COUNTRIES)
+
+    return tables, countries, version, counts
 
 def emit_simple(v4, v6, b4, b6):
     """
@@ -68,6 +203,63 @@ def networks_to_ranges(nets):
         out.append((int(net.network_address), int(net.broadcast_address)))
     return out
 
+# IPv6 prefixes embedding IPv4 addresses: 6to4 (RFC 3056) puts them after the
+# first 16 bits, and the NAT64 well-known prefix (RFC 6052) in the last 32.
+TRANSITIONAL_PREFIXES = [
+    (ipaddress.ip_network('2002::/16'), 128 - 16 - 32),
+    (ipaddress.ip_network('64:ff9b::/96'), 0),
+]
+
+def transitional(nets):
+    """
+    Returns the IPv6 networks the IPv4 networks nets project to under each
+    transitional prefix in turn.
+    """
+    out = []
+    for prefix, shift in TRANSITIONAL_PREFIXES:
+        for net in nets:
+            addr = int(prefix.network_address) | int(net.network_address) << shift
+            out.append(ipaddress.IPv6Network((addr, prefix.prefixlen + net.prefixlen)))
+    return out
+
+def with_transitional(v4, v6):
+    """
+    Returns v6 with the space under the transitional prefixes replaced by the
+    projection of v4. Both are lists of networks, or of (network, value).
+    """
+    def net(x):
+        return x[0] if isinstance(x, tuple) else x
+    kept = [x for x in v6 if not any(net(x).overlaps(p) for p, _ in TRANSITIONAL_PREFIXES)]
+    projected = transitional([net(x) for x in v4])
+    if v4 and isinstance(v4[0], tuple):
+        projected = list(zip(projected, [x[1] for x in v4] * len(TRANSITIONAL_PREFIXES)))
+    return kept + projected
+
+def merge_intervals(ranges):
+    """
+    Returns the union of (start, end[, value]) inclusive ranges as a sorted
+    list of disjoint ranges, merging those that touch and share a value.
+    """
+    out = []
+    for r in sorted(ranges):
+        assert not (out and r[2:] and out[-1][1] >= r[0]), ('overlapping values', out[-1], r)
+        if out and out[-1][1] + 1 >= r[0] and out[-1][2:] == r[2:]:
+            out[-1] = (out[-1][0], max(out[-1][1], r[1])) + r[2:]
+        else:
+            out.append(r)
+    return out
+
+def aggregate(nets):
+    """
+    Returns the fewest networks covering exactly the same addresses as nets,
+    by dropping nested networks and merging adjacent ones into supernets. The
+    result is checked against nets address by address, as intervals.
+    """
+    out = list(ipaddress.collapse_addresses(nets))
+    assert merge_intervals(networks_to_ranges(out)) == merge_intervals(networks_to_ranges(nets)), \
+        'aggregation changed the address set'
+    return out
+
 class Node:
     """
     Node represents an entry in a bitset DAG.
@@ -108,14 +300,16 @@ class Node:
         # No set_child map, so zero pointer have to be included:
         # return 2 + sum(3 if c else 0 for c in self.children)
 
-    # __hash__ and __eq__ allow for easy deduping
+    # __hash__ and __eq__ allow for easy deduping. Both compare children by
+    # identity, so equal nodes always hash equally and dedupe() merges the
+    # same nodes whatever the hash values are.
     def __hash__(self):
         return hash(tuple(id(x) for x in self.children))
 
     def __eq__(self, other):
         if not isinstance(self, other.__class__):
             return False
-        return self.children == other.children
+        return all(a is b for a, b in zip(self.children, other.children))
 
     def __str__(self):
         if self.addr is not None:
@@ -142,8 +336,11 @@ def emit_bitdag(ranges, outfile, width):
         assert bin(start ^ end).rstrip('1') in ('0b', '0b0'), (hex(start), hex(end))
 
         node = root
-        for a, b in zip(make_nibbles(start), make_nibbles(end)):
-            if a == b:
+        nibbles = list(zip(make_nibbles(start), make_nibbles(end)))
+        for i, (a, b) in enumerate(nibbles):
+            # A range ending within the last nibble, like a single address,
+            # sets children there rather than descending.
+            if a == b and i < len(nibbles) - 1:
                 if node.children[a] is None:
                     node.children[a] = new_node(node)
                 node = node.children[a]
@@ -215,7 +412,7 @@ def emit_bitdag(ranges, outfile, width):
                 return
 
     for n, (start, end) in enumerate(ranges):
-        if n == 0 or ranges[n-1][1] < start - 1:  # adjacency is possible
+        if start > 0 and (n == 0 or ranges[n-1][1] < start - 1):  # adjacency is possible
             test(start - 1, False)
         test(start, True)
         test((start + end) // 2, True)
@@ -223,39 +420,396 @@ def emit_bitdag(ranges, outfile, width):
         if n < len(ranges) - 1 and ranges[n+1][0] > end + 1:  # adjacency is possible
             test(end + 1, False)
 
+    # exhaustive check: the DAG must hold exactly the input ranges
+    def set_ranges(node, prefix=0, depth=0):
+        bits = width - 4 * (depth + 1)
+        for n, child in enumerate(node.children):
+            start = (prefix << 4 | n) << bits
+            if child is root:
+                yield start, start + (1 << bits) - 1
+            elif child:
+                yield from set_ranges(child, prefix << 4 | n, depth + 1)
+    assert merge_intervals(set_ranges(root)) == merge_intervals(ranges), 'DAG differs from input'
+
     # emit
     for node in nodes:
         outfile.write(node.binary())
+    return len(nodes), sum(node.size() for node in nodes)
+
+
+class ValueNode:
+    """
+    ValueNode represents an entry in a value DAG: a bitset DAG where ranges map
+    to small integers instead of a single set bit.
+
+    Children are either ValueNodes or integer values, and identical subtrees
+    are shared.
+
+    Output structure, in little-endian 32-bit words:
+        bitmaps     32b: has_child in the low 16 bits, has_value in the high 16
+        children    32b * bitcount(has_child): index of child entry
+        values      32b * bitcount(has_value): value of each value child
+    """
+
+    def __init__(self):
+        self.addr = None
+        self.children = [None] * 16
+
+    def node_children(self):
+        return [c for c in self.children if isinstance(c, ValueNode)]
+
+    def size(self):
+        return 1 + sum(1 for c in self.children if c is not None)
+
+    def binary(self):
+        has_child = sum(1 << n for n, c in enumerate(self.children) if isinstance(c, ValueNode))
+        has_value = sum(1 << n for n, c in enumerate(self.children) if isinstance(c, int))
+        words = [has_child | has_value << 16]
+        words += [c.addr for c in self.children if isinstance(c, ValueNode)]
+        words += [c for c in self.children if isinstance(c, int)]
+        return struct.pack('<%dI' % len(words), *words)
+
+
+def emit_valuedag(ranges, outfile, width):
+    """Emits a value DAG for a list of non-overlapping (start, end, value)."""
+    root = ValueNode()
+
+    def make_nibbles(x):
+        return [((x >> n) & 0xf) for n in range(width - 4, -1, -4)]
+
+    def set_range(start, end, value):
+        assert bin(start ^ end).rstrip('1') in ('0b', '0b0'), (hex(start), hex(end))
+        node = root
+        nibbles = list(zip(make_nibbles(start), make_nibbles(end)))
+        for i, (a, b) in enumerate(nibbles):
+            if a == b and i < len(nibbles) - 1:
+                if node.children[a] is None:
+                    node.children[a] = ValueNode()
+                node = node.children[a]
+                assert isinstance(node, ValueNode), (hex(start), hex(end))
+            else:
+                for n in range(a, b + 1):
+                    node.children[n] = value
+                return
+
+    for start, end, value in ranges:
+        set_range(start, end, value)
+
+    # Deduplicate bottom-up, replacing nodes whose children all hold the same
+    # value by that value.
+    canonical = {}
+    def dedupe(node):
+        node.children = [dedupe(c) if isinstance(c, ValueNode) else c for c in node.children]
+        if node is not root and isinstance(node.children[0], int) and \
+                node.children == [node.children[0]] * 16:
+            return node.children[0]
+        key = tuple(('n', id(c)) if isinstance(c, ValueNode) else ('v', c)
+                    for c in node.children)
+        return canonical.setdefault(key, node)
+    dedupe(root)
+
+    nodes = []
+    seen = set()
+    def assign_addrs(node):
+        if id(node) in seen:
+            return
+        seen.add(id(node))
+        nodes.append(node)
+        for c in node.node_children():
+            assign_addrs(c)
+    assign_addrs(root)
+    cur = 0
+    for node in nodes:
+        node.addr = cur
+        cur += node.size()
+    print(len(nodes), "nodes for", len(ranges), "ranges, total size:", cur * 4)
+
+    def lookup(x):
+        node = root
+        for a in make_nibbles(x):
+            c = node.children[a]
+            if not isinstance(c, ValueNode):
+                return c
+            node = c
+        return None
+
+    for start, end, value in ranges:
+        for x in (start, (start + end) // 2, end):
+            assert lookup(x) == value, (hex(x), lookup(x), value)
+
+    # exhaustive check: the DAG must map exactly the input ranges
+    def value_ranges(node, prefix=0, depth=0):
+        bits = width - 4 * (depth + 1)
+        for n, child in enumerate(node.children):
+            start = (prefix << 4 | n) << bits
+            if isinstance(child, ValueNode):
+                yield from value_ranges(child, prefix << 4 | n, depth + 1)
+            elif child is not None:
+                yield start, start + (1 << bits) - 1, child
+    assert merge_intervals(value_ranges(root)) == merge_intervals(ranges), 'DAG differs from input'
+
+    for node in nodes:
+        outfile.write(node.binary())
+    return len(nodes), cur * 4
+
+
+def get_asns(path):
+    """
+    Returns {family: [(network, asn)]} and ASN organization names for a
+    GeoLite2 ASN CSV database.
+    """
+    f = zipfile.ZipFile(path)
+    names = {}
+    nets = {}
+    for family in ('IPv4', 'IPv6'):
+        nets[family] = []
+        rows = valid_rows(load_csv(f, family + '.csv'), path, family,
+                          lambda row: int(row['autonomous_system_number']))
+        for row in rows:
+            asn = int(row['autonomous_system_number'])
+            names[asn] = row['autonomous_system_organization']
+            nets[family].append((ipaddress.ip_network(row['network']), asn))
+    return nets, names
+
+def get_subdivisions(path, countries):
+    """
+    Returns {family: [(network, code)]} for a GeoLite2 City CSV database, where
+    code is the ISO 3166-2 code of the network's first-level subdivision,
+    like ES-CN for the Canary Islands. Only networks in countries are kept.
+    """
+    f = zipfile.ZipFile(path)
+    codes = {}
+    for loc in load_csv(f, 'Locations-en.csv'):
+        if loc['country_iso_code'] in countries and loc['subdivision_1_iso_code']:
+            codes[loc['geoname_id']] = '%s-%s' % (
+                loc['country_iso_code'], loc['subdivision_1_iso_code'])
+    nets = {}
+    for family in ('IPv4', 'IPv6'):
+        nets[family] = [(ipaddress.ip_network(row['network']), codes[row['geoname_id']])
+                        for row in valid_rows(load_csv(f, family + '.csv'), path, family)
+                        if row['geoname_id'] in codes]
+    return nets
+
+def emit_value_tables(name, nets, values):
+    """
+    Emits <name>_v4.vtr and <name>_v6.vtr, value DAGs mapping the networks in
+    nets, {family: [(network, value)]}, to indexes into the sorted values.
+    Networks sharing a value are aggregated first. Returns report entries
+    for each family.
+    """
+    index = {value: n for n, value in enumerate(values)}
+    report = {}
+    for family, width in (('IPv4', 32), ('IPv6', 128)):
+        by_value = {}
+        for net, value in nets[family]:
+            by_value.setdefault(index[value], []).append(net)
+        ranges = sorted((int(net.network_address), int(net.broadcast_address), i)
+                        for i, value_nets in by_value.items()
+                        for net in aggregate(value_nets))
+        print("%s: %d %s ranges" % (name, len(ranges), family))
+        with open('%s_v%s.vtr' % (name, family[-1]), 'wb') as f:
+            nodes, size = emit_valuedag(ranges, f, width)
+        report['v' + family[-1]] = {
+            'prefixes_in': len(nets[family]), 'prefixes': len(ranges),
+            'nodes': nodes, 'bytes': size}
+    return report
+
+def process_asn(path):
+    """
+    Emits asn_v4.vtr and asn_v6.vtr, mapping networks to indexes into
+    asn_names.tsv, which lists "number\tname" sorted by number.
+    """
+    nets, names = get_asns(path)
+    asns = sorted(names)
+    with open('asn_names.tsv', 'w') as f:
+        for asn in asns:
+            f.write('%d\t%s\n' % (asn, names[asn].replace('\t', ' ').replace('\n', ' ')))
+    emit_value_tables('asn', nets, asns)
+
+def process_subdivisions(path):
+    """
+    Emits subdivision_v4.vtr and subdivision_v6.vtr, mapping networks in any
+    country a table is built for to indexes into subdivision_codes.txt.
+    """
+    countries = EU_COUNTRIES | UK_COUNTRIES | MICROSTATE_COUNTRIES
+    nets = get_subdivisions(path, countries)
+    codes = sorted({code for family in nets.values() for _, code in family})
+    with open('subdivision_codes.txt', 'w') as f:
+        for code in codes:
+            f.write(code + '\n')
+    emit_value_tables('subdivision', nets, codes)
+
+def process_datacenters(path):
+    """
+    Emits datacenter_v4.btr and datacenter_v6.btr from a text file listing
+    datacenter and cloud networks, one per line, with # comments, such as the
+    ranges cloud providers publish. Returns report entries for each family.
+    """
+    nets = {4: [], 6: []}
+    with open(path) as f:
+        for line, text in enumerate(f, 1):
+            text = text.split('#', 1)[0].strip()
+            if not text:
+                continue
+            try:
+                net = ipaddress.ip_network(text)
+            except ValueError as e:
+                where = '%s line %d' % (path, line)
+                if not LENIENT:
+                    raise ValueError('%s: %s (use --lenient to skip malformed rows)' % (where, e))
+                SKIPPED.append({'source': path, 'line': line, 'reason': str(e)})
+                continue
+            nets[net.version].append(net)
+    report = {}
+    for version, width in ((4, 32), (6, 128)):
+        aggregated = aggregate(nets[version])
+        print("datacenter: %d v%d ranges" % (len(aggregated), version))
+        with open('datacenter_v%d.btr' % version, 'wb') as f:
+            nodes, size = emit_bitdag(networks_to_ranges(aggregated), f, width)
+        report['v%d' % version] = {
+            'prefixes_in': len(nets[version]), 'prefixes': len(aggregated),
+            'nodes': nodes, 'bytes': size}
+    return report
 
 
 def main():
+    parser = argparse.ArgumentParser()
+    parser.add_argument('--asn', action='store_true',
+                        help='also build ASN tables from GeoLite2-ASN-CSV.zip')
+    parser.add_argument('--subdivisions', action='store_true',
+                        help='also build subdivision tables from GeoLite2-City-CSV.zip')
+    parser.add_argument('--countries', metavar='CC,...',
+                        help='also build countries_<codes>_v{4,6}.btr for these ISO 3166-1 codes')
+    parser.add_argument('--set', choices=sorted(COUNTRY_SETS),
+                        help='also build <set>_v{4,6}.btr for a named country set')
+    parser.add_argument('--datacenters', metavar='FILE',
+                        help='also build datacenter_v{4,6}.btr from a list of datacenter networks')
+    parser.add_argument('--transitional', action='store_true',
+                        help='also map the 6to4 and NAT64 embeddings of IPv4 networks in IPv6 tables')
+    parser.add_argument('--report', metavar='FILE',
+                        help='also write the statistics report to FILE as JSON')
+    parser.add_argument('--lenient', action='store_true',
+                        help='skip malformed input rows, listing them in the report, instead of failing')
+    options = parser.parse_args()
+    global LENIENT
+    LENIENT = options.lenient
+
+    if options.countries:
+        countries = set(options.countries.upper().split(','))
+        if not all(len(c) == 2 and c.isalpha() for c in countries):
+            parser.error('--countries takes comma-separated two-letter codes')
+        TABLES['countries_' + '_'.join(sorted(countries)).lower()] = in_countries(countries)
+    if options.set:
+        TABLES[options.set] = in_countries(COUNTRY_SETS[options.set])
+
+    if options.asn:
+        process_asn(ASN_ZIP)
+        record_source(ASN_ZIP)
+    if options.subdivisions:
+        process_subdivisions(CITY_ZIP)
+        record_source(CITY_ZIP)
+
     try:
-        v4 = [ipaddress.ip_network(l.strip()) for l in open('euro_v4.txt')]
-        v6 = [ipaddress.ip_network(l.strip()) for l in open('euro_v6.txt')]
+        # The cached tables are only reused if they came from this snapshot.
+        if os.path.exists(COUNTRY_ZIP) and read_sources().get(COUNTRY_ZIP) != sha256(COUNTRY_ZIP):
+            raise IOError('cached tables are from another ' + COUNTRY_ZIP)
+        tables = {}
+        for name in TABLES:
+            v4 = [ipaddress.ip_network(l.strip()) for l in open('%s_v4.txt' % name)]
+            v6 = [ipaddress.ip_network(l.strip()) for l in open('%s_v6.txt' % name)]
+            tables[name] = v4, v6
+        countries = {}
+        for family in ('IPv4', 'IPv6'):
+            countries[family] = [(ipaddress.ip_network(net), country) for net, country in
+                                 (l.split() for l in open('country_v%s.txt' % family[-1]))]
+        counts = json.load(open('input_counts.json'))
+        if set(counts['tables']) != set(TABLES):
+            raise IOError('cached counts are for other tables')
+        if counts.get('skipped') and not LENIENT:
+            raise IOError('cached tables skipped malformed rows')
+        # ASN and subdivision rows were checked above; add the cached ones.
+        SKIPPED.extend(counts.get('skipped', []))
     except IOError:
-        v4, v6, version = get_euro_cidrs('GeoLite2-Country-CSV.zip')
-        with open('euro_v4.txt', 'w') as f:
-            for c in v4:
-                f.write('%s\n' % c)
-        with open('euro_v6.txt', 'w') as f:
-            for c in v6:
-                f.write('%s\n' % c)
+        tables, countries, version, counts = get_cidrs(COUNTRY_ZIP)
+        for family, nets in countries.items():
+            with open('country_v%s.txt' % family[-1], 'w') as f:
+                for net, country in nets:
+                    f.write('%s %s\n' % (net, country))
+        for name, (v4, v6) in tables.items():
+            with open('%s_v4.txt' % name, 'w') as f:
+                for c in v4:
+                    f.write('%s\n' % c)
+            with open('%s_v6.txt' % name, 'w') as f:
+                for c in v6:
+                    f.write('%s\n' % c)
+        with open('input_counts.json', 'w') as f:
+            json.dump(counts, f, indent=1, sort_keys=True)
         with open('version.txt', 'w') as f:
             f.write(version + '\n')
-
-    print("%d v4 ranges" % len(v4))
-    print("%d v6 ranges" % len(v6))
-
-    with open('euro_v4.bin', 'wb') as b4, open('euro_v6.bin', 'wb') as b6:
-        emit_simple(v4, v6, b4, b6)
-
-    v4_ranges = networks_to_ranges(v4)
-    v6_ranges = networks_to_ranges(v6)
-
-    with open('euro_v4.btr', 'wb') as b4:
-        emit_bitdag(v4_ranges, b4, 32)
-    with open('euro_v6.btr', 'wb') as b6:
-        emit_bitdag(v6_ranges, b6, 128)
+        record_source(COUNTRY_ZIP)
+
+    if options.transitional:
+        for name, (v4, v6) in tables.items():
+            tables[name] = v4, aggregate(with_transitional(v4, v6))
+        countries['IPv6'] = sorted(with_transitional(countries['IPv4'], countries['IPv6']))
+
+    report = {
+        'version': open('version.txt').read().strip(),
+        'tables': {},
+        'countries': {c: {'v4': n4, 'v6': n6} for c, (n4, n6) in counts['countries'].items()},
+        'skipped': SKIPPED,
+    }
+    for name, (v4, v6) in tables.items():
+        print("%s: %d v4 ranges" % (name, len(v4)))
+        print("%s: %d v6 ranges" % (name, len(v6)))
+
+        with open('%s_v4.bin' % name, 'wb') as b4, open('%s_v6.bin' % name, 'wb') as b6:
+            emit_simple(v4, v6, b4, b6)
+
+        v4_ranges = networks_to_ranges(v4)
+        v6_ranges = networks_to_ranges(v6)
+
+        report['tables'][name] = {}
+        families = (('v4', v4, v4_ranges, 32), ('v6', v6, v6_ranges, 128))
+        for i, (family, nets, ranges, width) in enumerate(families):
+            with open('%s_%s.btr' % (name, family), 'wb') as f:
+                nodes, size = emit_bitdag(ranges, f, width)
+            report['tables'][name][family] = {
+                'prefixes_in': counts['tables'][name][i],
+                'prefixes': len(nets), 'nodes': nodes, 'bytes': size}
+
+    codes = sorted({country for nets in countries.values() for _, country in nets})
+    with open('country_codes.txt', 'w') as f:
+        for code in codes:
+            f.write(code + '\n')
+    report['tables']['country'] = emit_value_tables('country', countries, codes)
+    if options.datacenters:
+        report['tables']['datacenter'] = process_datacenters(options.datacenters)
+
+    print_report(report)
+    if options.report:
+        with open(options.report, 'w') as f:
+            json.dump(report, f, indent=1, sort_keys=True)
+            f.write('\n')
+
+def print_report(report):
+    """Prints the statistics report as text."""
+    print()
+    print('GeoLite2 %s' % report['version'])
+    print('%-24s %6s %12s %12s %8s %10s' % ('table', 'family', 'prefixes in', 'aggregated', 'nodes', 'bytes'))
+    for name, families in sorted(report['tables'].items()):
+        for family, t in sorted(families.items()):
+            print('%-24s %6s %12d %12d %8d %10d' % (
+                name, family, t['prefixes_in'], t['prefixes'], t['nodes'], t['bytes']))
+    print()
+    print('%-8s %10s %10s' % ('country', 'v4 in', 'v6 in'))
+    for country, c in sorted(report['countries'].items()):
+        print('%-8s %10d %10d' % (country, c['v4'], c['v6']))
+    if report['skipped']:
+        print()
+        print('%d malformed rows skipped:' % len(report['skipped']))
+        for s in report['skipped']:
+            print('%s line %d: %s' % (s['source'], s['line'], s['reason']))
 
 if __name__ == '__main__':
     main()
@@ -0,0 +1,219 @@
+package eurip
+
+import (
+	"cmp"
+	"encoding/binary"
+	"math/bits"
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+)
+
+// RandomEUAddr returns an IPv4 address drawn uniformly from the EU address
+// space, using src for randomness. It is meant for load and property tests
+// of code that branches on IsFromEU.
+//
+// IPv6 isn't sampled, since its EU space is so much larger that a draw
+// uniform over both families would never return IPv4; see RandomEUAddr6. If
+// there is no EU IPv4 space, as in a dataset without IPv4 tables, it
+// returns the zero Addr.
+func RandomEUAddr(src rand.Source) netip.Addr {
+	return defaultMatcher.RandomEUAddr(src)
+}
+
+// RandomNonEUAddr returns an IPv4 address drawn uniformly from the public
+// address space outside the EU, using src for randomness. Special-purpose
+// blocks like 10.0.0.0/8 and multicast are never returned: draws landing in
+// them are redrawn, up to sampleTries times in all. It returns the zero
+// Addr if there is no non-EU IPv4 space, or if every draw was
+// special-purpose, which only happens when little else is left outside the
+// EU.
+func RandomNonEUAddr(src rand.Source) netip.Addr {
+	return defaultMatcher.RandomNonEUAddr(src)
+}
+
+// RandomEUAddr6 is like RandomEUAddr, but returns an IPv6 address drawn
+// uniformly from the EU part of the global unicast space, 2000::/3, which
+// holds every IPv6 network allocated to one. If there is none, as in a
+// dataset without IPv6 tables, it returns the zero Addr.
+func RandomEUAddr6(src rand.Source) netip.Addr {
+	return defaultMatcher.RandomEUAddr6(src)
+}
+
+// RandomNonEUAddr6 is like RandomNonEUAddr, but returns an IPv6 address
+// drawn uniformly from the global unicast space, 2000::/3, outside the EU.
+// Draws landing in special-purpose blocks like 2001:db8::/32 are redrawn as
+// for RandomNonEUAddr.
+func RandomNonEUAddr6(src rand.Source) netip.Addr {
+	return defaultMatcher.RandomNonEUAddr6(src)
+}
+
+// RandomEUAddr is like the package-level RandomEUAddr, but uses m's view of
+// the dataset, with m's overrides applied.
+func (m *Matcher) RandomEUAddr(src rand.Source) netip.Addr {
+	return m.samplers().v4[1].sample(rand.New(src))
+}
+
+// RandomNonEUAddr is like the package-level RandomNonEUAddr, but uses m's
+// view of the dataset, with m's overrides applied.
+func (m *Matcher) RandomNonEUAddr(src rand.Source) netip.Addr {
+	return samplePublic(rand.New(src), m.samplers().v4[0].sample)
+}
+
+// RandomEUAddr6 is like the package-level RandomEUAddr6, but uses m's view
+// of the dataset, with m's overrides applied.
+func (m *Matcher) RandomEUAddr6(src rand.Source) netip.Addr {
+	return m.samplers().v6[1].sample(rand.New(src))
+}
+
+// RandomNonEUAddr6 is like the package-level RandomNonEUAddr6, but uses m's
+// view of the dataset, with m's overrides applied.
+func (m *Matcher) RandomNonEUAddr6(src rand.Source) netip.Addr {
+	return samplePublic(rand.New(src), m.samplers().v6[0].sample)
+}
+
+// samplePublic returns the first draw from sample that isn't special-purpose,
+// or the zero Addr if sample does or sampleTries draws all were.
+func samplePublic(r *rand.Rand, sample func(*rand.Rand) netip.Addr) netip.Addr {
+	for range sampleTries {
+		if addr := sample(r); !addr.IsValid() || !isSpecialUse(addr) {
+			return addr
+		}
+	}
+	return netip.Addr{}
+}
+
+// sampleTries bounds the draws of RandomNonEUAddr and RandomNonEUAddr6. Special-purpose blocks are
+// about a seventh of the IPv4 space, and far less of 2000::/3, so with the
+// usual non-EU space all 64 draws land in them with a probability below
+// 1e-50.
+const sampleTries = 64
+
+// globalUnicast is the IPv6 space the IPv6 samplers draw from.
+var globalUnicast = netip.MustParsePrefix("2000::/3")
+
+// sampler draws addresses uniformly from a set of ranges of one family,
+// weighting each by its size. Addresses and counts are 128-bit integers,
+// with IPv4 addresses as their low 32 bits.
+type sampler struct {
+	is4    bool
+	starts []uint128
+	// cumulative[i] is the number of addresses in ranges before i.
+	cumulative []uint128
+	total      uint128
+}
+
+func (s *sampler) add(r Range) {
+	start := uint128From(r.Start)
+	s.starts = append(s.starts, start)
+	s.cumulative = append(s.cumulative, s.total)
+	s.total = s.total.add(uint128From(r.End).sub(start)).add(uint128{lo: 1})
+}
+
+// sample returns an address drawn from s, or the zero Addr if s is empty.
+func (s *sampler) sample(r *rand.Rand) netip.Addr {
+	if s.total == (uint128{}) {
+		return netip.Addr{}
+	}
+	n := s.total.randN(r)
+	i, found := slices.BinarySearchFunc(s.cumulative, n, uint128.cmp)
+	if !found {
+		i--
+	}
+	return s.starts[i].add(n.sub(s.cumulative[i])).addr(s.is4)
+}
+
+// uint128 is an unsigned 128-bit integer, big enough to count the addresses
+// in any set of IPv6 ranges but the whole space.
+type uint128 struct {
+	hi, lo uint64
+}
+
+func uint128From(a netip.Addr) uint128 {
+	b := a.As16()
+	return uint128{binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])}
+}
+
+func (x uint128) addr(is4 bool) netip.Addr {
+	if is4 {
+		a := uint32(x.lo)
+		return netip.AddrFrom4([4]byte{byte(a >> 24), byte(a >> 16), byte(a >> 8), byte(a)})
+	}
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], x.hi)
+	binary.BigEndian.PutUint64(b[8:], x.lo)
+	return netip.AddrFrom16(b)
+}
+
+func (x uint128) add(y uint128) uint128 {
+	lo, carry := bits.Add64(x.lo, y.lo, 0)
+	hi, _ := bits.Add64(x.hi, y.hi, carry)
+	return uint128{hi, lo}
+}
+
+func (x uint128) sub(y uint128) uint128 {
+	lo, borrow := bits.Sub64(x.lo, y.lo, 0)
+	hi, _ := bits.Sub64(x.hi, y.hi, borrow)
+	return uint128{hi, lo}
+}
+
+func (x uint128) cmp(y uint128) int {
+	if c := cmp.Compare(x.hi, y.hi); c != 0 {
+		return c
+	}
+	return cmp.Compare(x.lo, y.lo)
+}
+
+// randN returns a uniform draw below n, which must be nonzero and below
+// 2^127. Draws of the high word up to n.hi are rejected if they reach n,
+// which happens less than half the time.
+func (n uint128) randN(r *rand.Rand) uint128 {
+	if n.hi == 0 {
+		return uint128{lo: r.Uint64N(n.lo)}
+	}
+	for {
+		if x := (uint128{r.Uint64N(n.hi + 1), r.Uint64()}); x.cmp(n) < 0 {
+			return x
+		}
+	}
+}
+
+// samplerSet holds samplers for the non-EU and EU space of each family of a
+// view with a set of overrides applied.
+type samplerSet struct {
+	v      *view
+	o      *PrefixMap[Result]
+	v4, v6 [2]sampler
+}
+
+// samplers returns samplers for the non-EU and EU space of m's current view
+// and overrides, building them on first use after either changes.
+func (m *Matcher) samplers() *samplerSet {
+	v, o := m.load(), m.overrides.Load()
+	if set := m.sampled.Load(); set != nil && set.v == v && set.o == o {
+		return set
+	}
+	set := &samplerSet{v: v, o: o}
+	set.v4[0].is4, set.v4[1].is4 = true, true
+	for _, r := range v.appendRanges(nil, netip.PrefixFrom(netip.IPv4Unspecified(), 0), o) {
+		s := &set.v4[0]
+		if r.EU {
+			s = &set.v4[1]
+		}
+		s.add(r)
+	}
+	for _, r := range v.appendRanges(nil, globalUnicast, o) {
+		s := &set.v6[0]
+		if r.EU {
+			s = &set.v6[1]
+		}
+		s.add(r)
+	}
+	m.sampled.Store(set)
+	return set
+}
+
+func addrUint32(a netip.Addr) uint32 {
+	b := a.As4()
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
@@ -0,0 +1,108 @@
+package eurip
+
+import (
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestRandomEUAddr(t *testing.T) {
+	src := rand.NewPCG(1, 2)
+	for range 2000 {
+		if a := RandomEUAddr(src); !a.Is4() || !IsFromEU(net.IP(a.AsSlice())) {
+			t.Fatalf("RandomEUAddr() = %s, not an EU IPv4 address", a)
+		}
+		if a := RandomNonEUAddr(src); !a.Is4() || IsFromEU(net.IP(a.AsSlice())) || isSpecialUse(a) {
+			t.Fatalf("RandomNonEUAddr() = %s, not a public non-EU IPv4 address", a)
+		}
+	}
+}
+
+func TestRandomEUAddrWeighted(t *testing.T) {
+	withTables(t, map[*[]uint16][]uint16{
+		&v4Data: buildTable("10.0.0.0/8", "11.0.0.0/16"),
+	})
+	m := NewMatcher()
+	src := rand.NewPCG(3, 4)
+	small := 0
+	const n = 25700
+	for range n {
+		a := m.RandomEUAddr(src).As4()
+		switch {
+		case a[0] == 11 && a[1] == 0:
+			small++
+		case a[0] != 10:
+			t.Fatalf("RandomEUAddr() = %v, outside the EU set", a)
+		}
+	}
+	// 11.0.0.0/16 is 1/257 of the space, so expect about 100 hits.
+	if small < 50 || small > 150 {
+		t.Errorf("RandomEUAddr() drew from 11.0.0.0/16 %d times in %d, want about %d", small, n, n/257)
+	}
+}
+
+func TestRandomAddrEmptySpace(t *testing.T) {
+	src := rand.NewPCG(5, 6)
+	m := NewMatcher()
+	m.SetOverride(netip.MustParsePrefix("0.0.0.0/0"), Result{})
+	if a := m.RandomEUAddr(src); a.IsValid() {
+		t.Errorf("RandomEUAddr() with no EU space = %s, want the zero Addr", a)
+	}
+	m.SetOverride(netip.MustParsePrefix("0.0.0.0/0"), Result{EU: true})
+	if a := m.RandomNonEUAddr(src); a.IsValid() {
+		t.Errorf("RandomNonEUAddr() with no non-EU space = %s, want the zero Addr", a)
+	}
+	// Only special-purpose space is left outside the EU.
+	m.SetOverride(netip.MustParsePrefix("10.0.0.0/8"), Result{})
+	if a := m.RandomNonEUAddr(src); a.IsValid() {
+		t.Errorf("RandomNonEUAddr() with only 10.0.0.0/8 outside the EU = %s, want the zero Addr", a)
+	}
+}
+
+func TestRandomEUAddr6(t *testing.T) {
+	src := rand.NewPCG(7, 8)
+	for range 2000 {
+		if embeddedIPv6 {
+			if a := RandomEUAddr6(src); !a.Is6() || !globalUnicast.Contains(a) || !Lookup(a).EU {
+				t.Fatalf("RandomEUAddr6() = %s, not an EU global unicast address", a)
+			}
+		}
+		if a := RandomNonEUAddr6(src); !a.Is6() || !globalUnicast.Contains(a) || Lookup(a).EU || isSpecialUse(a) {
+			t.Fatalf("RandomNonEUAddr6() = %s, not a public non-EU global unicast address", a)
+		}
+	}
+}
+
+func TestRandomEUAddr6Weighted(t *testing.T) {
+	m := NewMatcher()
+	m.SetOverride(globalUnicast, Result{})
+	m.SetOverride(netip.MustParsePrefix("2000::/4"), Result{EU: true})
+	m.SetOverride(netip.MustParsePrefix("3000::/12"), Result{EU: true})
+	src := rand.NewPCG(9, 10)
+	small := 0
+	const n = 25700
+	for range n {
+		a := m.RandomEUAddr6(src).As16()
+		switch {
+		case a[0] == 0x30 && a[1]>>4 == 0:
+			small++
+		case a[0]>>4 != 2:
+			t.Fatalf("RandomEUAddr6() = %v, outside the EU set", netip.AddrFrom16(a))
+		}
+	}
+	// 3000::/12 is 1/257 of the space, so expect about 100 hits.
+	if small < 50 || small > 150 {
+		t.Errorf("RandomEUAddr6() drew from 3000::/12 %d times in %d, want about %d", small, n, n/257)
+	}
+
+	m.DeleteOverride(netip.MustParsePrefix("2000::/4"))
+	m.DeleteOverride(netip.MustParsePrefix("3000::/12"))
+	if a := m.RandomEUAddr6(src); a.IsValid() {
+		t.Errorf("RandomEUAddr6() with no EU space = %s, want the zero Addr", a)
+	}
+	m.SetOverride(globalUnicast, Result{EU: true})
+	if a := m.RandomNonEUAddr6(src); a.IsValid() {
+		t.Errorf("RandomNonEUAddr6() with no non-EU space = %s, want the zero Addr", a)
+	}
+}
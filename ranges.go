@@ -0,0 +1,320 @@
+package eurip
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"net/netip"
+	"regexp"
+	"strconv"
+)
+
+// Range is an inclusive span of addresses sharing one classification.
+type Range struct {
+	Start, End netip.Addr
+	EU         bool
+	// Country is the ISO 3166-1 alpha-2 code, or empty if the dataset has no
+	// country data for the range.
+	Country string
+}
+
+// Contains reports whether addr lies between r.Start and r.End inclusive.
+// IPv4-mapped IPv6 addresses are treated as IPv4.
+func (r Range) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.BitLen() == r.Start.BitLen() && !addr.Less(r.Start) && !r.End.Less(addr)
+}
+
+// Size returns the number of addresses in r, which for IPv6 ranges can
+// need up to 129 bits.
+func (r Range) Size() *big.Int {
+	n := new(big.Int).SetBytes(r.End.AsSlice())
+	n.Sub(n, new(big.Int).SetBytes(r.Start.AsSlice()))
+	return n.Add(n, big.NewInt(1))
+}
+
+// AppendPrefixes appends the fewest CIDR prefixes exactly covering r to
+// dst, in address order, and returns the extended slice.
+func (r Range) AppendPrefixes(dst []netip.Prefix) []netip.Prefix {
+	return appendRangePrefixes(dst, r.Start, r.End)
+}
+
+// AppendRanges appends ranges covering the whole IPv4 and then IPv6 address
+// space to dst, in address order, and returns the extended slice. Adjacent
+// EU prefixes are merged, and the gaps between them become non-EU ranges.
+func AppendRanges(dst []Range) []Range {
+	return defaultMatcher.AppendRanges(dst)
+}
+
+// AppendRanges is like the package-level AppendRanges, but uses m's view of
+// the dataset, with m's overrides applied.
+func (m *Matcher) AppendRanges(dst []Range) []Range {
+	return m.load().appendAllRanges(dst, m.overrides.Load())
+}
+
+// appendAllRanges appends the ranges of both families, with the EU answers
+// of overrides o, which may be nil, applied.
+func (v *view) appendAllRanges(dst []Range, o *PrefixMap[Result]) []Range {
+	dst = v.appendRanges(dst, netip.PrefixFrom(netip.IPv4Unspecified(), 0), o)
+	return v.appendRanges(dst, netip.PrefixFrom(netip.IPv6Unspecified(), 0), o)
+}
+
+// appendRanges appends ranges tiling all, which must be masked and unmapped,
+// with the EU answers of overrides o, which may be nil, applied.
+func (v *view) appendRanges(dst []Range, all netip.Prefix, o *PrefixMap[Result]) []Range {
+	countries := v.countries.v6
+	if all.Addr().Is4() {
+		countries = v.countries.v4
+	}
+	var cs []countryPrefix
+	valuePrefixes(countries, all, func(p netip.Prefix, c uint32) bool {
+		if int(c) < len(v.countries.codes) {
+			cs = append(cs, countryPrefix{p, v.countries.codes[c]})
+		}
+		return true
+	})
+	b := rangeBuilder{dst: dst, countries: cs, overrides: flattenOverrides(o)}
+
+	next := all.Addr()
+	for _, p := range v.appendPrefixes(nil, all) {
+		start, end := p.Addr(), lastAddr(p)
+		if start != next {
+			b.add(next, start.Prev(), false)
+		}
+		b.add(start, end, true)
+		next = end.Next()
+	}
+	if next.IsValid() {
+		b.add(next, lastAddr(all), false)
+	}
+	return b.dst
+}
+
+type countryPrefix struct {
+	prefix  netip.Prefix
+	country string
+}
+
+// rangeBuilder appends ranges in address order, splitting them by country
+// and by override, and merging neighbours with the same classification.
+type rangeBuilder struct {
+	dst       []Range
+	countries []countryPrefix // sorted and disjoint
+	next      int             // index of the first country not yet passed
+	// overrides are sorted and disjoint, and their EU replaces that of
+	// the ranges they overlap.
+	overrides    []Range
+	nextOverride int // index of the first override not yet passed
+}
+
+func (b *rangeBuilder) add(start, end netip.Addr, eu bool) {
+	for {
+		for b.next < len(b.countries) && lastAddr(b.countries[b.next].prefix).Less(start) {
+			b.next++
+		}
+		if b.next == len(b.countries) || end.Less(b.countries[b.next].prefix.Addr()) {
+			b.append(Range{Start: start, End: end, EU: eu})
+			return
+		}
+		c := b.countries[b.next]
+		if cstart := c.prefix.Addr(); start.Less(cstart) {
+			b.append(Range{Start: start, End: cstart.Prev(), EU: eu})
+			start = cstart
+		}
+		cend := lastAddr(c.prefix)
+		if !cend.Less(end) {
+			b.append(Range{Start: start, End: end, EU: eu, Country: c.country})
+			return
+		}
+		b.append(Range{Start: start, End: cend, EU: eu, Country: c.country})
+		start = cend.Next()
+	}
+}
+
+// append appends r, split where it overlaps overrides.
+func (b *rangeBuilder) append(r Range) {
+	for {
+		for b.nextOverride < len(b.overrides) && b.overrides[b.nextOverride].End.Less(r.Start) {
+			b.nextOverride++
+		}
+		if b.nextOverride == len(b.overrides) || r.End.Less(b.overrides[b.nextOverride].Start) {
+			b.merge(r)
+			return
+		}
+		o := b.overrides[b.nextOverride]
+		if r.Start.Less(o.Start) {
+			b.merge(Range{Start: r.Start, End: o.Start.Prev(), EU: r.EU, Country: r.Country})
+			r.Start = o.Start
+		}
+		if !o.End.Less(r.End) {
+			b.merge(Range{Start: r.Start, End: r.End, EU: o.EU, Country: r.Country})
+			return
+		}
+		b.merge(Range{Start: r.Start, End: o.End, EU: o.EU, Country: r.Country})
+		r.Start = o.End.Next()
+	}
+}
+
+// merge appends r, extending the last range instead if r continues it.
+func (b *rangeBuilder) merge(r Range) {
+	if n := len(b.dst); n > 0 {
+		last := &b.dst[n-1]
+		if last.EU == r.EU && last.Country == r.Country && last.End.Next() == r.Start {
+			last.End = r.End
+			return
+		}
+	}
+	b.dst = append(b.dst, r)
+}
+
+// flattenOverrides returns the space each override in o, which may be nil,
+// decides as sorted, disjoint ranges carrying its EU answer: each prefix,
+// less the longer prefixes within it.
+func flattenOverrides(o *PrefixMap[Result]) []Range {
+	if o == nil {
+		return nil
+	}
+	var out, open []Range
+	var next netip.Addr
+	// finish emits the innermost open prefix's space from next through end.
+	finish := func(end netip.Addr) {
+		if next.IsValid() && !end.Less(next) {
+			out = append(out, Range{Start: next, End: end, EU: open[len(open)-1].EU})
+		}
+		next = end.Next()
+	}
+	for p, r := range o.All() {
+		start := p.Addr()
+		for len(open) > 0 && open[len(open)-1].End.Less(start) {
+			finish(open[len(open)-1].End)
+			open = open[:len(open)-1]
+		}
+		if len(open) > 0 {
+			finish(start.Prev())
+		}
+		open = append(open, Range{Start: start, End: lastAddr(p), EU: r.EU})
+		next = start
+	}
+	for len(open) > 0 {
+		finish(open[len(open)-1].End)
+		open = open[:len(open)-1]
+	}
+	return out
+}
+
+// lastAddr returns the highest address in p.
+func lastAddr(p netip.Prefix) netip.Addr {
+	a := p.Addr().As16()
+	offset := 0
+	if p.Addr().Is4() {
+		offset = 96
+	}
+	for i := offset + p.Bits(); i < 128; i++ {
+		a[i/8] |= 1 << (7 - i%8)
+	}
+	if p.Addr().Is4() {
+		return netip.AddrFrom4([4]byte(a[12:]))
+	}
+	return netip.AddrFrom16(a)
+}
+
+// appendRangePrefixes appends the fewest prefixes exactly covering start
+// through end, in address order.
+func appendRangePrefixes(dst []netip.Prefix, start, end netip.Addr) []netip.Prefix {
+	for {
+		p := netip.PrefixFrom(start, start.BitLen())
+		for bits := 0; bits < start.BitLen(); bits++ {
+			if q := netip.PrefixFrom(start, bits); q.Masked().Addr() == start && !end.Less(lastAddr(q)) {
+				p = q
+				break
+			}
+		}
+		dst = append(dst, p)
+		last := lastAddr(p)
+		if last == end {
+			return dst
+		}
+		start = last.Next()
+	}
+}
+
+// WriteRangesCSV writes every range as a start_ip,end_ip,is_eu,country CSV
+// row, preceded by a header.
+func WriteRangesCSV(w io.Writer) error {
+	return defaultMatcher.WriteRangesCSV(w)
+}
+
+// WriteRangesCSV is like the package-level WriteRangesCSV, but uses m's view
+// of the dataset, with m's overrides applied.
+func (m *Matcher) WriteRangesCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"start_ip", "end_ip", "is_eu", "country"})
+	for _, r := range m.AppendRanges(nil) {
+		cw.Write([]string{r.Start.String(), r.End.String(), strconv.FormatBool(r.EU), r.Country})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WritePrefixesCSV writes every range as network,is_eu,country CSV rows,
+// splitting each into the fewest CIDR prefixes covering it, preceded by a
+// header. This is the layout of GeoLite2's own CSV files, for consumers that
+// index networks rather than address ranges.
+func WritePrefixesCSV(w io.Writer) error {
+	return defaultMatcher.WritePrefixesCSV(w)
+}
+
+// WritePrefixesCSV is like the package-level WritePrefixesCSV, but uses m's
+// view of the dataset, with m's overrides applied.
+func (m *Matcher) WritePrefixesCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"network", "is_eu", "country"})
+	var prefixes []netip.Prefix
+	for _, r := range m.AppendRanges(nil) {
+		eu := strconv.FormatBool(r.EU)
+		prefixes = r.AppendPrefixes(prefixes[:0])
+		for _, p := range prefixes {
+			cw.Write([]string{p.String(), eu, r.Country})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// postgresTableName matches the table names WriteRangesPostgres accepts,
+// which need no quoting.
+var postgresTableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// WriteRangesPostgres writes a script that creates table (if missing) with
+// inet start_ip and end_ip columns and loads every range into it with COPY,
+// suitable for piping into psql. The table name is written into the script
+// as is, so it must be a plain SQL identifier, optionally schema-qualified,
+// like eu_ranges or geo.eu_ranges; WriteRangesPostgres returns an error
+// without writing anything otherwise.
+func WriteRangesPostgres(w io.Writer, table string) error {
+	return defaultMatcher.WriteRangesPostgres(w, table)
+}
+
+// WriteRangesPostgres is like the package-level WriteRangesPostgres, but
+// uses m's view of the dataset, with m's overrides applied.
+func (m *Matcher) WriteRangesPostgres(w io.Writer, table string) error {
+	if !postgresTableName.MatchString(table) {
+		return fmt.Errorf("eurip: %q is not a plain Postgres table name", table)
+	}
+	v := m.load()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "-- eurip ranges, GeoLite2 %s\n", v.data.version)
+	fmt.Fprintf(bw, "CREATE TABLE IF NOT EXISTS %s (start_ip inet NOT NULL, end_ip inet NOT NULL, is_eu boolean NOT NULL, country char(2));\n", table)
+	fmt.Fprintf(bw, "COPY %s (start_ip, end_ip, is_eu, country) FROM stdin;\n", table)
+	for _, r := range v.appendAllRanges(nil, m.overrides.Load()) {
+		country := r.Country
+		if country == "" {
+			country = `\N`
+		}
+		fmt.Fprintf(bw, "%s\t%s\t%t\t%s\n", r.Start, r.End, r.EU, country)
+	}
+	bw.WriteString("\\.\n")
+	return bw.Flush()
+}
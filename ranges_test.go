@@ -0,0 +1,133 @@
+package eurip
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestAppendRangesCoverAddressSpace(t *testing.T) {
+	ranges := AppendRanges(nil)
+	if ranges[0].Start != netip.IPv4Unspecified() {
+		t.Errorf("first range starts at %s", ranges[0].Start)
+	}
+	if last := ranges[len(ranges)-1].End; last != netip.MustParseAddr("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff") {
+		t.Errorf("last range ends at %s", last)
+	}
+	for i, r := range ranges {
+		if r.End.Less(r.Start) {
+			t.Fatalf("range %d is inverted: %v", i, r)
+		}
+		for _, a := range []netip.Addr{r.Start, r.End} {
+			if IsFromEU(net.IP(a.AsSlice())) != r.EU {
+				t.Errorf("IsFromEU(%s) != %v, range %s-%s", a, r.EU, r.Start, r.End)
+			}
+		}
+		if i == 0 || r.Start.Is4() != ranges[i-1].Start.Is4() {
+			continue
+		}
+		prev := ranges[i-1]
+		if prev.End.Next() != r.Start {
+			t.Errorf("gap between %s and %s", prev.End, r.Start)
+		}
+		if prev.EU == r.EU {
+			t.Errorf("unmerged ranges ending %s and starting %s", prev.End, r.Start)
+		}
+	}
+}
+
+func TestLastAddr(t *testing.T) {
+	for _, tc := range []struct{ prefix, last string }{
+		{"2.0.0.0/12", "2.15.255.255"},
+		{"0.0.0.0/0", "255.255.255.255"},
+		{"10.1.2.3/32", "10.1.2.3"},
+		{"2001:db8::/33", "2001:db8:7fff:ffff:ffff:ffff:ffff:ffff"},
+	} {
+		if got := lastAddr(netip.MustParsePrefix(tc.prefix)).String(); got != tc.last {
+			t.Errorf("lastAddr(%s) = %s, want %s", tc.prefix, got, tc.last)
+		}
+	}
+}
+
+func TestWriteRanges(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteRangesCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "start_ip,end_ip,is_eu,country\n") ||
+		!strings.Contains(buf.String(), "\n2.0.0.0,2.15.255.255,true,\n") {
+		t.Errorf("unexpected CSV output:\n%.200s", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteRangesPostgres(&buf, "eu_ranges"); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "COPY eu_ranges (start_ip, end_ip, is_eu, country) FROM stdin;\n0.0.0.0\t1.255.255.255\tfalse\t\\N\n") ||
+		!strings.HasSuffix(out, "\n\\.\n") {
+		t.Errorf("unexpected Postgres output:\n%.400s", out)
+	}
+	buf.Reset()
+	if err := WriteRangesPostgres(&buf, "geo.eu_ranges"); err != nil || !strings.Contains(buf.String(), "COPY geo.eu_ranges (") {
+		t.Errorf("WriteRangesPostgres(geo.eu_ranges) = %v, output:\n%.400s", err, buf.String())
+	}
+	for _, table := range []string{"", "eu ranges", "t; DROP TABLE users; --", `"eu"`, "1eu", "a.b.c", "eu."} {
+		buf.Reset()
+		if err := WriteRangesPostgres(&buf, table); err == nil || buf.Len() != 0 {
+			t.Errorf("WriteRangesPostgres(%q) = %v after writing %d bytes, want an error and nothing written", table, err, buf.Len())
+		}
+	}
+}
+
+func TestRangeMath(t *testing.T) {
+	for _, tc := range []struct {
+		start, end string
+		size       string
+		prefixes   string
+	}{
+		{"0.0.0.0", "255.255.255.255", "4294967296", "0.0.0.0/0"},
+		{"::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "340282366920938463463374607431768211456", "::/0"},
+		{"44.0.0.255", "44.0.1.0", "2", "44.0.0.255/32 44.0.1.0/32"},
+		// Ranges crossing the middle of the address, where a 64-bit half
+		// carries into the other.
+		{"2620:db8::ffff:ffff:ffff:ffff", "2620:db8:0:1::", "2", "2620:db8::ffff:ffff:ffff:ffff/128 2620:db8:0:1::/128"},
+		{"2620:db8::8000:0:0:0", "2620:db8:0:1:7fff:ffff:ffff:ffff", "18446744073709551616", "2620:db8:0:0:8000::/65 2620:db8:0:1::/65"},
+		{"2620:db8::1", "2620:db8::6", "6", "2620:db8::1/128 2620:db8::2/127 2620:db8::4/127 2620:db8::6/128"},
+		{"2620:db8::", "2620:db9::ffff", "79228162514264337593544015872", "2620:db8::/32 2620:db9::/112"},
+	} {
+		r := Range{Start: netip.MustParseAddr(tc.start), End: netip.MustParseAddr(tc.end)}
+		if got := r.Size().String(); got != tc.size {
+			t.Errorf("%s-%s: Size() = %s, want %s", tc.start, tc.end, got, tc.size)
+		}
+		var prefixes []string
+		for _, p := range r.AppendPrefixes(nil) {
+			prefixes = append(prefixes, p.String())
+		}
+		if got := strings.Join(prefixes, " "); got != tc.prefixes {
+			t.Errorf("%s-%s: AppendPrefixes() = %s, want %s", tc.start, tc.end, got, tc.prefixes)
+		}
+		if !r.Contains(r.Start) || !r.Contains(r.End) || r.Contains(r.End.Next()) || r.Start.Prev().IsValid() && r.Contains(r.Start.Prev()) {
+			t.Errorf("%s-%s: Contains is wrong at the boundaries", tc.start, tc.end)
+		}
+	}
+
+	r := Range{Start: netip.MustParseAddr("44.0.0.0"), End: netip.MustParseAddr("44.255.255.255")}
+	if !r.Contains(netip.MustParseAddr("::ffff:44.1.2.3")) || r.Contains(netip.MustParseAddr("::2c01:203")) {
+		t.Error("Contains doesn't unmap IPv4-mapped addresses, or matches IPv6 ones")
+	}
+}
+
+func TestWritePrefixesCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WritePrefixesCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "network,is_eu,country\n0.0.0.0/7,false,\n2.0.0.0/12,true,\n") ||
+		!strings.HasSuffix(out, ",false,\n") {
+		t.Errorf("unexpected CSV output:\n%.200s", out)
+	}
+}
@@ -0,0 +1,68 @@
+package eurip
+
+import (
+	"bufio"
+	"encoding/hex"
+	"io"
+	"strconv"
+)
+
+// redisBatch is the number of members sent per ZADD command.
+const redisBatch = 1000
+
+// WriteRedis writes Redis commands, in the protocol format expected by
+// redis-cli --pipe, that load every range into two sorted sets: key+":v4"
+// and key+":v6". The sets are built under temporary names and renamed into
+// place, so readers never observe a partial load. The dataset version is
+// stored at key+":version".
+//
+// Every member has score 0 and reads "<start>:<eu>:<country>", where start
+// is the range's first address as fixed-width lowercase hex (8 digits for
+// IPv4, 32 for IPv6), eu is 1 or 0, and country may be empty. Because the
+// ranges tile the address space, the range holding an address with hex form
+// h is found with:
+//
+//	ZREVRANGEBYLEX <key>:v4 "(h;" - LIMIT 0 1
+func WriteRedis(w io.Writer, key string) error {
+	return defaultMatcher.WriteRedis(w, key)
+}
+
+// WriteRedis is like the package-level WriteRedis, but uses m's view of the
+// dataset, with m's overrides applied.
+func (m *Matcher) WriteRedis(w io.Writer, key string) error {
+	v := m.load()
+	bw := bufio.NewWriter(w)
+	ranges := v.appendAllRanges(nil, m.overrides.Load())
+	for _, family := range []string{"v4", "v6"} {
+		set, tmp := key+":"+family, key+":"+family+":loading"
+		writeRedisCommand(bw, "DEL", tmp)
+		args := []string{"ZADD", tmp}
+		for _, r := range ranges {
+			if r.Start.Is4() != (family == "v4") {
+				continue
+			}
+			eu := "0"
+			if r.EU {
+				eu = "1"
+			}
+			args = append(args, "0", hex.EncodeToString(r.Start.AsSlice())+":"+eu+":"+r.Country)
+			if len(args) == 2+2*redisBatch {
+				writeRedisCommand(bw, args...)
+				args = args[:2]
+			}
+		}
+		if len(args) > 2 {
+			writeRedisCommand(bw, args...)
+		}
+		writeRedisCommand(bw, "RENAME", tmp, set)
+	}
+	writeRedisCommand(bw, "SET", key+":version", v.data.version)
+	return bw.Flush()
+}
+
+func writeRedisCommand(w *bufio.Writer, args ...string) {
+	w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		w.WriteString("$" + strconv.Itoa(len(a)) + "\r\n" + a + "\r\n")
+	}
+}
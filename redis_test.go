@@ -0,0 +1,113 @@
+package eurip
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// readRedisCommands parses a stream of RESP arrays of bulk strings.
+func readRedisCommands(t *testing.T, b []byte) [][]string {
+	var cmds [][]string
+	r := bufio.NewReader(bytes.NewReader(b))
+	line := func() string {
+		s, err := r.ReadString('\n')
+		if err != nil || !strings.HasSuffix(s, "\r\n") {
+			t.Fatalf("bad RESP line %q: %v", s, err)
+		}
+		return s[:len(s)-2]
+	}
+	for {
+		if _, err := r.Peek(1); err != nil {
+			return cmds
+		}
+		h := line()
+		n, err := strconv.Atoi(strings.TrimPrefix(h, "*"))
+		if err != nil || h[0] != '*' {
+			t.Fatalf("bad RESP array header %q", h)
+		}
+		cmd := make([]string, n)
+		for i := range cmd {
+			size, err := strconv.Atoi(strings.TrimPrefix(line(), "$"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cmd[i] = line()
+			if len(cmd[i]) != size {
+				t.Fatalf("bulk string %q has length %d, want %d", cmd[i], len(cmd[i]), size)
+			}
+		}
+		cmds = append(cmds, cmd)
+	}
+}
+
+func TestWriteRedis(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteRedis(&buf, "eurip"); err != nil {
+		t.Fatal(err)
+	}
+	sets := map[string][]string{}
+	strs := map[string]string{}
+	for _, cmd := range readRedisCommands(t, buf.Bytes()) {
+		switch cmd[0] {
+		case "DEL":
+			delete(sets, cmd[1])
+		case "ZADD":
+			for i := 2; i < len(cmd); i += 2 {
+				sets[cmd[1]] = append(sets[cmd[1]], cmd[i+1])
+			}
+		case "RENAME":
+			sets[cmd[2]] = sets[cmd[1]]
+			delete(sets, cmd[1])
+		case "SET":
+			strs[cmd[1]] = cmd[2]
+		default:
+			t.Fatalf("unexpected command %v", cmd)
+		}
+	}
+	if strs["eurip:version"] != Version {
+		t.Errorf("eurip:version = %q, want %q", strs["eurip:version"], Version)
+	}
+	if len(sets) != 2 {
+		t.Fatalf("got sets %v, want eurip:v4 and eurip:v6", len(sets))
+	}
+	// Emulate ZREVRANGEBYLEX key "(h;" - LIMIT 0 1.
+	lookup := func(ip string) bool {
+		a := netip.MustParseAddr(ip)
+		set := sets["eurip:v6"]
+		if a.Is4() {
+			set = sets["eurip:v4"]
+		}
+		sort.Strings(set)
+		bound := hex.EncodeToString(a.AsSlice()) + ";"
+		i := sort.SearchStrings(set, bound) - 1
+		if i < 0 {
+			t.Fatalf("no range starts at or before %s", ip)
+		}
+		return strings.Split(set[i], ":")[1] == "1"
+	}
+	for _, tc := range []struct {
+		ip      string
+		is_euro bool
+	}{
+		{"2.0.0.0", true},
+		{"2.0.0.1", true},
+		{"1.0.0.1", false},
+		{"2.15.255.255", true},
+		{"2.16.0.0", false},
+		{"::0", false},
+		{"2001:420:4000:1::", true},
+	} {
+		if skipV6(tc.ip) {
+			continue
+		}
+		if got := lookup(tc.ip); got != tc.is_euro {
+			t.Errorf("lookup(%s) = %v, want %v", tc.ip, got, tc.is_euro)
+		}
+	}
+}
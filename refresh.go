@@ -0,0 +1,144 @@
+package eurip
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"time"
+)
+
+// A DatasetSource fetches a serialized dataset, in the format
+// NewMatcherFromBytes reads, for Refresh and StartAutoRefresh. It can
+// download one, read one, or run the generator. The bytes it returns are
+// used in place, so it must not change them afterwards.
+type DatasetSource func(ctx context.Context) ([]byte, error)
+
+// FileSource returns a DatasetSource that reads the file at path.
+func FileSource(path string) DatasetSource {
+	return func(context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}
+}
+
+// URLSource returns a DatasetSource that fetches url with
+// http.DefaultClient. A response other than 200 OK is an error.
+func URLSource(url string) DatasetSource {
+	return func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("eurip: fetching %s: %s", url, resp.Status)
+		}
+		return io.ReadAll(resp.Body)
+	}
+}
+
+// A RefreshEvent reports one StartAutoRefresh attempt to the hook set with
+// WithRefreshHook.
+type RefreshEvent struct {
+	// Version is the version of the dataset in use after the attempt.
+	Version string
+	// Err is why the attempt failed, or nil if it swapped in a new dataset.
+	Err error
+	// Next is how long until the next attempt, before jitter.
+	Next time.Duration
+}
+
+// WithRefreshHook calls fn after each StartAutoRefresh attempt, for logging
+// or alerting. fn runs in the refreshing goroutine.
+func WithRefreshHook(fn func(RefreshEvent)) Option {
+	return func(m *Matcher) {
+		m.refreshHook = fn
+	}
+}
+
+// Refresh fetches a dataset from src and swaps it in, keeping m's options.
+// Lookups in flight finish with the old dataset. If the new one can't be
+// fetched or doesn't validate, m keeps the old one and Refresh returns the
+// error.
+func (m *Matcher) Refresh(ctx context.Context, src DatasetSource) error {
+	b, err := src(ctx)
+	if err != nil {
+		return err
+	}
+	d, err := parseDataset(b)
+	if err != nil {
+		return err
+	}
+	v := m.newView(d)
+	if err := v.validate(); err != nil {
+		return err
+	}
+	m.cur.Store(v)
+	if m.vars != nil {
+		m.vars.setVersion(d.version)
+	}
+	return nil
+}
+
+// StartAutoRefresh starts a goroutine that calls Refresh with src every
+// interval until ctx is done. Each wait is jittered by up to a tenth of
+// interval, so a fleet of processes doesn't fetch at once. After a failure
+// the next attempt comes sooner, at an eighth of interval, doubling with
+// each further failure up to interval.
+func (m *Matcher) StartAutoRefresh(ctx context.Context, interval time.Duration, src DatasetSource) {
+	go func() {
+		next := interval
+		failures := 0
+		for {
+			t := time.NewTimer(jitter(next))
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+			err := m.Refresh(ctx, src)
+			if ctx.Err() != nil {
+				return
+			}
+			next = interval
+			if err != nil {
+				failures++
+				next = refreshBackoff(interval, failures)
+			} else {
+				failures = 0
+			}
+			if m.refreshHook != nil {
+				m.refreshHook(RefreshEvent{Version: m.load().data.version, Err: err, Next: next})
+			}
+		}
+	}()
+}
+
+// refreshBackoff returns the wait after the given number of consecutive
+// failures.
+func refreshBackoff(interval time.Duration, failures int) time.Duration {
+	d := interval / 8
+	for range failures - 1 {
+		if d >= interval/2 {
+			return interval
+		}
+		d *= 2
+	}
+	return max(d, 1)
+}
+
+// jitter returns d moved randomly by up to a tenth of it either way.
+func jitter(d time.Duration) time.Duration {
+	spread := d / 10
+	if spread <= 0 {
+		return d
+	}
+	return d - spread + rand.N(2*spread+1)
+}
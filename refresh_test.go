@@ -0,0 +1,146 @@
+package eurip
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// refreshedDataset returns a serialized dataset that locates 44.0.0.0/8 in
+// the EU, and the UK at 45.0.0.0/8.
+func refreshedDataset(t *testing.T) []byte {
+	t.Helper()
+	old := [...][]uint16{v4Data, gbV4Data}
+	v4Data, gbV4Data = buildTable("44.0.0.0/8"), buildTable("45.0.0.0/8")
+	defer func() { v4Data, gbV4Data = old[0], old[1] }()
+	b, err := NewMatcher().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestRefresh(t *testing.T) {
+	b := refreshedDataset(t)
+	m := NewMatcher(WithUKTreatedAsEU(true))
+	eu, uk, old := netip.MustParseAddr("44.0.0.1"), netip.MustParseAddr("45.0.0.1"), netip.MustParseAddr("2.0.0.1")
+	if m.Lookup(eu).EU || !m.Lookup(old).EU {
+		t.Fatal("Lookup answers from the new dataset before Refresh")
+	}
+
+	if err := m.Refresh(context.Background(), func(context.Context) ([]byte, error) { return b, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if !m.Lookup(eu).EU || !m.Lookup(uk).EU || m.Lookup(old).EU {
+		t.Errorf("after Refresh, Lookup(%s, %s, %s) = %v, %v, %v, want true, true, false",
+			eu, uk, old, m.Lookup(eu).EU, m.Lookup(uk).EU, m.Lookup(old).EU)
+	}
+
+	fail := errors.New("unreachable")
+	if err := m.Refresh(context.Background(), func(context.Context) ([]byte, error) { return nil, fail }); err != fail {
+		t.Errorf("Refresh with a failing source = %v, want %v", err, fail)
+	}
+	corrupt := append([]byte(nil), b...)
+	for i := datasetHeaderLen; i < len(corrupt); i++ {
+		corrupt[i] = 0xff
+	}
+	if err := m.Refresh(context.Background(), func(context.Context) ([]byte, error) { return corrupt, nil }); !errors.Is(err, ErrDatasetCorrupt) {
+		t.Errorf("Refresh with a corrupt dataset = %v, want ErrDatasetCorrupt", err)
+	}
+	if !m.Lookup(eu).EU {
+		t.Error("a failed Refresh replaced the dataset")
+	}
+}
+
+func TestStartAutoRefresh(t *testing.T) {
+	b := refreshedDataset(t)
+	var calls atomic.Int32
+	src := func(context.Context) ([]byte, error) {
+		if calls.Add(1) <= 2 {
+			return nil, errors.New("not yet")
+		}
+		return b, nil
+	}
+	events := make(chan RefreshEvent, 10)
+	m := NewMatcher(WithRefreshHook(func(e RefreshEvent) { events <- e }))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interval := 40 * time.Millisecond
+	m.StartAutoRefresh(ctx, interval, src)
+
+	for i, want := range []time.Duration{interval / 8, interval / 4, interval} {
+		select {
+		case e := <-events:
+			if failed := i < 2; (e.Err != nil) != failed || e.Next != want || e.Version != Version {
+				t.Errorf("event %d = %+v, want failed %v and Next %v", i, e, failed, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no event %d", i)
+		}
+	}
+	if !m.Lookup(netip.MustParseAddr("44.0.0.1")).EU {
+		t.Error("StartAutoRefresh didn't swap in the new dataset")
+	}
+
+	cancel()
+	time.Sleep(2 * interval)
+	n := calls.Load()
+	time.Sleep(2 * interval)
+	if calls.Load() != n {
+		t.Error("StartAutoRefresh kept running after its context was done")
+	}
+}
+
+func TestRefreshBackoff(t *testing.T) {
+	for failures, want := range []time.Duration{1: 100, 2: 200, 3: 400, 4: 800, 5: 800} {
+		if failures == 0 {
+			continue
+		}
+		if got := refreshBackoff(800, failures); got != want {
+			t.Errorf("refreshBackoff(800, %d) = %v, want %v", failures, got, want)
+		}
+	}
+	for range 100 {
+		if d := jitter(time.Second); d < 900*time.Millisecond || d > 1100*time.Millisecond {
+			t.Fatalf("jitter(1s) = %v", d)
+		}
+	}
+}
+
+func TestSources(t *testing.T) {
+	b := refreshedDataset(t)
+	path := filepath.Join(t.TempDir(), "eurip.dataset")
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/eurip.dataset" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	for name, src := range map[string]DatasetSource{
+		"FileSource": FileSource(path),
+		"URLSource":  URLSource(srv.URL + "/eurip.dataset"),
+	} {
+		m := NewMatcher()
+		if err := m.Refresh(context.Background(), src); err != nil {
+			t.Errorf("Refresh(%s) = %v", name, err)
+		} else if !m.Lookup(netip.MustParseAddr("44.0.0.1")).EU {
+			t.Errorf("Refresh(%s) didn't swap in the dataset", name)
+		}
+	}
+	if err := NewMatcher().Refresh(context.Background(), URLSource(srv.URL+"/missing")); err == nil {
+		t.Error("Refresh(URLSource) of a 404 succeeded")
+	}
+}
@@ -0,0 +1,59 @@
+package eurip
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// An anchor is an address whose classification any EU dataset should agree
+// on: long-standing allocations to networks that have stayed put, and the
+// edges of a few large EU blocks.
+type anchor struct {
+	addr netip.Addr
+	eu   bool
+	note string
+}
+
+var anchors = []anchor{
+	{netip.MustParseAddr("2.0.0.1"), true, "Orange, FR"},
+	{netip.MustParseAddr("85.214.0.1"), true, "Strato, DE"},
+	{netip.MustParseAddr("130.149.7.201"), true, "TU Berlin, DE"},
+	{netip.MustParseAddr("217.0.0.1"), true, "Deutsche Telekom, DE"},
+	{netip.MustParseAddr("2003::1"), true, "Deutsche Telekom, DE"},
+	{netip.MustParseAddr("2a01:e00::1"), true, "Free, FR"},
+	{netip.MustParseAddr("4.2.2.2"), false, "Level 3, US"},
+	{netip.MustParseAddr("17.0.0.1"), false, "Apple, US"},
+	{netip.MustParseAddr("2620:0:860::1"), false, "Wikimedia, US"},
+	{netip.MustParseAddr("1.255.255.255"), false, "last address below 2.0.0.0/12"},
+	{netip.MustParseAddr("2.0.0.0"), true, "first address of 2.0.0.0/12"},
+	{netip.MustParseAddr("2a01:e00::"), true, "first address of 2a01:e00::/27"},
+	{netip.MustParseAddr("2a01:e1f:ffff:ffff:ffff:ffff:ffff:ffff"), true, "last address of 2a01:e00::/27"},
+}
+
+// SelfTest checks that m's dataset is sane before it serves traffic: that
+// it validates, as with Validate, and that it classifies a small embedded
+// set of anchors, well-known EU and non-EU addresses and the edges of EU
+// blocks, as expected. It returns the Validate error, or an error wrapping
+// ErrSelfTestFailed for each anchor classified otherwise, joined. Anchors
+// in a family the dataset has no tables for are skipped. Overrides are
+// ignored, and the checks aren't reported to feedback or expvar.
+//
+// The anchors assume an EU dataset, so datasets built with process.py's
+// --set or --countries may fail them.
+func (m *Matcher) SelfTest() error {
+	v := m.load()
+	if err := v.validate(); err != nil {
+		return err
+	}
+	var errs []error
+	for _, a := range anchors {
+		if !v.data.hasFamily(a.addr) {
+			continue
+		}
+		if eu := v.isEU(a.addr); eu != a.eu {
+			errs = append(errs, fmt.Errorf("%w: %s (%s) has EU %v, want %v", ErrSelfTestFailed, a.addr, a.note, eu, a.eu))
+		}
+	}
+	return errors.Join(errs...)
+}
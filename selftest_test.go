@@ -0,0 +1,34 @@
+package eurip
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	m := NewMatcher()
+	m.SetOverride(netip.MustParsePrefix("2.0.0.0/8"), Result{})
+	if err := m.SelfTest(); err != nil {
+		t.Errorf("SelfTest() on embedded data = %v", err)
+	}
+
+	b := refreshedDataset(t)
+	bad, err := NewMatcherFromBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bad.SelfTest()
+	if !errors.Is(err, ErrSelfTestFailed) || !strings.Contains(err.Error(), "2.0.0.1 (Orange, FR) has EU false, want true") {
+		t.Errorf("SelfTest() on a dataset with only 44.0.0.0/8 = %v, want ErrSelfTestFailed for 2.0.0.1", err)
+	}
+	if strings.Contains(err.Error(), "2003::1") {
+		t.Errorf("SelfTest() failed an IPv6 anchor the dataset agrees with: %v", err)
+	}
+
+	withTables(t, map[*[]uint16][]uint16{&v4Data: {1}})
+	if err := NewMatcher().SelfTest(); !errors.Is(err, ErrDatasetCorrupt) {
+		t.Errorf("SelfTest() with a corrupt table = %v, want ErrDatasetCorrupt", err)
+	}
+}
@@ -0,0 +1,96 @@
+package eurip
+
+import (
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"sync/atomic"
+)
+
+// A Disagreement is an address a ShadowMatcher's primary and candidate
+// classify differently.
+type Disagreement struct {
+	// Addr is the address looked up, unmapped.
+	Addr               netip.Addr
+	Primary, Candidate Result
+	// PrimaryPrefix and CandidatePrefix are the prefixes that decided each
+	// Result.EU, as from MatchedPrefix.
+	PrimaryPrefix, CandidatePrefix netip.Prefix
+}
+
+// A ShadowMatcher answers lookups from a primary Matcher while checking
+// each against a candidate, such as one over next month's dataset, so the
+// candidate can be compared on production traffic before it is promoted.
+// Only the primary's answers are returned; the candidate's errors are
+// ignored. Lookups cost about twice as much as the primary's alone.
+//
+// Each Result of the candidate that differs from the primary's in any field
+// is counted as a disagreement. Both Matchers report their lookups to their
+// own feedback and expvar options, so a candidate is best made without
+// them. A ShadowMatcher is safe for concurrent use.
+type ShadowMatcher struct {
+	primary, candidate *Matcher
+	rate               float64
+	fn                 func(Disagreement)
+
+	lookups, disagreements atomic.Uint64
+}
+
+// NewShadowMatcher returns a ShadowMatcher answering from primary and
+// checking candidate. It calls fn on a random fraction rate, from 0 to 1,
+// of disagreements, for logging examples. fn runs synchronously in the
+// looking-up goroutine, so it should hand the Disagreement off rather than
+// block. fn may be nil to only count disagreements.
+func NewShadowMatcher(primary, candidate *Matcher, rate float64, fn func(Disagreement)) *ShadowMatcher {
+	return &ShadowMatcher{primary: primary, candidate: candidate, rate: rate, fn: fn}
+}
+
+// Lookup is like the primary's Lookup.
+func (s *ShadowMatcher) Lookup(addr netip.Addr) Result {
+	r, _ := s.Check(addr)
+	return r
+}
+
+// Check is like the primary's Check.
+func (s *ShadowMatcher) Check(addr netip.Addr) (Result, error) {
+	addr = addr.Unmap()
+	r, err := s.primary.Check(addr)
+	c, _ := s.candidate.Check(addr)
+	s.compare(addr, r, c)
+	return r, err
+}
+
+// IsFromEU is like the primary's IsFromEU. Only the EU answers are
+// compared, since IsFromEU doesn't compute the others.
+func (s *ShadowMatcher) IsFromEU(ipAddress net.IP) bool {
+	eu := s.primary.IsFromEU(ipAddress)
+	c := s.candidate.IsFromEU(ipAddress)
+	addr, _ := netip.AddrFromSlice(ipAddress)
+	s.compare(addr.Unmap(), Result{EU: eu}, Result{EU: c})
+	return eu
+}
+
+// compare counts one lookup, and a disagreement if r and c differ.
+func (s *ShadowMatcher) compare(addr netip.Addr, r, c Result) {
+	s.lookups.Add(1)
+	if r == c {
+		return
+	}
+	s.disagreements.Add(1)
+	if s.fn == nil || s.rate < 1 && rand.Float64() >= s.rate {
+		return
+	}
+	s.fn(Disagreement{
+		Addr:            addr,
+		Primary:         r,
+		Candidate:       c,
+		PrimaryPrefix:   s.primary.matchedPrefix(addr),
+		CandidatePrefix: s.candidate.matchedPrefix(addr),
+	})
+}
+
+// Counts returns the number of lookups s has compared, and how many of them
+// the candidate disagreed with.
+func (s *ShadowMatcher) Counts() (lookups, disagreements uint64) {
+	return s.lookups.Load(), s.disagreements.Load()
+}
@@ -0,0 +1,46 @@
+package eurip
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestShadowMatcher(t *testing.T) {
+	candidate, err := NewMatcherFromBytes(refreshedDataset(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seen []Disagreement
+	s := NewShadowMatcher(NewMatcher(), candidate, 1, func(d Disagreement) { seen = append(seen, d) })
+
+	moved, gained, same := netip.MustParseAddr("2.0.0.1"), netip.MustParseAddr("44.0.0.1"), netip.MustParseAddr("1.0.0.1")
+	if !s.Lookup(moved).EU || s.Lookup(gained).EU || s.Lookup(same).EU {
+		t.Error("ShadowMatcher didn't answer from the primary")
+	}
+	if !s.IsFromEU(net.ParseIP("::ffff:2.0.0.1")) {
+		t.Error("ShadowMatcher.IsFromEU didn't answer from the primary")
+	}
+	if lookups, disagreements := s.Counts(); lookups != 4 || disagreements != 3 {
+		t.Errorf("Counts() = %d, %d, want 4, 3", lookups, disagreements)
+	}
+	if len(seen) != 3 {
+		t.Fatalf("fn called %d times, want 3", len(seen))
+	}
+	want := Disagreement{
+		Addr:            moved,
+		Primary:         Result{EU: true},
+		Candidate:       Result{},
+		PrimaryPrefix:   NewMatcher().MatchedPrefix(moved),
+		CandidatePrefix: candidate.MatchedPrefix(moved),
+	}
+	if seen[0] != want || seen[2].Addr != moved {
+		t.Errorf("disagreements = %+v, want %+v first and last", seen, want)
+	}
+
+	s = NewShadowMatcher(NewMatcher(), candidate, 0, func(Disagreement) { t.Error("fn called at rate 0") })
+	s.Lookup(moved)
+	if _, disagreements := s.Counts(); disagreements != 1 {
+		t.Errorf("at rate 0, Counts() = _, %d, want 1", disagreements)
+	}
+}
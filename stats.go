@@ -0,0 +1,45 @@
+package eurip
+
+import (
+	"math/big"
+	"net/netip"
+)
+
+// CountryStats summarizes what one country contributes to the EU set.
+type CountryStats struct {
+	// Prefixes is the number of IPv4 and IPv6 CIDR prefixes, after merging
+	// adjacent ones.
+	Prefixes int
+	// IPv4Addrs and IPv6Addrs count the addresses in each family.
+	IPv4Addrs uint64
+	IPv6Addrs *big.Int
+}
+
+// CountryStats returns the contribution of each country to m's EU set, with
+// its overrides applied, keyed by ISO 3166-1 alpha-2 code. EU space without
+// country data is counted under "". It walks the whole dataset, so is meant
+// for reviewing dataset updates rather than calling per request.
+func (m *Matcher) CountryStats() map[string]CountryStats {
+	stats := map[string]CountryStats{}
+	var prefixes []netip.Prefix
+	for _, r := range m.AppendRanges(nil) {
+		if !r.EU {
+			continue
+		}
+		s, ok := stats[r.Country]
+		if !ok {
+			s.IPv6Addrs = new(big.Int)
+		}
+		prefixes = appendRangePrefixes(prefixes[:0], r.Start, r.End)
+		s.Prefixes += len(prefixes)
+		for _, p := range prefixes {
+			if p.Addr().Is4() {
+				s.IPv4Addrs += 1 << (32 - p.Bits())
+			} else {
+				s.IPv6Addrs.Add(s.IPv6Addrs, new(big.Int).Lsh(big.NewInt(1), uint(128-p.Bits())))
+			}
+		}
+		stats[r.Country] = s
+	}
+	return stats
+}
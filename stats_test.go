@@ -0,0 +1,40 @@
+package eurip
+
+import "testing"
+
+func TestCountryStats(t *testing.T) {
+	withTables(t, map[*[]uint16][]uint16{
+		&v4Data: buildTable("2.0.0.0/12", "10.0.0.0/24", "10.0.2.0/24"),
+		&v6Data: buildTable("2001:db8::/32"),
+	})
+	withCountries(t,
+		map[string]uint32{"2.0.0.0/13": 0, "2.8.0.0/13": 1, "81.2.69.0/24": 2},
+		map[string]uint32{"2001:db8::/33": 1},
+		"DE", "FR", "GB")
+	stats := NewMatcher().CountryStats()
+	if len(stats) != 3 {
+		t.Errorf("CountryStats() = %v, want DE, FR, and no country", stats)
+	}
+	for _, tc := range []struct {
+		country  string
+		prefixes int
+		v4       uint64
+		v6       string
+	}{
+		{"DE", 1, 1 << 19, "0"},
+		{"FR", 2, 1 << 19, "39614081257132168796771975168"},
+		{"", 3, 512, "39614081257132168796771975168"},
+	} {
+		if !embeddedIPv6 && tc.v6 != "0" {
+			tc.prefixes, tc.v6 = tc.prefixes-1, "0"
+		}
+		s := stats[tc.country]
+		if s.Prefixes != tc.prefixes || s.IPv4Addrs != tc.v4 || s.IPv6Addrs.String() != tc.v6 {
+			t.Errorf("CountryStats()[%q] = {%d %d %s}, want {%d %d %s}",
+				tc.country, s.Prefixes, s.IPv4Addrs, s.IPv6Addrs, tc.prefixes, tc.v4, tc.v6)
+		}
+	}
+	if _, ok := stats["GB"]; ok {
+		t.Error("CountryStats() counts GB, which isn't in the EU set")
+	}
+}
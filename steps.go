@@ -0,0 +1,105 @@
+package eurip
+
+import "math/bits"
+
+// Steps bounds the work of one lookup. See Matcher.WorstCaseSteps.
+type Steps struct {
+	// Depth is the most nodes a lookup visits in any one table or in the
+	// override trie.
+	Depth int
+	// Nodes is the most nodes a lookup visits in all of them together.
+	Nodes int
+	// Reads is the most memory reads a lookup makes visiting those nodes:
+	// two adjacent 16-bit words per table node, and one per override trie
+	// node.
+	Reads int
+}
+
+// WorstCaseSteps bounds the work Lookup or Check does for any address with
+// m's current tables and overrides. The bound is computed from the tables,
+// not estimated: some address walks Depth nodes, and Nodes and Reads add up
+// the worst case of each walk. Lookups have no loops other than these
+// walks, and don't lock; beyond them, Check only compares the address
+// against a fixed list of special-purpose prefixes. They don't allocate
+// either, except for the error a strict matcher returns for an address it
+// has no data for, which Lookup builds too and drops. The bound leaves out
+// WithFeedback: a sampled lookup also walks the tables once more to find
+// the matched prefix, then calls the feedback function.
+//
+// Whatever the dataset, a table walk visits at most one node per nibble of
+// the address, 8 for IPv4 and 32 for IPv6, and an override walk at most
+// one per bit, plus the root. A lookup walks the EU table, the UK and
+// microstate tables if m counts them, the table of uncertain ranges, and,
+// if m is strict, the table of ranges with any data. The bound holds until
+// the dataset is refreshed or the overrides change.
+func (m *Matcher) WorstCaseSteps() Steps {
+	v := m.load()
+	v.stepsOnce.Do(func() {
+		v.steps = v.worstCaseSteps(m.strict)
+	})
+	s := v.steps
+	if o := m.overrides.Load(); o != nil {
+		for f, root := range [2]*prefixNode[Result]{o.v4, o.v6} {
+			n := root.depth()
+			s[f].Depth = max(s[f].Depth, n)
+			s[f].Nodes += n
+			s[f].Reads += n
+		}
+	}
+	return Steps{
+		Depth: max(s[0].Depth, s[1].Depth),
+		Nodes: max(s[0].Nodes, s[1].Nodes),
+		Reads: max(s[0].Reads, s[1].Reads),
+	}
+}
+
+// worstCaseSteps returns the worst case of the table walks of a lookup, for
+// IPv4 and IPv6 addresses.
+func (v *view) worstCaseSteps(strict bool) [2]Steps {
+	tables := append(v.tables[:len(v.tables):len(v.tables)], v.uncertain)
+	if strict {
+		tables = append(tables, v.known)
+	}
+	var s [2]Steps
+	for _, t := range tables {
+		for f, n := range [2]int{dagDepth(t.v4, 8), dagDepth(t.v6, 32)} {
+			s[f].Depth = max(s[f].Depth, n)
+			s[f].Nodes += n
+			s[f].Reads += 2 * n
+		}
+	}
+	return s
+}
+
+// dagDepth returns the most nodes walk visits in a bitset DAG for addresses
+// of the given number of nibbles. Nodes can be shared between levels, so
+// depths are memoized per node and level.
+func dagDepth(data []uint16, nibbles int) int {
+	type key struct{ p, level int }
+	memo := map[key]int{}
+	var depth func(p, level int) int
+	depth = func(p, level int) int {
+		if level == nibbles-1 {
+			return 1
+		}
+		k := key{p, level}
+		if d, ok := memo[k]; ok {
+			return d
+		}
+		d := 0
+		for _, c := range data[p+2 : p+2+bits.OnesCount16(data[p])] {
+			d = max(d, depth(int(c), level+1))
+		}
+		memo[k] = 1 + d
+		return 1 + d
+	}
+	return depth(0, 0)
+}
+
+// depth returns the most nodes PrefixMap.Lookup visits below n, counting n.
+func (n *prefixNode[V]) depth() int {
+	if n == nil {
+		return 0
+	}
+	return 1 + max(n.child[0].depth(), n.child[1].depth())
+}
@@ -0,0 +1,50 @@
+package eurip
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestWorstCaseSteps(t *testing.T) {
+	if !embeddedIPv6 {
+		t.Skip("the expected bounds are those of the IPv6 tables, left out by eurip_nov6")
+	}
+	withTables(t, map[*[]uint16][]uint16{
+		&v4Data:          buildTable("44.0.0.0/8", "46.1.2.0/24"),
+		&v6Data:          buildTable("2620:db8::/32"),
+		&gbV4Data:        buildTable("45.0.0.0/8"),
+		&gbV6Data:        buildTable("2620:db9:1::/48"),
+		&uncertainV4Data: buildTable(),
+		&uncertainV6Data: buildTable(),
+		&knownV4Data:     buildTable("0.0.0.0/0"),
+		&knownV6Data:     buildTable("::/0"),
+	})
+	// 46.1.2.0/24 is 6 nibbles deep, and 2620:db8::/32 8 and
+	// 2620:db9:1::/48 12. Empty tables, and ones covering everything, are
+	// just their root.
+	for _, tc := range []struct {
+		opts []Option
+		want Steps
+	}{
+		{nil, Steps{Depth: 8, Nodes: 9, Reads: 18}},
+		{[]Option{WithUKTreatedAsEU(true)}, Steps{Depth: 12, Nodes: 21, Reads: 42}},
+		{[]Option{WithUKTreatedAsEU(true), WithStrict(true)}, Steps{Depth: 12, Nodes: 22, Reads: 44}},
+	} {
+		if got := NewMatcher(tc.opts...).WorstCaseSteps(); got != tc.want {
+			t.Errorf("WorstCaseSteps with %d options = %+v, want %+v", len(tc.opts), got, tc.want)
+		}
+	}
+
+	m := NewMatcher()
+	m.SetOverride(netip.MustParsePrefix("2620:db8:1::/48"), Result{})
+	if got, want := m.WorstCaseSteps(), (Steps{Depth: 49, Nodes: 58, Reads: 67}); got != want {
+		t.Errorf("WorstCaseSteps with an override = %+v, want %+v", got, want)
+	}
+}
+
+func TestWorstCaseStepsEmbedded(t *testing.T) {
+	s := NewMatcher(WithUKTreatedAsEU(true), WithMicrostatesTreatedAsEU(true), WithStrict(true)).WorstCaseSteps()
+	if s.Depth < 1 || s.Depth > 32 || s.Nodes > 5*32 || s.Reads != 2*s.Nodes {
+		t.Errorf("WorstCaseSteps = %+v, outside the bounds of five tables", s)
+	}
+}
@@ -0,0 +1,20 @@
+package eurip
+
+import "net/netip"
+
+// Subdivision returns the ISO 3166-2 code of the first-level subdivision
+// addr is in, like "ES-CN" for the Canary Islands, or "" if it is unknown.
+// It covers the countries the embedded tables are built for, where VAT and
+// other rules can differ between regions.
+//
+// The subdivision tables come from the much larger GeoLite2 City database,
+// so they are only embedded when built with -tags eurip_subdivisions, after
+// generating subdivision_data.go with process.py --subdivisions and
+// codegen.py. Without the tag, Subdivision always returns "".
+func Subdivision(addr netip.Addr) string {
+	v, ok := lookupValue(addr, subdivisionV4Data, subdivisionV6Data)
+	if !ok || int(v) >= len(subdivisionCodes) {
+		return ""
+	}
+	return subdivisionCodes[v]
+}
@@ -0,0 +1,10 @@
+//go:build !eurip_subdivisions
+
+package eurip
+
+// Empty subdivision tables, used unless built with the eurip_subdivisions tag.
+var (
+	subdivisionV4Data = []uint32{0}
+	subdivisionV6Data = []uint32{0}
+	subdivisionCodes  []string
+)
@@ -0,0 +1,52 @@
+#!/usr/bin/env python3
+"""
+fuzz.py writes the reference data for the Go fuzz tests: fuzz_v4.txt and
+fuzz_v6.txt list random, overlapping networks, and fuzz_v4.btr and
+fuzz_v6.btr are the bitset DAGs process.py builds from them. Run it from
+this directory; its output is deterministic.
+"""
+
+import ipaddress
+import os
+import random
+import sys
+
+sys.path.insert(0, os.path.join(os.path.dirname(os.path.abspath(__file__)), '..'))
+import process  # pylint: disable=wrong-import-position
+
+
+def random_networks(rng, width, count, lengths):
+    """Returns count random networks with prefix lengths drawn from lengths."""
+    nets = []
+    for _ in range(count):
+        prefixlen = rng.choice(lengths)
+        addr = rng.getrandbits(width) >> (width - prefixlen) << (width - prefixlen)
+        if width == 32:
+            nets.append(ipaddress.IPv4Network((addr, prefixlen)))
+        else:
+            nets.append(ipaddress.IPv6Network((addr, prefixlen)))
+    # Neighbours and nested networks exercise aggregation and node sharing.
+    for net in list(nets[:count // 4]):
+        if net.prefixlen < width:
+            nets.append(next(net.subnets(new_prefix=min(width, net.prefixlen + rng.randint(1, 8)))))
+        sibling = int(net.network_address) ^ (1 << (width - net.prefixlen)) if net.prefixlen else 0
+        nets.append(ipaddress.ip_network((sibling, net.prefixlen)))
+    return nets
+
+
+def main():
+    rng = random.Random(20180602)
+    families = (
+        ('v4', 32, random_networks(rng, 32, 400, list(range(4, 33)) + list(range(16, 25)) * 3)),
+        ('v6', 128, random_networks(rng, 128, 300, list(range(12, 65)) + [128] * 10)),
+    )
+    for family, width, nets in families:
+        with open('fuzz_%s.txt' % family, 'w') as f:
+            for net in nets:
+                f.write('%s\n' % net)
+        with open('fuzz_%s.btr' % family, 'wb') as f:
+            process.emit_bitdag(process.networks_to_ranges(process.aggregate(nets)), f, width)
+
+
+if __name__ == '__main__':
+    main()
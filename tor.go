@@ -0,0 +1,72 @@
+package eurip
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+)
+
+// TorExitListURL is where the Tor Project publishes the current exit
+// addresses, in a format LoadTorExits reads.
+const TorExitListURL = "https://check.torproject.org/torbulkexitlist"
+
+// IsTorExit reports whether addr is a Tor exit in the list last loaded with
+// LoadTorExits. The location of Tor traffic says nothing about its user, so
+// compliance logic should usually treat it as unknown rather than trust
+// IsFromEU. Without a loaded list, IsTorExit always returns false.
+func IsTorExit(addr netip.Addr) bool {
+	return defaultMatcher.IsTorExit(addr)
+}
+
+// LoadTorExits replaces the package-level Tor exit list with the one read
+// from r.
+func LoadTorExits(r io.Reader) error {
+	return defaultMatcher.LoadTorExits(r)
+}
+
+// IsTorExit is like the package-level IsTorExit, but uses m's Tor exit list.
+func (m *Matcher) IsTorExit(addr netip.Addr) bool {
+	exits := m.torExits.Load()
+	if exits == nil {
+		return false
+	}
+	_, ok := (*exits)[addr.Unmap()]
+	return ok
+}
+
+// LoadTorExits replaces m's Tor exit list with the one read from r, which
+// is either the bulk list at TorExitListURL, one address per line, or the
+// exit-addresses format of the Tor Project's TorDNSEL, whose ExitAddress
+// lines are read and other lines skipped. Blank lines and # comments are
+// ignored. The list changes often, so it is kept apart from the dataset:
+// reload it regularly, while lookups go on with the old one. If r holds a
+// malformed address, m keeps its old list and LoadTorExits returns an error
+// wrapping ErrInvalidAddr.
+func (m *Matcher) LoadTorExits(r io.Reader) error {
+	exits := map[netip.Addr]struct{}{}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		fields := strings.Fields(strings.SplitN(s.Text(), "#", 2)[0])
+		switch {
+		case len(fields) == 0:
+			continue
+		case fields[0] == "ExitAddress" && len(fields) >= 2:
+			fields = fields[1:]
+		case len(fields) > 1:
+			// Another exit-addresses line, like ExitNode or Published.
+			continue
+		}
+		addr, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			return fmt.Errorf("%w: Tor exit list line %d: %w", ErrInvalidAddr, line, err)
+		}
+		exits[addr.Unmap()] = struct{}{}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	m.torExits.Store(&exits)
+	return nil
+}
@@ -0,0 +1,52 @@
+package eurip
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestTorExits(t *testing.T) {
+	m := NewMatcher()
+	addr := netip.MustParseAddr("2.0.0.1")
+	if m.IsTorExit(addr) {
+		t.Error("IsTorExit is true before a list is loaded")
+	}
+
+	if err := m.LoadTorExits(strings.NewReader("# bulk list\n2.0.0.1\n\n2620:db8::1\n")); err != nil {
+		t.Fatal(err)
+	}
+	for ip, want := range map[string]bool{
+		"2.0.0.1":         true,
+		"::ffff:2.0.0.1":  true,
+		"2.0.0.2":         false,
+		"2620:db8::1":     true,
+		"2620:db8::2":     false,
+		"not an address?": false,
+	} {
+		addr, _ := netip.ParseAddr(ip)
+		if got := m.IsTorExit(addr); got != want {
+			t.Errorf("IsTorExit(%s) = %v, want %v", ip, got, want)
+		}
+	}
+
+	exitAddresses := `ExitNode 0011BD2485AD45D984EC4159C88FC066E5E3300E
+Published 2026-10-13 20:14:31
+LastStatus 2026-10-14 07:00:00
+ExitAddress 44.0.0.7 2026-10-14 07:23:56
+`
+	if err := m.LoadTorExits(strings.NewReader(exitAddresses)); err != nil {
+		t.Fatal(err)
+	}
+	if !m.IsTorExit(netip.MustParseAddr("44.0.0.7")) || m.IsTorExit(addr) {
+		t.Error("LoadTorExits of an exit-addresses list didn't replace the bulk one")
+	}
+
+	if err := m.LoadTorExits(strings.NewReader("44.0.0.8\n44.0.0\n")); !errors.Is(err, ErrInvalidAddr) || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("LoadTorExits of a malformed list = %v, want ErrInvalidAddr at line 2", err)
+	}
+	if !m.IsTorExit(netip.MustParseAddr("44.0.0.7")) {
+		t.Error("a failed LoadTorExits replaced the list")
+	}
+}
@@ -0,0 +1,157 @@
+package eurip
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+	"time"
+)
+
+// Validate checks that m's tables are well formed: every node a lookup can
+// reach lies within its table, as do the child indexes it holds. It returns
+// an error wrapping ErrDatasetCorrupt if not. The embedded dataset always
+// validates.
+func (m *Matcher) Validate() error {
+	return m.load().validate()
+}
+
+func (v *view) validate() error {
+	for _, t := range v.tables {
+		if err := validateTable("v4", t.v4); err != nil {
+			return err
+		}
+		if err := validateTable("v6", t.v6); err != nil {
+			return err
+		}
+	}
+	if err := validateTable("uncertain v4", v.uncertain.v4); err != nil {
+		return err
+	}
+	if err := validateTable("uncertain v6", v.uncertain.v6); err != nil {
+		return err
+	}
+	if err := validateTable("known v4", v.known.v4); err != nil {
+		return err
+	}
+	if err := validateTable("known v6", v.known.v6); err != nil {
+		return err
+	}
+	if err := validateTable("datacenter v4", v.datacenter.v4); err != nil {
+		return err
+	}
+	if err := validateTable("datacenter v6", v.datacenter.v6); err != nil {
+		return err
+	}
+	if err := validateValueTable("country v4", v.countries.v4); err != nil {
+		return err
+	}
+	return validateValueTable("country v6", v.countries.v6)
+}
+
+// validateTable checks the nodes of a bitset DAG reachable from its root.
+func validateTable(name string, data []uint16) error {
+	seen := map[int]bool{}
+	queue := []int{0}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		if p+2 > len(data) {
+			return corrupt(name, "node %d past end of table (%d words)", p, len(data))
+		}
+		n := bits.OnesCount16(data[p])
+		if p+2+n > len(data) {
+			return corrupt(name, "node %d children past end of table (%d words)", p, len(data))
+		}
+		for _, c := range data[p+2 : p+2+n] {
+			queue = append(queue, int(c))
+		}
+	}
+	return nil
+}
+
+// validateValueTable checks the nodes of a value DAG reachable from its root.
+func validateValueTable(name string, data []uint32) error {
+	seen := map[int]bool{}
+	queue := []int{0}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		if p+1 > len(data) {
+			return corrupt(name, "node %d past end of table (%d words)", p, len(data))
+		}
+		children := bits.OnesCount16(uint16(data[p]))
+		if p+1+children+bits.OnesCount16(uint16(data[p]>>16)) > len(data) {
+			return corrupt(name, "node %d entries past end of table (%d words)", p, len(data))
+		}
+		for _, c := range data[p+1 : p+1+children] {
+			queue = append(queue, int(c))
+		}
+	}
+	return nil
+}
+
+// CheckTables returns an error wrapping ErrTableUnavailable for each of m's
+// options that needs a table m's dataset lacks, joined, or nil if there are
+// none. WithUKTreatedAsEU then has no effect, nor WithMicrostatesTreatedAsEU,
+// and WithStrict is ignored for the families without a known table rather
+// than reporting every non-EU address there as unknown. The embedded dataset
+// has none of these tables, so callers using the options can warn that it
+// must be regenerated, or load one that has them.
+func (m *Matcher) CheckTables() error {
+	d := m.load().data
+	var errs []error
+	for _, o := range []struct {
+		set    bool
+		option string
+		t      table
+	}{
+		{m.uk, "WithUKTreatedAsEU", d.uk},
+		{m.microstates, "WithMicrostatesTreatedAsEU", d.micro},
+		{m.strict, "WithStrict", d.known},
+	} {
+		if !o.set {
+			continue
+		}
+		var missing []string
+		for _, f := range m.Families() {
+			if o.t.empty(f) {
+				missing = append(missing, f.String())
+			}
+		}
+		if missing != nil {
+			errs = append(errs, fmt.Errorf("%w: %s needs the %s table, which dataset %s has no %s data in",
+				ErrTableUnavailable, o.option, o.t.name, d.version, strings.Join(missing, " or ")))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Version returns the GeoLite2 version of m's dataset, as YYYYMMDD: Version
+// for the embedded dataset, or that of the one last loaded with
+// NewMatcherFromBytes or Refresh.
+func (m *Matcher) Version() string {
+	return m.load().data.version
+}
+
+// CheckFreshness returns a *StaleError if m's dataset was published more than
+// maxAge ago.
+func (m *Matcher) CheckFreshness(maxAge time.Duration) error {
+	version := m.load().data.version
+	published, err := time.Parse("20060102", version)
+	if err != nil {
+		return corrupt("version", "%q is not a YYYYMMDD date", version)
+	}
+	if age := time.Since(published); age > maxAge {
+		return &StaleError{Version: version, Age: age, MaxAge: maxAge}
+	}
+	return nil
+}
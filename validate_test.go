@@ -0,0 +1,121 @@
+package eurip
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	if err := NewMatcher(WithUKTreatedAsEU(true), WithMicrostatesTreatedAsEU(true)).Validate(); err != nil {
+		t.Errorf("Validate() on embedded data = %v", err)
+	}
+	for _, tc := range []struct {
+		name string
+		v4   []uint16
+	}{
+		{"empty", []uint16{}},
+		{"truncated root", []uint16{1}},
+		{"missing child index", []uint16{1, 0}},
+		{"child past end", []uint16{1, 0, 7}},
+	} {
+		withTables(t, map[*[]uint16][]uint16{&v4Data: tc.v4})
+		if err := NewMatcher().Validate(); !errors.Is(err, ErrDatasetCorrupt) {
+			t.Errorf("Validate() with %s table = %v, want ErrDatasetCorrupt", tc.name, err)
+		}
+	}
+
+	withTables(t, map[*[]uint16][]uint16{&v4Data: buildTable("2.0.0.0/8")})
+	withCountries(t, nil, nil)
+	truncated := &countryV6Data
+	if !embeddedIPv6 {
+		truncated = &countryV4Data
+	}
+	*truncated = []uint32{1 << 16}
+	if err := NewMatcher().Validate(); !errors.Is(err, ErrDatasetCorrupt) {
+		t.Errorf("Validate() with truncated country table = %v, want ErrDatasetCorrupt", err)
+	}
+}
+
+func TestCheckFreshness(t *testing.T) {
+	m := NewMatcher()
+	if err := m.CheckFreshness(100 * 365 * 24 * time.Hour); err != nil {
+		t.Errorf("CheckFreshness(100y) = %v", err)
+	}
+	err := m.CheckFreshness(24 * time.Hour)
+	var stale *StaleError
+	if !errors.Is(err, ErrDatasetStale) || !errors.As(err, &stale) {
+		t.Fatalf("CheckFreshness(24h) = %v, want a StaleError", err)
+	}
+	if stale.Version != Version || stale.Age < 24*time.Hour {
+		t.Errorf("CheckFreshness(24h) = %+v", stale)
+	}
+}
+
+func TestCheckTables(t *testing.T) {
+	if err := NewMatcher().CheckTables(); err != nil {
+		t.Errorf("CheckTables() without options = %v", err)
+	}
+	m := NewMatcher(WithUKTreatedAsEU(true), WithMicrostatesTreatedAsEU(true), WithStrict(true))
+	err := m.CheckTables()
+	if !errors.Is(err, ErrTableUnavailable) || len(err.(interface{ Unwrap() []error }).Unwrap()) != 3 {
+		t.Errorf("CheckTables() over the embedded dataset = %v, want 3 ErrTableUnavailable", err)
+	}
+	// Strict is ignored without a known table, rather than making every
+	// non-EU address unknown.
+	if r, err := m.Check(netip.MustParseAddr("4.2.2.2")); r.Unknown || err != nil {
+		t.Errorf("strict Check(4.2.2.2) without a known table = %+v, %v", r, err)
+	}
+
+	withTables(t, map[*[]uint16][]uint16{
+		&gbV4Data:    buildTable("45.0.0.0/8"),
+		&gbV6Data:    buildTable("2620:db8::/32"),
+		&knownV4Data: buildTable("44.0.0.0/8"),
+	})
+	m = NewMatcher(WithUKTreatedAsEU(true), WithStrict(true))
+	err = m.CheckTables()
+	if embeddedIPv6 {
+		if !errors.Is(err, ErrTableUnavailable) || !strings.Contains(err.Error(), "WithStrict") || strings.Contains(err.Error(), "WithUKTreatedAsEU") {
+			t.Errorf("CheckTables() with no IPv6 known table = %v, want a WithStrict ErrTableUnavailable", err)
+		}
+		if r, err := m.Check(netip.MustParseAddr("2620:0:860::1")); r.Unknown || err != nil {
+			t.Errorf("strict Check(2620:0:860::1) without an IPv6 known table = %+v, %v", r, err)
+		}
+	} else if err != nil {
+		t.Errorf("CheckTables() with every table = %v", err)
+	}
+	if r, err := m.Check(netip.MustParseAddr("4.2.2.2")); !r.Unknown || !errors.Is(err, ErrUnknown) {
+		t.Errorf("strict Check(4.2.2.2) outside the known table = %+v, %v, want unknown", r, err)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	m := NewMatcher()
+	if v := m.Version(); v != Version {
+		t.Errorf("Version() = %q, want %q", v, Version)
+	}
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(b[8:16], "20240101")
+	if m, err = NewMatcherFromBytes(b); err != nil {
+		t.Fatal(err)
+	}
+	if v := m.Version(); v != "20240101" {
+		t.Errorf("Version() of a dataset marked 20240101 = %q", v)
+	}
+}
+
+func TestLookupString(t *testing.T) {
+	if r, err := LookupString("2.0.0.1"); err != nil || !r.EU {
+		t.Errorf("LookupString(2.0.0.1) = %+v, %v", r, err)
+	}
+	for _, s := range []string{"", "2.0.0", "example.com"} {
+		if _, err := LookupString(s); !errors.Is(err, ErrInvalidAddr) {
+			t.Errorf("LookupString(%q) = %v, want ErrInvalidAddr", s, err)
+		}
+	}
+}
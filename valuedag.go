@@ -0,0 +1,104 @@
+package eurip
+
+import (
+	"math/bits"
+	"net/netip"
+)
+
+// lookupValue returns the value held for addr by v4 or v6, the value DAGs
+// for each address family. IPv4-mapped addresses are treated as IPv4.
+func lookupValue(addr netip.Addr, v4, v6 []uint32) (uint32, bool) {
+	addr = addr.Unmap()
+	if addr.Is4() {
+		a := addr.As4()
+		return walkValue(a[:], v4)
+	}
+	if addr.Is6() {
+		a := addr.As16()
+		return walkValue(a[:], v6)
+	}
+	return 0, false
+}
+
+// walkValue returns the value a value DAG holds for addr. Value DAGs are
+// laid out like the bitset DAGs walk reads, but in 32-bit words, with a
+// value stored for each set child after the child pointers. See ValueNode
+// in process.py.
+func walkValue(addr []byte, data []uint32) (uint32, bool) {
+	p := 0
+	for i := 0; i < len(addr)*2; i++ {
+		n := addr[i/2] >> 4
+		if i%2 == 1 {
+			n = addr[i/2] & 0xf
+		}
+		hasChild, hasValue := uint16(data[p]), uint16(data[p]>>16)
+		if hasChild&(1<<n) != 0 {
+			p = int(data[p+1+bits.OnesCount16(hasChild&(1<<n-1))])
+			continue
+		}
+		if hasValue&(1<<n) != 0 {
+			return data[p+1+bits.OnesCount16(hasChild)+bits.OnesCount16(hasValue&(1<<n-1))], true
+		}
+		break
+	}
+	return 0, false
+}
+
+// valueWalker enumerates the value children of a value DAG as CIDR prefixes.
+type valueWalker struct {
+	nibblePath
+	data   []uint32
+	within netip.Prefix
+	yield  func(netip.Prefix, uint32) bool
+}
+
+// valuePrefixes calls yield, in address order, for each prefix of data that
+// overlaps within, clipped to within, and its value. It stops early, and
+// returns false, if yield does.
+func valuePrefixes(data []uint32, within netip.Prefix, yield func(netip.Prefix, uint32) bool) bool {
+	w := valueWalker{nibblePath: nibblePath{is4: within.Addr().Is4()}, data: data, within: within, yield: yield}
+	return w.node(0, 0)
+}
+
+func (w *valueWalker) node(p, depth int) bool {
+	hasChild, hasValue := uint16(w.data[p]), uint16(w.data[p]>>16)
+	child := p + 1
+	value := p + 1 + bits.OnesCount16(hasChild)
+	for n := 0; n < 16; {
+		if hasChild&(1<<n) != 0 {
+			w.setNibble(depth, n)
+			if w.within.Overlaps(w.prefix(4*(depth+1))) && !w.node(int(w.data[child]), depth+1) {
+				return false
+			}
+			w.setNibble(depth, 0)
+			child++
+			n++
+			continue
+		}
+		if hasValue&(1<<n) == 0 {
+			n++
+			continue
+		}
+		// Emit the largest aligned block of children sharing a value.
+		v := w.data[value]
+		k := alignedBlock(n, func(mask uint16) bool {
+			if hasValue&mask != mask {
+				return false
+			}
+			for i := range bits.OnesCount16(mask) {
+				if w.data[value+i] != v {
+					return false
+				}
+			}
+			return true
+		})
+		w.setNibble(depth, n)
+		if q, ok := clip(w.prefix(4*depth+4-k), w.within); ok && !w.yield(q, v) {
+			return false
+		}
+		w.setNibble(depth, 0)
+		value += 1 << k
+		n += 1 << k
+	}
+	return true
+}
@@ -0,0 +1,144 @@
+package eurip
+
+import (
+	"math/bits"
+	"net/netip"
+	"testing"
+)
+
+// buildValueTable encodes disjoint prefixes, mapped to values, as a value DAG
+// without deduplication.
+func buildValueTable(values map[string]uint32) []uint32 {
+	type node struct {
+		children [16]*node
+		hasValue uint16
+		values   [16]uint32
+	}
+	root := &node{}
+	for s, v := range values {
+		p := netip.MustParsePrefix(s).Masked()
+		a := p.Addr().AsSlice()
+		nibble := func(i int) int { return int(a[i/2]>>(4*(1-i%2))) & 0xf }
+		n := root
+		i := 0
+		for ; (i+1)*4 < p.Bits(); i++ {
+			if n.children[nibble(i)] == nil {
+				n.children[nibble(i)] = &node{}
+			}
+			n = n.children[nibble(i)]
+		}
+		span := 1 << ((i+1)*4 - p.Bits())
+		for c := nibble(i); c < nibble(i)+span; c++ {
+			n.hasValue |= 1 << c
+			n.values[c] = v
+		}
+	}
+	order := []*node{root}
+	index := map[*node]int{}
+	size := 0
+	for i := 0; i < len(order); i++ {
+		n := order[i]
+		index[n] = size
+		size += 1 + bits.OnesCount16(n.hasValue)
+		for _, c := range n.children {
+			if c != nil {
+				order = append(order, c)
+				size++
+			}
+		}
+	}
+	var data []uint32
+	for _, n := range order {
+		var hasChild uint16
+		var words []uint32
+		for c, child := range n.children {
+			if child != nil {
+				hasChild |= 1 << c
+				words = append(words, uint32(index[child]))
+			}
+		}
+		for c := 0; c < 16; c++ {
+			if n.hasValue&(1<<c) != 0 {
+				words = append(words, n.values[c])
+			}
+		}
+		data = append(data, uint32(hasChild)|uint32(n.hasValue)<<16)
+		data = append(data, words...)
+	}
+	return data
+}
+
+func TestWalkValue(t *testing.T) {
+	data := buildValueTable(map[string]uint32{
+		"10.0.0.0/8":     1,
+		"11.1.0.0/16":    2,
+		"192.168.4.0/22": 3,
+		"203.0.113.7/32": 4,
+	})
+	for _, tc := range []struct {
+		ip    string
+		value uint32
+		ok    bool
+	}{
+		{"10.0.0.1", 1, true},
+		{"10.255.0.0", 1, true},
+		{"11.1.2.3", 2, true},
+		{"11.0.0.0", 0, false},
+		{"192.168.3.255", 0, false},
+		{"192.168.7.255", 3, true},
+		{"203.0.113.7", 4, true},
+		{"203.0.113.8", 0, false},
+	} {
+		a := netip.MustParseAddr(tc.ip).As4()
+		if v, ok := walkValue(a[:], data); v != tc.value || ok != tc.ok {
+			t.Errorf("walkValue(%s) = %d, %v, want %d, %v", tc.ip, v, ok, tc.value, tc.ok)
+		}
+	}
+	if v, ok := walkValue([]byte{1, 2, 3, 4}, []uint32{0}); v != 0 || ok {
+		t.Errorf("walkValue() on an empty table = %d, %v", v, ok)
+	}
+}
+
+func TestASN(t *testing.T) {
+	v4, v6, numbers, names := asnV4Data, asnV6Data, asnNumbers, asnNames
+	t.Cleanup(func() { asnV4Data, asnV6Data, asnNumbers, asnNames = v4, v6, numbers, names })
+	asnV4Data = buildValueTable(map[string]uint32{"2.0.0.0/12": 0, "198.51.100.0/24": 1})
+	asnV6Data = buildValueTable(map[string]uint32{"2001:db8::/32": 1})
+	asnNumbers = []uint32{3215, 64496}
+	asnNames = []string{"Orange", "Example Net"}
+
+	for _, tc := range []struct {
+		ip   string
+		asn  uint32
+		name string
+	}{
+		{"2.1.2.3", 3215, "Orange"},
+		{"::ffff:2.1.2.3", 3215, "Orange"},
+		{"198.51.100.1", 64496, "Example Net"},
+		{"2001:db8::1", 64496, "Example Net"},
+		{"1.1.1.1", 0, ""},
+	} {
+		if asn, name := ASN(netip.MustParseAddr(tc.ip)); asn != tc.asn || name != tc.name {
+			t.Errorf("ASN(%s) = %d, %q, want %d, %q", tc.ip, asn, name, tc.asn, tc.name)
+		}
+	}
+}
+
+func TestSubdivision(t *testing.T) {
+	v4, v6, codes := subdivisionV4Data, subdivisionV6Data, subdivisionCodes
+	t.Cleanup(func() { subdivisionV4Data, subdivisionV6Data, subdivisionCodes = v4, v6, codes })
+	subdivisionV4Data = buildValueTable(map[string]uint32{"198.51.100.0/24": 0, "203.0.113.0/24": 1})
+	subdivisionV6Data = buildValueTable(map[string]uint32{"2001:db8::/32": 0})
+	subdivisionCodes = []string{"ES-CN", "ES-MD"}
+
+	for _, tc := range []struct{ ip, code string }{
+		{"198.51.100.1", "ES-CN"},
+		{"203.0.113.1", "ES-MD"},
+		{"2001:db8::1", "ES-CN"},
+		{"192.0.2.1", ""},
+	} {
+		if code := Subdivision(netip.MustParseAddr(tc.ip)); code != tc.code {
+			t.Errorf("Subdivision(%s) = %q, want %q", tc.ip, code, tc.code)
+		}
+	}
+}